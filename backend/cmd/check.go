@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"static-mirrors/pkg/config"
+)
+
+// runCheckConfig 执行check-config子命令：严格检查配置文件，有问题时返回非零退出码，供部署流程使用
+func runCheckConfig(args []string) int {
+	fs := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	path := configFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: static-mirrors check-config [参数]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if _, err := config.CheckFile(*path); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *path, err)
+		return 1
+	}
+	fmt.Printf("%s: 配置检查通过\n", *path)
+	return 0
+}
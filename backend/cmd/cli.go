@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// defaultConfigPath 未指定配置文件时使用的路径
+const defaultConfigPath = "../../config/config.yaml"
+
+// configEnv 指定配置文件路径的环境变量
+const configEnv = "STATIC_MIRRORS_CONFIG"
+
+// globalConfigPath 子命令之前通过 --config 指定的配置文件路径
+var globalConfigPath string
+
+// commands 子命令及其说明，按帮助信息中的顺序排列
+var commands = []struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}{
+	{"serve", "启动镜像服务（默认）", runServe},
+	{"check-config", "检查配置文件", runCheckConfig},
+	{"purge", "通过运行中实例的管理接口删除缓存", runPurge},
+	{"warm", "通过运行中实例的管理接口预热缓存", runWarm},
+	{"stats", "导出运行中实例的访问统计（stats export）", runStats},
+	{"version", "输出版本信息", runVersion},
+}
+
+// run 解析全局参数并执行子命令，没有子命令时启动镜像服务
+func run(args []string) int {
+	fs := flag.NewFlagSet("static-mirrors", flag.ContinueOnError)
+	fs.StringVar(&globalConfigPath, "config", "", fmt.Sprintf("配置文件路径（默认读取 %s，未设置时为 %s）", configEnv, defaultConfigPath))
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintln(out, "用法: static-mirrors [--config 配置文件] [子命令] [参数]")
+		fmt.Fprintln(out, "\n子命令:")
+		for _, cmd := range commands {
+			fmt.Fprintf(out, "  %-14s %s\n", cmd.name, cmd.usage)
+		}
+		fmt.Fprintln(out, "\n全局参数:")
+		fs.PrintDefaults()
+		fmt.Fprintln(out, "\n使用 \"static-mirrors <子命令> -h\" 查看子命令的参数")
+	}
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		return runServe(nil)
+	}
+	if args[0] == "help" {
+		fs.SetOutput(os.Stdout)
+		fs.Usage()
+		return 0
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "未知的子命令: %s\n\n", args[0])
+	fs.Usage()
+	return 2
+}
+
+// configFlag 注册子命令的配置文件参数，默认依次使用全局 --config、环境变量和默认路径
+func configFlag(fs *flag.FlagSet) *string {
+	path := globalConfigPath
+	if path == "" {
+		path = os.Getenv(configEnv)
+	}
+	if path == "" {
+		path = defaultConfigPath
+	}
+	return fs.String("config", path, fmt.Sprintf("配置文件路径（默认读取 %s）", configEnv))
+}
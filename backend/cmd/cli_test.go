@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testConfig 通过检查的最小配置文件
+const testConfig = `app:
+  port: 8080
+cache:
+  ttl:
+    default: 3600
+sources:
+  - name: "npm"
+    domain: "registry.npmjs.org"
+    enabled: true
+    prefix: "/npm"
+`
+
+// writeConfig 把配置写入临时文件并返回路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFlag(t *testing.T) {
+	tests := []struct {
+		name   string
+		global string
+		env    string
+		args   []string
+		want   string
+	}{
+		{name: "默认路径", want: defaultConfigPath},
+		{name: "环境变量", env: "/etc/env.yaml", want: "/etc/env.yaml"},
+		{name: "全局参数优先于环境变量", global: "/etc/global.yaml", env: "/etc/env.yaml", want: "/etc/global.yaml"},
+		{name: "子命令参数优先", global: "/etc/global.yaml", args: []string{"-config", "/etc/sub.yaml"}, want: "/etc/sub.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(configEnv, tt.env)
+			globalConfigPath = tt.global
+			defer func() { globalConfigPath = "" }()
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			path := configFlag(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if *path != tt.want {
+				t.Fatalf("config = %q, want %q", *path, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	valid := writeConfig(t, testConfig)
+	invalid := writeConfig(t, "app:\n  prot: 8080\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"帮助", []string{"help"}, 0},
+		{"全局帮助参数", []string{"-h"}, 0},
+		{"未知的子命令", []string{"unknown"}, 2},
+		{"未知的全局参数", []string{"-verbose"}, 2},
+		{"检查有效配置", []string{"check-config", "-config", valid}, 0},
+		{"检查无效配置", []string{"check-config", "-config", invalid}, 1},
+		{"全局参数指定配置文件", []string{"--config", invalid, "check-config"}, 1},
+		{"多余的参数", []string{"check-config", "-config", valid, "extra"}, 2},
+		{"配置文件不存在", []string{"check-config", "-config", filepath.Join(t.TempDir(), "missing.yaml")}, 1},
+		{"版本", []string{"version"}, 0},
+		{"purge缺少目标", []string{"purge", "-token", "t"}, 2},
+		{"purge缺少令牌", []string{"purge", "-token", "", "https://cdn.jsdelivr.net/npm/a"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { globalConfigPath = "" }()
+			if got := run(tt.args); got != tt.want {
+				t.Fatalf("run(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunPurge(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/admin/purge" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			URLs []string `json:"urls"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		got = append(got, body.URLs...)
+
+		type result struct {
+			Target string `json:"target"`
+			URL    string `json:"url,omitempty"`
+			Error  string `json:"error,omitempty"`
+		}
+		var results []result
+		for _, target := range body.URLs {
+			if target == "bad" {
+				results = append(results, result{Target: target, Error: "无效的URL"})
+			} else {
+				results = append(results, result{Target: target, URL: target})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	}))
+	defer server.Close()
+
+	list := filepath.Join(t.TempDir(), "urls.txt")
+	if err := os.WriteFile(list, []byte("https://cdn.jsdelivr.net/npm/b\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := runPurge([]string{"-server", server.URL, "-token", "secret", "-file", list, "https://cdn.jsdelivr.net/npm/a"}); code != 0 {
+		t.Fatalf("runPurge() = %d, want 0", code)
+	}
+	if want := []string{"https://cdn.jsdelivr.net/npm/a", "https://cdn.jsdelivr.net/npm/b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("提交的URL = %v, want %v", got, want)
+	}
+
+	// 部分失败和认证失败都返回1
+	if code := runPurge([]string{"-server", server.URL, "-token", "secret", "bad"}); code != 1 {
+		t.Fatalf("部分失败时 runPurge() = %d, want 1", code)
+	}
+	if code := runPurge([]string{"-server", server.URL, "-token", "wrong", "https://cdn.jsdelivr.net/npm/a"}); code != 1 {
+		t.Fatalf("令牌错误时 runPurge() = %d, want 1", code)
+	}
+}
+
+func TestAdminFlagsEnv(t *testing.T) {
+	t.Setenv("STATIC_MIRRORS_SERVER", "http://mirror:1108")
+	t.Setenv("STATIC_MIRRORS_TOKEN", "env-token")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	client := adminFlags(fs)
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if client.server != "http://mirror:1108" || client.token != "env-token" || client.check() != nil {
+		t.Fatalf("client = %+v", client)
+	}
+}
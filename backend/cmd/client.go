@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// defaultServer 运维子命令默认连接的实例地址
+const defaultServer = "http://localhost:1108"
+
+// adminClient 运行中实例的管理接口客户端，供warm、purge、stats等运维子命令使用
+type adminClient struct {
+	server string
+	token  string
+}
+
+// adminFlags 注册管理接口地址和令牌参数，未指定时读取环境变量
+func adminFlags(fs *flag.FlagSet) *adminClient {
+	client := &adminClient{}
+	server := os.Getenv("STATIC_MIRRORS_SERVER")
+	if server == "" {
+		server = defaultServer
+	}
+	fs.StringVar(&client.server, "server", server, "运行中实例的地址（默认读取 STATIC_MIRRORS_SERVER）")
+	fs.StringVar(&client.token, "token", os.Getenv("STATIC_MIRRORS_TOKEN"), "管理接口令牌（默认读取 STATIC_MIRRORS_TOKEN）")
+	return client
+}
+
+// check 检查是否配置了管理接口令牌
+func (c *adminClient) check() error {
+	if c.token == "" {
+		return errors.New("缺少管理接口令牌，请使用 -token 或设置 STATIC_MIRRORS_TOKEN")
+	}
+	return nil
+}
+
+// do 调用管理接口，body不为nil时以JSON提交，非200响应返回错误，调用方负责关闭响应体
+func (c *adminClient) do(method string, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimRight(c.server, "/")+"/api/admin"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+// call 调用管理接口并把JSON响应解析到out
+func (c *adminClient) call(method string, path string, body interface{}, out interface{}) error {
+	resp, err := c.do(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"static-mirrors/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// URL批量转换限制
+const (
+	maxConvertURLs  = 1000
+	maxConvertHTML  = 5 << 20
+	htmlContentType = "text/html"
+)
+
+// processURLRequest URL转换请求，url为单个地址（兼容旧接口），urls为批量地址，html为需要改写的HTML文档
+type processURLRequest struct {
+	URL     string   `json:"url" form:"url"`
+	URLs    []string `json:"urls" form:"urls"`
+	HTML    string   `json:"html" form:"html"`
+	BaseURL string   `json:"base_url" form:"base_url"`
+}
+
+// handleProcessURL 将源站URL转换为镜像地址，支持单个URL、批量URL和HTML文档
+// HTML文档可以放在JSON的html字段、以text/html请求体提交，或以multipart的file字段上传index.html
+func handleProcessURL(c *gin.Context) {
+	req, err := bindProcessURLRequest(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.URL == "" && len(req.URLs) == 0 && req.HTML == "" {
+		c.JSON(400, gin.H{"error": "无效的URL"})
+		return
+	}
+	if len(req.URLs) > maxConvertURLs {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("单次最多转换%d个URL", maxConvertURLs)})
+		return
+	}
+	if len(req.HTML) > maxConvertHTML {
+		c.JSON(413, gin.H{"error": "HTML文档过大"})
+		return
+	}
+
+	baseURL := strings.TrimRight(req.BaseURL, "/")
+
+	// 单个URL保持原有的响应格式
+	if req.URL != "" && len(req.URLs) == 0 && req.HTML == "" {
+		result := proxyService.ConvertURL(req.URL, baseURL)
+		if !result.OK() {
+			c.JSON(400, gin.H{
+				"error":        result.Error,
+				"original_url": req.URL,
+				"status":       result.Status,
+			})
+			return
+		}
+		c.JSON(200, gin.H{
+			"original_url":    result.Original,
+			"accelerated_url": result.Mirror,
+			"source":          result.Source,
+			"message":         "URL处理成功",
+		})
+		return
+	}
+
+	urls := req.URLs
+	if req.URL != "" {
+		urls = append([]string{req.URL}, urls...)
+	}
+
+	response := gin.H{"message": "URL处理成功"}
+	results := make([]proxy.URLConversion, 0, len(urls))
+	for _, u := range urls {
+		results = append(results, proxyService.ConvertURL(u, baseURL))
+	}
+
+	if req.HTML != "" {
+		document, htmlResults := proxyService.RewriteHTML(req.HTML, baseURL)
+		results = append(results, htmlResults...)
+		response["html"] = document
+	}
+
+	converted := 0
+	for _, result := range results {
+		if result.OK() {
+			converted++
+		}
+	}
+	response["results"] = results
+	response["total"] = len(results)
+	response["converted"] = converted
+	response["unsupported"] = len(results) - converted
+
+	c.JSON(200, response)
+}
+
+// bindProcessURLRequest 按请求类型解析转换请求
+func bindProcessURLRequest(c *gin.Context) (processURLRequest, error) {
+	var req processURLRequest
+
+	switch c.ContentType() {
+	case htmlContentType:
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxConvertHTML+1))
+		if err != nil {
+			return req, fmt.Errorf("读取HTML文档失败")
+		}
+		req.HTML = string(body)
+		req.BaseURL = c.Query("base_url")
+		return req, nil
+
+	case gin.MIMEMultipartPOSTForm:
+		if err := c.ShouldBind(&req); err != nil {
+			return req, fmt.Errorf("无效的请求参数")
+		}
+		file, err := c.FormFile("file")
+		if err == http.ErrMissingFile {
+			return req, nil
+		}
+		if err != nil {
+			return req, fmt.Errorf("读取上传文件失败")
+		}
+		if file.Size > maxConvertHTML {
+			return req, fmt.Errorf("HTML文档过大")
+		}
+		f, err := file.Open()
+		if err != nil {
+			return req, fmt.Errorf("读取上传文件失败")
+		}
+		defer f.Close()
+		body, err := io.ReadAll(f)
+		if err != nil {
+			return req, fmt.Errorf("读取上传文件失败")
+		}
+		req.HTML = string(body)
+		return req, nil
+
+	default:
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return req, fmt.Errorf("无效的URL")
+		}
+		return req, nil
+	}
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"log"
-	"net/url"
+	"math"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"static-mirrors/internal/admin"
 	"static-mirrors/internal/cache"
+	"static-mirrors/internal/outbound"
 	"static-mirrors/internal/proxy"
+	"static-mirrors/internal/server"
 	"static-mirrors/internal/stats"
 	"static-mirrors/pkg/config"
+	"static-mirrors/pkg/logging"
+	"static-mirrors/pkg/metrics"
 	"static-mirrors/pkg/utils"
 
 	"github.com/gin-gonic/gin"
@@ -24,34 +31,70 @@ var (
 	cacheService cache.Cache
 	statsService stats.Stats
 	adminService *admin.Admin
+	accessLog    *logging.AccessLog
+	metricsSrv   *http.Server
 )
 
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// runServe 执行serve子命令：加载配置并启动镜像服务，直到收到退出信号
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := configFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: static-mirrors serve [参数]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
 	// 加载配置文件
-	configPath := "../../config/config.yaml"
-	if err := config.LoadConfig(configPath); err != nil {
-		log.Fatalf("加载配置文件失败: %v", err)
+	if err := config.LoadConfig(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置文件失败: %v\n", err)
+		return 1
 	}
 
 	// 获取配置
 	cfg := config.GetConfig()
 
+	// 按日志配置初始化日志，之后的日志都使用结构化格式
+	logger := logging.Setup(cfg.Log)
+	logger.Info("配置文件加载成功", "path", *configPath)
+
 	// 初始化缓存服务
 	var err error
-	if cacheService, err = cache.NewCache(cfg); err != nil {
-		log.Printf("初始化缓存服务失败: %v，将禁用缓存", err)
+	if cacheService, err = cache.NewCache(cfg, logger.With("component", "cache")); err != nil {
+		logger.Warn("初始化缓存服务失败，将禁用缓存", "error", err)
+		cacheService = nil
 	}
 
 	// 初始化统计服务
-	if statsService, err = stats.NewStats(cfg); err != nil {
-		log.Printf("初始化统计服务失败: %v，将禁用统计", err)
+	if statsService, err = stats.NewStats(cfg, logger.With("component", "stats")); err != nil {
+		logger.Warn("初始化统计服务失败，将禁用统计", "error", err)
+		statsService = nil
 	}
 
 	// 初始化反代服务
-	proxyService = proxy.NewProxy(cfg)
+	proxyService = proxy.NewProxy(cfg, cacheService, logger.With("component", "proxy"))
 
 	// 初始化后台管理服务
-	adminService = admin.NewAdmin(cfg)
+	adminService = admin.NewAdmin(cfg, proxyService, statsService, logger.With("component", "admin"))
+
+	// 初始化访问日志
+	if cfg.Log.Access.Enabled {
+		if accessLog, err = logging.NewAccessLog(cfg.Log.Access, logger.With("component", "access_log")); err != nil {
+			logger.Error("初始化访问日志失败", "error", err)
+			return 1
+		}
+		logger.Info("访问日志已启用", "path", cfg.Log.Access.Path, "format", cfg.Log.Access.Format)
+	}
 
 	// 设置Gin模式
 	if cfg.App.Debug {
@@ -60,8 +103,21 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// 创建Gin引擎
-	r := gin.Default()
+	// 创建Gin引擎，请求日志由logging中间件按日志配置输出
+	r := gin.New()
+	// 只采用可信代理转发的客户端地址，否则任何人都可以通过X-Forwarded-For绕过速率限制
+	if err := r.SetTrustedProxies(cfg.App.TrustedProxies); err != nil {
+		logger.Error("设置可信代理失败", "error", err)
+		return 1
+	}
+	r.Use(logging.Middleware(logger))
+	if accessLog != nil {
+		r.Use(accessLog.Middleware())
+	}
+	if cfg.Metrics.Enabled {
+		r.Use(metrics.Middleware())
+	}
+	r.Use(utils.RecoveryMiddleware())
 
 	// 添加中间件
 	r.Use(utils.PerformanceMiddleware())
@@ -71,20 +127,70 @@ func main() {
 	// 添加CDN缓存配置中间件
 	r.Use(utils.CDNCacheMiddleware())
 
-	// 添加速率限制中间件（每分钟60个请求）
-	r.Use(utils.RateLimitMiddleware(60, time.Minute))
+	// 添加速率限制中间件，按 security.rate_limit 配置，支持热更新
+	r.Use(utils.RateLimitMiddleware(rateLimit, time.Minute))
 
 	// 注册路由
 	registerRoutes(r)
 
+	// Prometheus指标，未配置单独的监听地址时在服务端口上提供
+	if cfg.Metrics.Enabled {
+		registerGauges()
+		if cfg.Metrics.Listen == "" {
+			r.GET(metricsPath(cfg.Metrics), metrics.Handler(cfg.Metrics.Token))
+			logger.Info("指标已启用", "path", metricsPath(cfg.Metrics))
+		} else {
+			if metricsSrv, err = startMetricsServer(cfg.Metrics, logger.With("component", "metrics")); err != nil {
+				logger.Error("启动指标服务失败", "addr", cfg.Metrics.Listen, "error", err)
+				return 1
+			}
+			logger.Info("指标服务已启动", "addr", cfg.Metrics.Listen, "path", metricsPath(cfg.Metrics))
+		}
+	}
+
 	// 启动服务器
-	serverAddr := fmt.Sprintf("%s:%d", cfg.App.Host, cfg.App.Port)
-	log.Printf("服务器启动在 %s", serverAddr)
+	srv, err := server.New(cfg.App, r, logger.With("component", "server"))
+	if err != nil {
+		logger.Error("初始化服务器失败", "error", err)
+		return 1
+	}
+	logger.Info("服务器已启动", "addr", srv.Addr(), "tls", srv.TLS())
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	// 配置文件变化或收到SIGHUP时热更新配置
+	watchConfig()
+
+	// 收到SIGINT或SIGTERM后优雅关闭
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errCh:
+		if err != nil {
+			logger.Error("启动服务器失败", "error", err)
+			return 1
+		}
+	case sig := <-quit:
+		logger.Info("收到信号，停止接收新连接", "signal", sig.String())
+		// 读取当前配置，热更新后的shutdown_timeout同样生效
+		shutdown(srv, time.Duration(config.GetConfig().App.ShutdownTimeout)*time.Second)
+	}
+	return 0
+}
+
+// latencyTestsPerMinute 每个客户端每分钟允许的延迟测试次数
+const latencyTestsPerMinute = 5
 
-	if err := r.Run(serverAddr); err != nil {
-		log.Fatalf("启动服务器失败: %v", err)
-		os.Exit(1)
+// rateLimit 返回当前配置下每个客户端每分钟允许的请求数，未启用速率限制时返回0
+func rateLimit() int {
+	limit := config.GetConfig().Security.RateLimit
+	if !limit.Enabled {
+		return 0
 	}
+	return limit.RequestsPerMinute
 }
 
 // registerRoutes 注册所有路由
@@ -116,30 +222,14 @@ func registerRoutes(r *gin.Engine) {
 	api := r.Group("/api")
 	{
 		// URL处理API
-		api.POST("/process-url", func(c *gin.Context) {
-			var req struct {
-				URL string `json:"url" binding:"required"`
-			}
+		api.POST("/process-url", handleProcessURL)
 
-			if err := c.ShouldBindJSON(&req); err != nil {
-				c.JSON(400, gin.H{"error": "无效的URL"})
-				return
-			}
-
-			// 生成加速后的URL
-			acceleratedURL := fmt.Sprintf("/mirror?url=%s", url.QueryEscape(req.URL))
-
-			c.JSON(200, gin.H{
-				"original_url":    req.URL,
-				"accelerated_url": acceleratedURL,
-				"message":         "URL处理成功",
-			})
-		})
-
-		// 延迟测试API
-		api.POST("/test-latency", func(c *gin.Context) {
+		// 延迟测试API，samples为采样次数（默认1次，最多5次）
+		// 每次测试会向源站发起多次请求，单独限制每个客户端的频率，避免被用来放大对源站的请求
+		api.POST("/test-latency", utils.RateLimitMiddleware(func() int { return latencyTestsPerMinute }, time.Minute), func(c *gin.Context) {
 			var req struct {
-				URL string `json:"url" binding:"required"`
+				URL     string `json:"url" binding:"required"`
+				Samples int    `json:"samples"`
 			}
 
 			if err := c.ShouldBindJSON(&req); err != nil {
@@ -148,34 +238,39 @@ func registerRoutes(r *gin.Engine) {
 			}
 
 			// 测试原地址延迟
-			originalLatency, err := proxyService.TestLatency(req.URL)
+			original, err := proxyService.MeasureLatency(c.Request.Context(), req.URL, req.Samples)
+			if outbound.IsBlocked(err) {
+				c.JSON(403, gin.H{"error": "不允许测试该地址", "details": err.Error()})
+				return
+			}
 			if err != nil {
 				c.JSON(500, gin.H{"error": "测试原地址延迟失败", "details": err.Error()})
 				return
 			}
 
-			// 生成加速后的URL
-			acceleratedURL := fmt.Sprintf("http://localhost:1108/mirror?url=%s", url.QueryEscape(req.URL))
-
-			// 测试加速后地址延迟
-			acceleratedLatency, err := proxyService.TestLatency(acceleratedURL)
+			// 在进程内测量镜像处理耗时（缓存查询、回源和传输）
+			accelerated, err := proxyService.MeasureMirror(c.Request.Context(), req.URL, req.Samples)
 			if err != nil {
 				c.JSON(500, gin.H{"error": "测试加速后地址延迟失败", "details": err.Error()})
 				return
 			}
 
-			// 计算改进百分比
+			// 使用中位数计算改进百分比
+			originalLatency := original.Total.Median
+			acceleratedLatency := accelerated.Total.Median
 			improvement := float64(0)
 			if originalLatency > 0 {
-				improvement = (float64(originalLatency) - float64(acceleratedLatency)) / float64(originalLatency) * 100
+				improvement = (originalLatency - acceleratedLatency) / originalLatency * 100
 			}
 
 			c.JSON(200, gin.H{
 				"original_url":        req.URL,
-				"accelerated_url":     acceleratedURL,
-				"original_latency":    originalLatency,
-				"accelerated_latency": acceleratedLatency,
+				"accelerated_url":     accelerated.MirrorPath,
+				"original_latency":    int64(math.Round(originalLatency)),
+				"accelerated_latency": int64(math.Round(acceleratedLatency)),
 				"improvement":         fmt.Sprintf("%.1f%%", improvement),
+				"original":            original,
+				"accelerated":         accelerated,
 			})
 		})
 
@@ -253,12 +348,18 @@ func registerRoutes(r *gin.Engine) {
 		})
 	}
 
+	// 镜像模式 - 通过url参数指定源站完整地址
+	r.Any("/mirror", func(c *gin.Context) {
+		proxyService.HandleMirror(c)
+	})
+
 	// 路径代理模式 - 支持直接路径访问（不包含域名）
-	r.Any("/*proxyPath", func(c *gin.Context) {
+	// gin不允许根路径的通配路由与其他路由共存，因此放在NoRoute中处理
+	r.NoRoute(func(c *gin.Context) {
 		start := time.Now()
 
 		// 获取代理路径
-		proxyPath := c.Param("proxyPath")
+		proxyPath := c.Request.URL.Path
 
 		// 如果路径为空或根路径，返回前端页面
 		if proxyPath == "" || proxyPath == "/" {
@@ -266,31 +367,25 @@ func registerRoutes(r *gin.Engine) {
 			return
 		}
 
+		// API、健康检查、缓存刷新和静态资源的未知路径返回404
+		if strings.HasPrefix(proxyPath, "/api") ||
+			strings.HasPrefix(proxyPath, "/health") ||
+			strings.HasPrefix(proxyPath, "/purge") ||
+			strings.HasPrefix(proxyPath, "/assets") {
+			c.JSON(404, gin.H{"error": "Page not found"})
+			return
+		}
+
 		// 处理路径代理请求
 		proxyService.HandlePathProxy(c, proxyPath)
 
-		// 记录访问统计
+		// 记录访问统计，被拒绝的请求没有回源目标，不计入统计
 		if statsService != nil {
-			duration := time.Since(start)
-			bytes := int64(0)
-			targetURL := fmt.Sprintf("https://cdn.jsdelivr.net%s", proxyPath)
-			statsService.RecordRequest(targetURL, "cdn.jsdelivr.net", bytes, duration)
-		}
-	})
-
-	// 前端页面服务 - 必须放在最后
-	r.NoRoute(func(c *gin.Context) {
-		// 如果不是API请求、健康检查或缓存刷新，返回前端页面
-		if !strings.HasPrefix(c.Request.URL.Path, "/api") &&
-			!strings.HasPrefix(c.Request.URL.Path, "/health") &&
-			!strings.HasPrefix(c.Request.URL.Path, "/purge") &&
-			!strings.HasPrefix(c.Request.URL.Path, "/assets") {
-			c.File("./frontend/dist/index.html")
-			return
+			if host, targetURL, ok := proxy.Target(c); ok {
+				bytes := int64(max(c.Writer.Size(), 0))
+				statsService.RecordRequest(targetURL, host, bytes, time.Since(start))
+			}
 		}
-
-		// 否则返回404
-		c.JSON(404, gin.H{"error": "Page not found"})
 	})
 }
 
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"static-mirrors/internal/cache"
+	"static-mirrors/internal/proxy"
+	"static-mirrors/pkg/config"
+	"static-mirrors/pkg/logging"
+	"static-mirrors/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMetricsPath 默认的指标路径
+const defaultMetricsPath = "/metrics"
+
+// breakerStates 熔断器状态指标输出的所有状态，当前状态取值为1，其余为0
+var breakerStates = []string{proxy.BreakerClosed, proxy.BreakerOpen, proxy.BreakerHalfOpen}
+
+// registerGauges 注册抓取时从各服务读取的指标：缓存条目数和熔断器状态
+// 缓存条目数只对能统计本服务条目数的缓存（内存缓存）输出
+func registerGauges() {
+	if counter, ok := cacheService.(cache.Counter); ok {
+		metrics.Default.NewGaugeFunc("static_mirrors_cache_entries", "缓存中的条目数", nil, func() []metrics.Sample {
+			n, err := counter.Len()
+			if err != nil {
+				return nil
+			}
+			return []metrics.Sample{{Value: float64(n)}}
+		})
+	}
+
+	metrics.Default.NewGaugeFunc("static_mirrors_circuit_breaker_state",
+		"源站熔断器状态，当前状态取值为1", []string{"source", "state"}, func() []metrics.Sample {
+			var samples []metrics.Sample
+			for _, status := range proxyService.BreakerStatuses() {
+				for _, state := range breakerStates {
+					value := 0.0
+					if status.State == state {
+						value = 1
+					}
+					samples = append(samples, metrics.Sample{LabelValues: []string{status.Source, state}, Value: value})
+				}
+			}
+			return samples
+		})
+}
+
+// metricsPath 返回配置的指标路径
+func metricsPath(cfg config.MetricsConfig) string {
+	if cfg.Path == "" {
+		return defaultMetricsPath
+	}
+	return cfg.Path
+}
+
+// startMetricsServer 在单独的地址上提供指标，监听失败时返回错误
+func startMetricsServer(cfg config.MetricsConfig, logger *slog.Logger) (*http.Server, error) {
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, err
+	}
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET(metricsPath(cfg), metrics.Handler(cfg.Token))
+
+	srv := &http.Server{
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          logging.StdLogger(logger, slog.LevelWarn),
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("指标服务异常退出", "error", err)
+		}
+	}()
+	return srv, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// purgeBatchSize 每次提交的URL数量，与管理接口的上限一致
+const purgeBatchSize = 1000
+
+// runPurge 执行purge子命令：通过运行中实例的管理接口删除缓存，不受公开刷新接口的频率限制
+func runPurge(args []string) int {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	client := adminFlags(fs)
+	listPath := fs.String("file", "", "URL列表文件，每行一个源站URL或镜像路径，\"-\"表示标准输入")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: static-mirrors purge [参数] [源站URL|镜像路径...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	targets := fs.Args()
+	if *listPath != "" {
+		urls, err := readURLList(*listPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取URL列表失败: %v\n", err)
+			return 1
+		}
+		targets = append(targets, urls...)
+	}
+	if len(targets) == 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := client.check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	failed := 0
+	for start := 0; start < len(targets); start += purgeBatchSize {
+		end := start + purgeBatchSize
+		if end > len(targets) {
+			end = len(targets)
+		}
+
+		var resp struct {
+			Results []struct {
+				Target string `json:"target"`
+				URL    string `json:"url"`
+				Error  string `json:"error"`
+			} `json:"results"`
+		}
+		if err := client.call(http.MethodPost, "/purge", map[string][]string{"urls": targets[start:end]}, &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "刷新请求失败: %v\n", err)
+			return 1
+		}
+
+		for _, result := range resp.Results {
+			if result.Error != "" {
+				failed++
+				fmt.Printf("FAIL %s  %s\n", result.Target, result.Error)
+				continue
+			}
+			fmt.Printf("OK   %s\n", result.URL)
+		}
+	}
+
+	fmt.Printf("完成: 共 %d 个，失败 %d 个\n", len(targets), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
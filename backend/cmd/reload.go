@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"static-mirrors/pkg/config"
+	"static-mirrors/pkg/logging"
+)
+
+// watchConfig 配置文件变化或收到SIGHUP时重新加载配置
+func watchConfig() {
+	config.Watch(reloadConfig)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			slog.Info("收到SIGHUP，重新加载配置")
+			reloadConfig()
+		}
+	}()
+}
+
+// reloadConfig 重新加载配置并应用到各服务，配置无效或修改了需要重启的配置项时继续使用当前配置
+// 文件变化和SIGHUP可能同时触发，config.Reload保证按顺序应用配置
+func reloadConfig() {
+	changes, err := config.Reload(applyConfig)
+	if err != nil {
+		slog.Error("重新加载配置失败，继续使用当前配置", "error", err)
+		return
+	}
+	if len(changes) == 0 {
+		slog.Info("配置没有变化")
+	}
+}
+
+// applyConfig 将热更新后的配置应用到各服务
+func applyConfig(cfg config.Config, changes []config.Change) {
+	logging.SetLevel(cfg.Log.Level)
+	if accessLog != nil {
+		accessLog.SetSampling(cfg.Log.Access.Sampling)
+	}
+	proxyService.Reload(cfg)
+	if adminService != nil {
+		adminService.Reload(cfg)
+	}
+	for _, change := range changes {
+		slog.Info("配置已更新", "key", change.Key, "old", change.Old, "new", change.New)
+	}
+}
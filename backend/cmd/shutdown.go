@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"static-mirrors/internal/server"
+)
+
+// 优雅关闭的默认等待时间，以及中止下载后等待回源请求退出的时间
+const (
+	defaultShutdownTimeout = 30 * time.Second
+	abortTimeout           = 5 * time.Second
+)
+
+// shutdown 等待进行中的请求在drain时间内完成，超时后中止未完成的下载（不写入缓存），最后关闭统计服务、访问日志和指标服务
+func shutdown(srv *server.Server, drain time.Duration) {
+	if drain <= 0 {
+		drain = defaultShutdownTimeout
+	}
+	slog.Info("等待进行中的请求完成", "timeout", drain.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("等待请求完成超时，中止未完成的下载", "error", err)
+		srv.Close()
+	}
+
+	// 中止剩余的回源请求，包括不属于任何连接的后台回源（缓存预热、过期刷新）
+	abortCtx, abortCancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer abortCancel()
+	if err := proxyService.Abort(abortCtx); err != nil {
+		slog.Warn("等待回源请求退出超时", "error", err)
+	}
+
+	if statsService != nil {
+		if err := statsService.Close(); err != nil {
+			slog.Error("关闭统计服务失败", "error", err)
+		}
+	}
+	if accessLog != nil {
+		if err := accessLog.Close(); err != nil {
+			slog.Error("关闭访问日志失败", "error", err)
+		}
+	}
+	if metricsSrv != nil {
+		metricsSrv.Close()
+	}
+	slog.Info("服务器已关闭")
+}
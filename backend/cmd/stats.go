@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// statsExport 管理接口导出的统计数据
+type statsExport struct {
+	GeneratedAt   string   `json:"generated_at"`
+	TotalRequests int64    `json:"total_requests"`
+	TotalTraffic  int64    `json:"total_traffic"`
+	TodayRequests int64    `json:"today_requests"`
+	TodayTraffic  int64    `json:"today_traffic"`
+	TopSources    []string `json:"top_sources"`
+}
+
+// runStats 执行stats子命令，目前只支持export
+func runStats(args []string) int {
+	if len(args) == 0 || args[0] != "export" {
+		fmt.Fprintln(os.Stderr, "用法: static-mirrors stats export [参数]")
+		return 2
+	}
+	return runStatsExport(args[1:])
+}
+
+// runStatsExport 通过运行中实例的管理接口导出访问统计，输出JSON或CSV
+func runStatsExport(args []string) int {
+	fs := flag.NewFlagSet("stats export", flag.ContinueOnError)
+	client := adminFlags(fs)
+	format := fs.String("format", "json", "输出格式: json 或 csv")
+	output := fs.String("output", "-", "输出文件，\"-\"表示标准输出")
+	noHeader := fs.Bool("no-header", false, "CSV不输出表头，便于定期追加到同一文件")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: static-mirrors stats export [参数]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 || (*format != "json" && *format != "csv") {
+		fs.Usage()
+		return 2
+	}
+	if err := client.check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var data statsExport
+	if err := client.call(http.MethodGet, "/stats/export", nil, &data); err != nil {
+		fmt.Fprintf(os.Stderr, "获取统计数据失败: %v\n", err)
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.OpenFile(*output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "打开输出文件失败: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var err error
+	if *format == "csv" {
+		err = writeStatsCSV(w, data, !*noHeader)
+	} else {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(data)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "写入统计数据失败: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeStatsCSV 以一行CSV输出统计数据，热门源站以空格分隔
+func writeStatsCSV(w io.Writer, data statsExport, header bool) error {
+	cw := csv.NewWriter(w)
+	if header {
+		cw.Write([]string{"generated_at", "total_requests", "total_traffic", "today_requests", "today_traffic", "top_sources"})
+	}
+	cw.Write([]string{
+		data.GeneratedAt,
+		strconv.FormatInt(data.TotalRequests, 10),
+		strconv.FormatInt(data.TotalTraffic, 10),
+		strconv.FormatInt(data.TodayRequests, 10),
+		strconv.FormatInt(data.TodayTraffic, 10),
+		strings.Join(data.TopSources, " "),
+	})
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"static-mirrors/internal/proxy"
+	"static-mirrors/internal/warmup"
+)
+
+// runWarm 执行warm子命令：读取URL列表，通过运行中实例的管理接口预热缓存
+func runWarm(args []string) int {
+	fs := flag.NewFlagSet("warm", flag.ContinueOnError)
+	client := adminFlags(fs)
+	concurrency := fs.Int("concurrency", warmup.DefaultConcurrency, "并发数")
+	refresh := fs.Bool("refresh", false, "忽略已有缓存，强制回源刷新")
+	statePath := fs.String("state", "", "进度文件，记录已完成的URL，中断后重新执行会跳过这些URL")
+	batchSize := fs.Int("batch", 500, "每次提交的URL数量")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: static-mirrors warm [参数] <URL列表文件|->")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if err := client.check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	urls, err := readURLList(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取URL列表失败: %v\n", err)
+		return 1
+	}
+
+	// 跳过进度文件中已完成的URL
+	var state *os.File
+	if *statePath != "" {
+		done, err := readURLList(*statePath)
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "读取进度文件失败: %v\n", err)
+			return 1
+		}
+		urls = excludeURLs(urls, done)
+
+		state, err = os.OpenFile(*statePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "打开进度文件失败: %v\n", err)
+			return 1
+		}
+		defer state.Close()
+	}
+
+	if len(urls) == 0 {
+		fmt.Println("没有需要预热的URL")
+		return 0
+	}
+	if *batchSize <= 0 {
+		*batchSize = 500
+	}
+
+	total := warmup.Summary{}
+	for start := 0; start < len(urls); start += *batchSize {
+		end := start + *batchSize
+		if end > len(urls) {
+			end = len(urls)
+		}
+
+		summary, err := postWarmBatch(client, urls[start:end], warmup.Options{
+			Concurrency: *concurrency,
+			Refresh:     *refresh,
+		}, func(result proxy.PrefetchResult) {
+			printPrefetchResult(result)
+			if state != nil && result.OK() {
+				fmt.Fprintln(state, result.URL)
+			}
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "预热请求失败: %v\n", err)
+			return 1
+		}
+
+		total.Total += summary.Total
+		total.Succeeded += summary.Succeeded
+		total.Failed += summary.Failed
+		total.Cached += summary.Cached
+		total.Bytes += summary.Bytes
+		total.Duration += summary.Duration
+	}
+
+	fmt.Printf("完成: 共 %d 个，成功 %d 个（已缓存 %d 个），失败 %d 个，流量 %s，耗时 %dms\n",
+		total.Total, total.Succeeded, total.Cached, total.Failed, formatBytes(total.Bytes), total.Duration)
+
+	if total.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// postWarmBatch 提交一批URL到管理接口，并逐行处理返回的预热结果
+func postWarmBatch(client *adminClient, urls []string, opts warmup.Options, report func(proxy.PrefetchResult)) (warmup.Summary, error) {
+	var summary warmup.Summary
+
+	resp, err := client.do(http.MethodPost, "/warm", struct {
+		URLs []string `json:"urls"`
+		warmup.Options
+	}{URLs: urls, Options: opts})
+	if err != nil {
+		return summary, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var line struct {
+			Result  *proxy.PrefetchResult `json:"result"`
+			Summary *warmup.Summary       `json:"summary"`
+		}
+		if err := decoder.Decode(&line); err == io.EOF {
+			return summary, fmt.Errorf("响应在汇总之前结束")
+		} else if err != nil {
+			return summary, err
+		}
+
+		if line.Result != nil {
+			report(*line.Result)
+		}
+		if line.Summary != nil {
+			return *line.Summary, nil
+		}
+	}
+}
+
+// printPrefetchResult 输出单个URL的预热结果
+func printPrefetchResult(result proxy.PrefetchResult) {
+	if !result.OK() {
+		fmt.Printf("FAIL %3d %-5s %10s %6dms %s  %s\n",
+			result.Status, result.Cache, formatBytes(result.Size), result.Duration, result.URL, result.Error)
+		return
+	}
+	fmt.Printf("OK   %3d %-5s %10s %6dms %s\n",
+		result.Status, result.Cache, formatBytes(result.Size), result.Duration, result.URL)
+}
+
+// readURLList 读取URL列表，忽略空行和#开头的注释，"-"表示标准输入
+func readURLList(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// excludeURLs 从列表中去掉已完成的URL
+func excludeURLs(urls []string, done []string) []string {
+	if len(done) == 0 {
+		return urls
+	}
+
+	skip := make(map[string]bool, len(done))
+	for _, u := range done {
+		skip[u] = true
+	}
+
+	remaining := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if !skip[u] {
+			remaining = append(remaining, u)
+		}
+	}
+	return remaining
+}
@@ -1,35 +1,72 @@
 package admin
 
 import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"static-mirrors/internal/proxy"
+	"static-mirrors/internal/stats"
+	"static-mirrors/internal/warmup"
 	"static-mirrors/pkg/config"
+	"static-mirrors/pkg/logging"
 
 	"github.com/gin-gonic/gin"
 )
 
 // Admin 后台管理结构
 type Admin struct {
-	config config.Config
+	proxy  *proxy.Proxy
+	stats  stats.Stats
+	warmer *warmup.Warmer
+	logger *slog.Logger
+	// token 管理接口令牌（security.admin_token），热更新后立即生效
+	token atomic.Value
 	// 这里可以添加其他依赖，如数据库连接等
 }
 
-// NewAdmin 创建新的后台管理实例
-func NewAdmin(cfg config.Config) *Admin {
-	return &Admin{
-		config: cfg,
+// NewAdmin 创建新的后台管理实例，s为nil时统计导出接口不可用
+func NewAdmin(cfg config.Config, p *proxy.Proxy, s stats.Stats, logger *slog.Logger) *Admin {
+	a := &Admin{
+		proxy:  p,
+		stats:  s,
+		logger: logger,
 	}
+	a.token.Store(cfg.Security.AdminToken)
+	if p != nil {
+		a.warmer = warmup.NewWarmer(p, cfg)
+	}
+	return a
+}
+
+// Reload 应用热更新后的配置
+func (a *Admin) Reload(cfg config.Config) {
+	a.token.Store(cfg.Security.AdminToken)
+	if a.warmer != nil {
+		a.warmer.Reload(cfg)
+	}
+}
+
+// log 返回带有请求ID的日志记录器
+func (a *Admin) log(c *gin.Context) *slog.Logger {
+	return logging.For(c, a.logger)
 }
 
+// maxWarmURLs 单次预热请求允许的最大URL数量
+const maxWarmURLs = 10000
+
+// maxPurgeURLs 单次缓存刷新请求允许的最大URL数量
+const maxPurgeURLs = 1000
+
 // RegisterRoutes 注册后台管理路由
 func (a *Admin) RegisterRoutes(r *gin.RouterGroup) {
 	admin := r.Group("/admin")
 	{
-		// 登录路由
-		admin.POST("/login", a.login)
-
-		// 需要认证的路由
+		// 所有管理接口都需要认证
 		auth := admin.Group("/")
 		auth.Use(a.authMiddleware())
 		{
@@ -43,42 +80,44 @@ func (a *Admin) RegisterRoutes(r *gin.RouterGroup) {
 
 			// 访问统计
 			auth.GET("/stats", a.getStats)
+			auth.GET("/stats/export", a.exportStats)
 
 			// 系统状态
 			auth.GET("/system", a.getSystemStatus)
-		}
-	}
-}
 
-// login 登录处理
-func (a *Admin) login(c *gin.Context) {
-	var req struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
-	}
+			// 源站熔断状态
+			auth.GET("/circuit-breakers", a.getCircuitBreakers)
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
-		return
-	}
+			// 离线模式
+			auth.GET("/offline", a.getOffline)
+			auth.PUT("/offline", a.setOffline)
+
+			// 缓存刷新
+			auth.POST("/purge", a.purge)
 
-	// 这里应该实现实际的认证逻辑
-	// 为了演示，我们使用简单的硬编码验证
-	if req.Username == "admin" && req.Password == "admin123" {
-		c.JSON(http.StatusOK, gin.H{
-			"token":   "dummy-token",
-			"message": "登录成功",
-		})
-	} else {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+			// 缓存预热
+			auth.POST("/warm", a.warm)
+			auth.POST("/warm/package", a.warmPackage)
+			auth.GET("/warm/jobs", a.getWarmJobs)
+			auth.GET("/warm/jobs/:id", a.getWarmJob)
+			auth.DELETE("/warm/jobs/:id", a.cancelWarmJob)
+		}
 	}
 }
 
-// authMiddleware 认证中间件
+// authMiddleware 认证中间件，要求 Authorization: Bearer <security.admin_token>
+// 未配置令牌时拒绝所有请求
 func (a *Admin) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token == "" || token != "Bearer dummy-token" {
+		token := a.token.Load().(string)
+		if token == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "管理接口未启用，请配置 security.admin_token"})
+			c.Abort()
+			return
+		}
+		if !validToken(c.GetHeader("Authorization"), token) {
+			a.log(c).Warn("管理接口认证失败", "path", c.Request.URL.Path, "client_ip", c.ClientIP())
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
 			c.Abort()
 			return
@@ -87,6 +126,15 @@ func (a *Admin) authMiddleware() gin.HandlerFunc {
 	}
 }
 
+// validToken 按固定时间比较令牌，避免通过响应时间猜测令牌
+func validToken(header string, token string) bool {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || header[:len(prefix)] != prefix {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(header[len(prefix):]), []byte(token)) == 1
+}
+
 // getDashboard 获取仪表盘数据
 func (a *Admin) getDashboard(c *gin.Context) {
 	// 这里应该实现实际的仪表盘数据获取逻辑
@@ -225,6 +273,33 @@ func (a *Admin) getStats(c *gin.Context) {
 	})
 }
 
+// exportStats 导出统计服务中的实际统计数据
+func (a *Admin) exportStats(c *gin.Context) {
+	if a.stats == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "统计服务未启用"})
+		return
+	}
+
+	data, err := a.stats.GetStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取统计数据失败", "details": err.Error()})
+		return
+	}
+	topSources, err := a.stats.GetTopSources()
+	if err != nil || topSources == nil {
+		topSources = []string{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"generated_at":   time.Now().Format(time.RFC3339),
+		"total_requests": data["total_requests"],
+		"total_traffic":  data["total_traffic"],
+		"today_requests": data["today_requests"],
+		"today_traffic":  data["today_traffic"],
+		"top_sources":    topSources,
+	})
+}
+
 // getSystemStatus 获取系统状态
 func (a *Admin) getSystemStatus(c *gin.Context) {
 	// 这里应该实现实际的获取系统状态的逻辑
@@ -263,3 +338,218 @@ func (a *Admin) getSystemStatus(c *gin.Context) {
 		},
 	})
 }
+
+// getCircuitBreakers 获取各源站熔断器状态
+func (a *Admin) getCircuitBreakers(c *gin.Context) {
+	if a.proxy == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "反代服务未初始化"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":  config.GetConfig().Proxy.CircuitBreaker.Enabled,
+		"breakers": a.proxy.BreakerStatuses(),
+	})
+}
+
+// getOffline 获取离线模式状态
+func (a *Admin) getOffline(c *gin.Context) {
+	if a.proxy == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "反代服务未初始化"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"offline": a.proxy.Offline()})
+}
+
+// setOffline 切换离线模式
+func (a *Admin) setOffline(c *gin.Context) {
+	if a.proxy == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "反代服务未初始化"})
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	a.log(c).Info("通过管理接口切换离线模式", "offline", *req.Enabled)
+	a.proxy.SetOffline(*req.Enabled)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "离线模式已更新",
+		"offline": a.proxy.Offline(),
+	})
+}
+
+// purgeResult 单个URL的缓存刷新结果
+type purgeResult struct {
+	Target string `json:"target"`
+	URL    string `json:"url,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// purge 批量删除缓存内容，管理接口不受公开刷新接口的频率和次数限制
+func (a *Admin) purge(c *gin.Context) {
+	if a.proxy == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "反代服务未初始化"})
+		return
+	}
+
+	var req struct {
+		URLs []string `json:"urls" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	if len(req.URLs) == 0 || len(req.URLs) > maxPurgeURLs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL数量必须在1到1000之间"})
+		return
+	}
+
+	results := make([]purgeResult, 0, len(req.URLs))
+	failed := 0
+	for _, target := range req.URLs {
+		result := purgeResult{Target: strings.TrimSpace(target)}
+		targetURL, err := a.proxy.Purge(result.Target)
+		if err != nil {
+			result.Error = err.Error()
+			failed++
+		}
+		result.URL = targetURL
+		results = append(results, result)
+	}
+	a.log(c).Info("通过管理接口删除缓存", "total", len(results), "failed", failed)
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"total":   len(results),
+		"failed":  failed,
+	})
+}
+
+// warm 缓存预热，以NDJSON逐行返回每个URL的结果，最后一行为汇总
+func (a *Admin) warm(c *gin.Context) {
+	if a.warmer == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "反代服务未初始化"})
+		return
+	}
+
+	var req struct {
+		URLs []string `json:"urls" binding:"required"`
+		warmup.Options
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	urls := make([]string, 0, len(req.URLs))
+	for _, u := range req.URLs {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 || len(urls) > maxWarmURLs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL数量必须在1到10000之间"})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	summary := a.warmer.Run(c.Request.Context(), urls, req.Options, func(result proxy.PrefetchResult) {
+		_ = encoder.Encode(gin.H{"result": result})
+		c.Writer.Flush()
+	})
+	_ = encoder.Encode(gin.H{"summary": summary})
+}
+
+// warmPackage 启动后台任务预热整个包版本中匹配的文件
+func (a *Admin) warmPackage(c *gin.Context) {
+	if a.warmer == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "反代服务未初始化"})
+		return
+	}
+
+	var req struct {
+		warmup.Package
+		warmup.Options
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	job, err := a.warmer.StartPackage(req.Package, req.Options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a.log(c).Info("预热任务已创建", "job", job.Snapshot().ID, "package", req.Package.String())
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "预热任务已创建",
+		"job":     job.Snapshot(),
+	})
+}
+
+// getWarmJobs 获取预热任务列表
+func (a *Admin) getWarmJobs(c *gin.Context) {
+	if a.warmer == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "反代服务未初始化"})
+		return
+	}
+
+	jobs := a.warmer.Jobs()
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  jobs,
+		"total": len(jobs),
+	})
+}
+
+// getWarmJob 获取单个预热任务的进度
+func (a *Admin) getWarmJob(c *gin.Context) {
+	if a.warmer == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "反代服务未初始化"})
+		return
+	}
+
+	job, exists := a.warmer.Job(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "预热任务不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job.Snapshot()})
+}
+
+// cancelWarmJob 取消预热任务
+func (a *Admin) cancelWarmJob(c *gin.Context) {
+	if a.warmer == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "反代服务未初始化"})
+		return
+	}
+
+	job, exists := a.warmer.Job(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "预热任务不存在"})
+		return
+	}
+
+	job.Cancel()
+	a.log(c).Info("预热任务已取消", "job", c.Param("id"))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "预热任务已取消",
+		"job":     job.Snapshot(),
+	})
+}
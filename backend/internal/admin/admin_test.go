@@ -0,0 +1,106 @@
+package admin
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"static-mirrors/internal/proxy"
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestAdmin 创建使用指定管理令牌的管理接口
+func newTestAdmin(t *testing.T, token string) (*Admin, *gin.Engine) {
+	t.Helper()
+	cfg := config.Config{}
+	cfg.Security.AdminToken = token
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := NewAdmin(cfg, proxy.NewProxy(cfg, nil, logger), nil, logger)
+
+	r := gin.New()
+	a.RegisterRoutes(r.Group("/api"))
+	return a, r
+}
+
+// request 发起带Authorization请求头的请求
+func request(r http.Handler, method string, path string, authorization string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		status        int
+	}{
+		{"令牌正确", "secret", "Bearer secret", http.StatusOK},
+		{"缺少令牌", "secret", "", http.StatusUnauthorized},
+		{"令牌错误", "secret", "Bearer other", http.StatusUnauthorized},
+		{"旧的固定令牌", "secret", "Bearer dummy-token", http.StatusUnauthorized},
+		{"不是Bearer令牌", "secret", "secret", http.StatusUnauthorized},
+		{"未配置令牌", "", "Bearer ", http.StatusForbidden},
+		{"未配置令牌时任何令牌都无效", "", "Bearer dummy-token", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, r := newTestAdmin(t, tt.token)
+			for _, route := range []struct{ method, path, body string }{
+				{http.MethodGet, "/api/admin/warm/jobs", ""},
+				{http.MethodPost, "/api/admin/warm", `{"urls":[]}`},
+				{http.MethodPost, "/api/admin/warm/package", `{}`},
+				{http.MethodPost, "/api/admin/purge", `{}`},
+			} {
+				w := request(r, route.method, route.path, tt.authorization, route.body)
+				if tt.status == http.StatusOK {
+					// 通过认证后由处理函数校验参数
+					if w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden {
+						t.Fatalf("%s %s status = %d, want authorized", route.method, route.path, w.Code)
+					}
+					continue
+				}
+				if w.Code != tt.status {
+					t.Fatalf("%s %s status = %d, want %d", route.method, route.path, w.Code, tt.status)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthReload(t *testing.T) {
+	a, r := newTestAdmin(t, "old")
+	cfg := config.Config{}
+	cfg.Security.AdminToken = "new"
+	a.Reload(cfg)
+
+	if w := request(r, http.MethodGet, "/api/admin/warm/jobs", "Bearer old", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("旧令牌 status = %d, want 401", w.Code)
+	}
+	if w := request(r, http.MethodGet, "/api/admin/warm/jobs", "Bearer new", ""); w.Code != http.StatusOK {
+		t.Fatalf("新令牌 status = %d, want 200", w.Code)
+	}
+}
+
+func TestLoginRemoved(t *testing.T) {
+	_, r := newTestAdmin(t, "secret")
+	w := request(r, http.MethodPost, "/api/admin/login", "", `{"username":"admin","password":"admin123"}`)
+	if w.Code == http.StatusOK || strings.Contains(w.Body.String(), "token") {
+		t.Fatalf("登录接口 status = %d, body %s", w.Code, w.Body.String())
+	}
+}
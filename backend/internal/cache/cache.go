@@ -3,11 +3,12 @@ package cache
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	"static-mirrors/pkg/config"
+	"static-mirrors/pkg/metrics"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -20,6 +21,12 @@ type Cache interface {
 	Exists(key string) (bool, error)
 }
 
+// Counter 可以统计条目数的缓存
+// Redis缓存可能与其他服务共用数据库，无法只统计本服务的键，因此不实现该接口
+type Counter interface {
+	Len() (int64, error)
+}
+
 // RedisCache Redis缓存实现
 type RedisCache struct {
 	client *redis.Client
@@ -40,15 +47,21 @@ type cacheItem struct {
 }
 
 // NewCache 创建新的缓存实例
-func NewCache(cfg config.Config) (Cache, error) {
+func NewCache(cfg config.Config, logger *slog.Logger) (Cache, error) {
 	if !cfg.Cache.Enabled {
 		return nil, nil
 	}
 
 	switch cfg.Cache.Type {
 	case "redis":
-		return NewRedisCache(cfg.Cache.Redis)
+		c, err := NewRedisCache(cfg.Cache.Redis)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Redis缓存连接成功", "addr", cfg.Cache.Redis.Addr)
+		return c, nil
 	case "memory":
+		logger.Info("内存缓存初始化成功", "size", cfg.Cache.Memory.Size)
 		return NewMemoryCache(cfg.Cache.Memory.Size), nil
 	default:
 		return nil, fmt.Errorf("不支持的缓存类型: %s", cfg.Cache.Type)
@@ -70,7 +83,6 @@ func NewRedisCache(redisConfig config.RedisConfig) (*RedisCache, error) {
 		return nil, fmt.Errorf("连接Redis失败: %w", err)
 	}
 
-	log.Println("Redis缓存连接成功")
 	return &RedisCache{
 		client: client,
 		ctx:    ctx,
@@ -87,7 +99,6 @@ func NewMemoryCache(size int) *MemoryCache {
 	// 启动清理过期项的协程
 	go cache.cleanupExpired()
 
-	log.Println("内存缓存初始化成功")
 	return cache
 }
 
@@ -185,6 +196,13 @@ func (c *MemoryCache) Exists(key string) (bool, error) {
 	return true, nil
 }
 
+// Len 返回内存缓存中的条目数，包括尚未清理的过期项
+func (c *MemoryCache) Len() (int64, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return int64(len(c.items)), nil
+}
+
 // cleanupExpired 清理过期的缓存项
 func (c *MemoryCache) cleanupExpired() {
 	ticker := time.NewTicker(5 * time.Minute)
@@ -196,6 +214,7 @@ func (c *MemoryCache) cleanupExpired() {
 		for key, item := range c.items {
 			if now.After(item.expiration) {
 				delete(c.items, key)
+				metrics.CacheEvictions.With("expired").Inc()
 			}
 		}
 		c.mutex.Unlock()
@@ -216,6 +235,7 @@ func (c *MemoryCache) evictOldest() {
 
 	if oldestKey != "" {
 		delete(c.items, oldestKey)
+		metrics.CacheEvictions.With("size").Inc()
 	}
 }
 
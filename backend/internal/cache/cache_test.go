@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache(2)
+	if err := c.Set("a", []byte("1"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("expired", []byte("2"), -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Get("a"); err != nil || string(v) != "1" {
+		t.Fatalf("Get(a) = %q, %v", v, err)
+	}
+	if v, err := c.Get("expired"); err != nil || v != nil {
+		t.Fatalf("Get(expired) = %q, %v, want nil", v, err)
+	}
+	if ok, _ := c.Exists("expired"); ok {
+		t.Fatal("过期的键不应存在")
+	}
+
+	if err := c.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := c.Exists("a"); ok {
+		t.Fatal("删除后键仍存在")
+	}
+}
+
+// TestCounter 只有能统计本服务条目数的缓存实现Counter
+func TestCounter(t *testing.T) {
+	if _, ok := Cache(NewMemoryCache(1)).(Counter); !ok {
+		t.Fatal("内存缓存应实现Counter")
+	}
+	if _, ok := Cache(&RedisCache{}).(Counter); ok {
+		t.Fatal("Redis缓存可能与其他服务共用数据库，不应实现Counter")
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Entry 缓存的完整响应
+type Entry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	StoredAt   time.Time
+	ExpiresAt  time.Time
+	// 内容摘要（sha256:<hex>），不为空时响应体按摘要单独存储，相同内容只保存一份
+	Digest string
+}
+
+// Fresh 判断缓存是否仍在有效期内
+func (e *Entry) Fresh() bool {
+	return time.Now().Before(e.ExpiresAt)
+}
+
+// Age 缓存已存在的时长
+func (e *Entry) Age() time.Duration {
+	return time.Since(e.StoredAt)
+}
+
+// Encode 序列化缓存项
+func (e *Entry) Encode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return nil, fmt.Errorf("序列化缓存项失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeEntry 反序列化缓存项
+func DecodeEntry(data []byte) (*Entry, error) {
+	var entry Entry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+		return nil, fmt.Errorf("反序列化缓存项失败: %w", err)
+	}
+	return &entry, nil
+}
+
+// GetEntry 从缓存读取响应，未命中时返回nil
+// 按内容寻址存储的响应会同时读取响应体，响应体已被淘汰时视为未命中
+func GetEntry(c Cache, key string) (*Entry, error) {
+	data, err := c.Get(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	entry, err := DecodeEntry(data)
+	if err != nil || entry.Digest == "" {
+		return entry, err
+	}
+
+	body, err := c.Get(blobKey(entry.Digest))
+	if err != nil || body == nil {
+		return nil, err
+	}
+	entry.Body = body
+	return entry, nil
+}
+
+// SetEntry 向缓存写入响应，ttl为缓存在存储中保留的时长（包含过期后可作为陈旧内容使用的时间）
+func SetEntry(c Cache, key string, entry *Entry, ttl time.Duration) error {
+	data, err := entry.Encode()
+	if err != nil {
+		return err
+	}
+	return c.Set(key, data, ttl)
+}
+
+// SetContentEntry 按内容寻址写入响应：响应体以sha256摘要为键存储，key只保存响应头和摘要
+func SetContentEntry(c Cache, key string, entry *Entry, ttl time.Duration) error {
+	sum := sha256.Sum256(entry.Body)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if err := c.Set(blobKey(digest), entry.Body, ttl); err != nil {
+		return err
+	}
+
+	ref := *entry
+	ref.Body = nil
+	ref.Digest = digest
+	return SetEntry(c, key, &ref, ttl)
+}
+
+// blobKey 生成按内容寻址的响应体缓存键
+func blobKey(digest string) string {
+	return "blob:" + digest
+}
+
+// DeleteEntry 删除缓存的响应，按内容寻址存储的响应同时删除其响应体
+// 引用同一响应体的其他缓存项随之失效，下次请求时重新回源
+func DeleteEntry(c Cache, key string) error {
+	data, err := c.Get(key)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if entry, err := DecodeEntry(data); err == nil && entry.Digest != "" {
+			if err := c.Delete(blobKey(entry.Digest)); err != nil {
+				return err
+			}
+		}
+	}
+	return c.Delete(key)
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestContentEntry(t *testing.T) {
+	c := NewMemoryCache(100)
+	body := []byte("release asset")
+	for _, key := range []string{"a", "b"} {
+		entry := &Entry{StatusCode: http.StatusOK, Header: http.Header{}, Body: body, StoredAt: time.Now()}
+		if err := SetContentEntry(c, key, entry, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 两个键共享同一份响应体
+	if n, _ := c.Len(); n != 3 {
+		t.Fatalf("缓存条目数 = %d, want 3", n)
+	}
+
+	entry, err := GetEntry(c, "a")
+	if err != nil || entry == nil {
+		t.Fatalf("GetEntry() = %v, %v", entry, err)
+	}
+	if string(entry.Body) != string(body) || entry.Digest == "" {
+		t.Fatalf("GetEntry() body = %q, digest %q", entry.Body, entry.Digest)
+	}
+}
+
+func TestDeleteEntry(t *testing.T) {
+	c := NewMemoryCache(100)
+	body := []byte("release asset")
+	for _, key := range []string{"a", "b"} {
+		entry := &Entry{StatusCode: http.StatusOK, Header: http.Header{}, Body: body, StoredAt: time.Now()}
+		if err := SetContentEntry(c, key, entry, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SetEntry(c, "plain", &Entry{StatusCode: http.StatusOK, Body: []byte("plain")}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// 删除按内容寻址的响应时一并删除响应体，引用同一响应体的缓存项视为未命中
+	if err := DeleteEntry(c, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := c.Len(); n != 2 {
+		t.Fatalf("缓存条目数 = %d, want 2", n)
+	}
+	for _, key := range []string{"a", "b"} {
+		if entry, err := GetEntry(c, key); err != nil || entry != nil {
+			t.Fatalf("GetEntry(%s) = %v, %v, want nil", key, entry, err)
+		}
+	}
+
+	// 普通缓存项和不存在的键
+	if err := DeleteEntry(c, "plain"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteEntry(c, "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := c.Len(); n != 1 {
+		t.Fatalf("缓存条目数 = %d, want 1", n)
+	}
+}
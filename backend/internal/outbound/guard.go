@@ -0,0 +1,172 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// 出站请求被拦截的原因
+var (
+	// ErrSchemeNotAllowed 只允许访问HTTP(S)地址
+	ErrSchemeNotAllowed = errors.New("只允许访问HTTP(S)地址")
+	// ErrHostNotAllowed 目标域名不在源站白名单中
+	ErrHostNotAllowed = errors.New("目标域名不在源站白名单中")
+	// ErrAddressNotAllowed 目标地址解析到了内网、回环或链路本地地址
+	ErrAddressNotAllowed = errors.New("禁止访问内网地址")
+)
+
+// blockedPrefixes 除netip分类以外需要额外拦截的地址段
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// Guard 出站请求守卫，限制镜像只能访问白名单域名的公网地址
+type Guard struct {
+	policy atomic.Pointer[guardPolicy]
+}
+
+// guardPolicy 守卫的白名单，更新时整体替换
+type guardPolicy struct {
+	hosts        map[string]bool
+	allowPrivate bool
+}
+
+// NewGuard 创建出站请求守卫，allowPrivate为true时不拦截内网地址（仅用于本地开发）
+func NewGuard(hosts []string, allowPrivate bool) *Guard {
+	g := &Guard{}
+	g.Update(hosts, allowPrivate)
+	return g
+}
+
+// Update 替换白名单，用于配置热更新，之后的请求和新建连接使用新白名单
+func (g *Guard) Update(hosts []string, allowPrivate bool) {
+	policy := &guardPolicy{
+		hosts:        make(map[string]bool, len(hosts)),
+		allowPrivate: allowPrivate,
+	}
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			policy.hosts[host] = true
+		}
+	}
+	g.policy.Store(policy)
+}
+
+// CheckURL 校验URL的协议和域名，域名可以带端口也可以不带端口匹配白名单
+func (g *Guard) CheckURL(u *url.URL) error {
+	if err := CheckScheme(u); err != nil {
+		return err
+	}
+	hosts := g.policy.Load().hosts
+	if !hosts[strings.ToLower(u.Host)] && !hosts[strings.ToLower(u.Hostname())] {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Host)
+	}
+	// IP字面量不需要等到连接时再检查
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+		return g.checkAddr(addr)
+	}
+	return nil
+}
+
+// CheckScheme 只允许HTTP(S)协议
+func CheckScheme(u *url.URL) error {
+	switch u.Scheme {
+	case "http", "https":
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrSchemeNotAllowed, u.Scheme)
+}
+
+// checkAddr 拦截内网、回环、链路本地、组播等非公网地址
+func (g *Guard) checkAddr(addr netip.Addr) error {
+	if g.policy.Load().allowPrivate {
+		return nil
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return fmt.Errorf("%w: %s", ErrAddressNotAllowed, addr)
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %s", ErrAddressNotAllowed, addr)
+		}
+	}
+	return nil
+}
+
+// control 在域名解析完成、建立连接之前检查实际连接的IP，防止DNS重绑定
+func (g *Guard) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrAddressNotAllowed, host)
+	}
+	return g.checkAddr(addr)
+}
+
+// DialContext 带地址检查的拨号函数
+func (g *Guard) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   g.control,
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// Transport 创建使用守卫拨号的Transport
+// 不使用环境变量中的HTTP代理，否则连接检查的对象会变成代理服务器
+func (g *Guard) Transport() *http.Transport {
+	return &http.Transport{
+		DialContext:           g.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// CheckRedirect 校验重定向目标，可作为http.Client.CheckRedirect使用
+func (g *Guard) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("重定向次数过多")
+	}
+	return g.CheckURL(req.URL)
+}
+
+// Client 创建受守卫保护的HTTP客户端
+func (g *Guard) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     g.Transport(),
+		CheckRedirect: g.CheckRedirect,
+	}
+}
+
+// IsBlocked 判断错误是否由守卫拦截产生
+func IsBlocked(err error) bool {
+	return errors.Is(err, ErrSchemeNotAllowed) ||
+		errors.Is(err, ErrHostNotAllowed) ||
+		errors.Is(err, ErrAddressNotAllowed)
+}
@@ -0,0 +1,111 @@
+package outbound
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		hosts        []string
+		allowPrivate bool
+		url          string
+		want         error
+	}{
+		{"白名单域名", []string{"cdn.example.com"}, false, "https://cdn.example.com/a.js", nil},
+		{"域名不区分大小写", []string{"CDN.example.com"}, false, "https://cdn.EXAMPLE.com/a.js", nil},
+		{"带端口匹配不带端口的白名单", []string{"cdn.example.com"}, false, "https://cdn.example.com:8443/a.js", nil},
+		{"不在白名单", []string{"cdn.example.com"}, false, "https://evil.example.com/a.js", ErrHostNotAllowed},
+		{"非HTTP协议", []string{"cdn.example.com"}, false, "ftp://cdn.example.com/a.js", ErrSchemeNotAllowed},
+		{"文件协议", []string{"cdn.example.com"}, false, "file:///etc/passwd", ErrSchemeNotAllowed},
+		{"公网IP", []string{"8.8.8.8"}, false, "http://8.8.8.8/", nil},
+		{"回环地址", []string{"127.0.0.1"}, false, "http://127.0.0.1/", ErrAddressNotAllowed},
+		{"内网地址", []string{"10.0.0.1"}, false, "http://10.0.0.1/", ErrAddressNotAllowed},
+		{"链路本地地址", []string{"169.254.169.254"}, false, "http://169.254.169.254/latest/meta-data/", ErrAddressNotAllowed},
+		{"运营商级NAT地址", []string{"100.64.0.1"}, false, "http://100.64.0.1/", ErrAddressNotAllowed},
+		{"IPv6回环地址", []string{"::1"}, false, "http://[::1]/", ErrAddressNotAllowed},
+		{"IPv4映射的IPv6地址", []string{"::ffff:127.0.0.1"}, false, "http://[::ffff:127.0.0.1]/", ErrAddressNotAllowed},
+		{"允许内网地址", []string{"127.0.0.1"}, true, "http://127.0.0.1/", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = NewGuard(tt.hosts, tt.allowPrivate).CheckURL(u)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("CheckURL(%s) = %v, want %v", tt.url, err, tt.want)
+			}
+			if tt.want != nil && !IsBlocked(err) {
+				t.Fatalf("IsBlocked(%v) = false", err)
+			}
+		})
+	}
+}
+
+func TestGuardUpdate(t *testing.T) {
+	g := NewGuard([]string{"a.example.com"}, false)
+	a, _ := url.Parse("https://a.example.com/")
+	b, _ := url.Parse("https://b.example.com/")
+
+	g.Update([]string{"b.example.com"}, false)
+	if err := g.CheckURL(a); !errors.Is(err, ErrHostNotAllowed) {
+		t.Fatalf("更新后旧域名应被拦截，got %v", err)
+	}
+	if err := g.CheckURL(b); err != nil {
+		t.Fatalf("更新后新域名应被放行，got %v", err)
+	}
+}
+
+func TestClientBlocksPrivateAddress(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+
+	tests := []struct {
+		name         string
+		allowPrivate bool
+		blocked      bool
+	}{
+		{"默认拦截回环地址", false, true},
+		{"允许内网地址时放行", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewGuard([]string{u.Host}, tt.allowPrivate).Client(5 * time.Second)
+			resp, err := client.Get(upstream.URL)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if IsBlocked(err) != tt.blocked {
+				t.Fatalf("Get(%s) error = %v, want blocked %v", upstream.URL, err, tt.blocked)
+			}
+			if !tt.blocked && err != nil {
+				t.Fatalf("Get(%s) error = %v", upstream.URL, err)
+			}
+		})
+	}
+}
+
+func TestClientBlocksRedirect(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://evil.example.com/", http.StatusFound)
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+
+	_, err := NewGuard([]string{u.Host}, true).Client(5 * time.Second).Get(upstream.URL)
+	if !errors.Is(err, ErrHostNotAllowed) {
+		t.Fatalf("重定向到白名单以外的域名应被拦截，got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"static-mirrors/pkg/config"
+)
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerStatus 熔断器状态快照
+type BreakerStatus struct {
+	Source    string     `json:"source"`
+	State     string     `json:"state"`
+	Requests  int        `json:"requests"`
+	Failures  int        `json:"failures"`
+	ErrorRate float64    `json:"error_rate"`
+	OpenedAt  *time.Time `json:"opened_at,omitempty"`
+	RetryAt   *time.Time `json:"retry_at,omitempty"`
+}
+
+// circuitBreaker 单个源站的熔断器
+type circuitBreaker struct {
+	mutex       sync.Mutex
+	config      config.CircuitBreakerConfig
+	state       string
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probing     bool
+}
+
+// breakerGroup 按源站域名分组的熔断器集合
+type breakerGroup struct {
+	mutex    sync.Mutex
+	config   config.CircuitBreakerConfig
+	breakers map[string]*circuitBreaker
+}
+
+// newBreakerGroup 创建熔断器集合
+func newBreakerGroup(cfg config.CircuitBreakerConfig) *breakerGroup {
+	if cfg.WindowSeconds <= 0 {
+		cfg.WindowSeconds = 60
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 10
+	}
+	if cfg.ErrorRate <= 0 || cfg.ErrorRate > 1 {
+		cfg.ErrorRate = 0.5
+	}
+	if cfg.OpenSeconds <= 0 {
+		cfg.OpenSeconds = 30
+	}
+
+	return &breakerGroup{
+		config:   cfg,
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+// get 获取源站对应的熔断器，不存在时创建
+func (g *breakerGroup) get(source string) *circuitBreaker {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	b, exists := g.breakers[source]
+	if !exists {
+		b = &circuitBreaker{
+			config:      g.config,
+			state:       BreakerClosed,
+			windowStart: time.Now(),
+		}
+		g.breakers[source] = b
+	}
+	return b
+}
+
+// statuses 获取所有熔断器的状态快照
+func (g *breakerGroup) statuses() []BreakerStatus {
+	g.mutex.Lock()
+	sources := make([]string, 0, len(g.breakers))
+	for source := range g.breakers {
+		sources = append(sources, source)
+	}
+	g.mutex.Unlock()
+
+	sort.Strings(sources)
+
+	result := make([]BreakerStatus, 0, len(sources))
+	for _, source := range sources {
+		result = append(result, g.get(source).status(source))
+	}
+	return result
+}
+
+// allow 判断是否允许请求源站，probe表示本次请求是半开状态下的探测请求
+func (b *circuitBreaker) allow() (allowed bool, probe bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.openDuration() {
+			return false, false
+		}
+		// 打开时间已过，进入半开状态并放行一个探测请求
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true, true
+	case BreakerHalfOpen:
+		// 半开状态下同一时间只允许一个探测请求
+		if b.probing {
+			return false, false
+		}
+		b.probing = true
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// record 记录请求结果
+func (b *circuitBreaker) record(success bool, probe bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if probe {
+		b.probing = false
+		if success {
+			b.reset(BreakerClosed)
+		} else {
+			b.trip()
+		}
+		return
+	}
+
+	// 熔断打开期间放行的请求（例如状态切换前已发出的请求）不参与计数
+	if b.state != BreakerClosed {
+		return
+	}
+
+	if time.Since(b.windowStart) >= time.Duration(b.config.WindowSeconds)*time.Second {
+		b.reset(BreakerClosed)
+	}
+
+	b.requests++
+	if !success {
+		b.failures++
+	}
+
+	if b.requests >= b.config.MinRequests &&
+		float64(b.failures)/float64(b.requests) >= b.config.ErrorRate {
+		b.trip()
+	}
+}
+
+// cancel 放弃本次请求的结果，半开状态下释放探测名额
+func (b *circuitBreaker) cancel(probe bool) {
+	if !probe {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.probing = false
+}
+
+// trip 打开熔断器
+func (b *circuitBreaker) trip() {
+	b.state = BreakerOpen
+	b.openedAt = time.Now()
+}
+
+// reset 重置计数窗口并切换到指定状态
+func (b *circuitBreaker) reset(state string) {
+	b.state = state
+	b.requests = 0
+	b.failures = 0
+	b.windowStart = time.Now()
+}
+
+// openDuration 熔断打开的持续时间
+func (b *circuitBreaker) openDuration() time.Duration {
+	return time.Duration(b.config.OpenSeconds) * time.Second
+}
+
+// retryAfter 距离下一次探测的剩余时间
+func (b *circuitBreaker) retryAfter() time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state != BreakerOpen {
+		return 0
+	}
+	remaining := b.openDuration() - time.Since(b.openedAt)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// status 获取熔断器状态快照
+func (b *circuitBreaker) status(source string) BreakerStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	status := BreakerStatus{
+		Source:   source,
+		State:    b.state,
+		Requests: b.requests,
+		Failures: b.failures,
+	}
+	if b.requests > 0 {
+		status.ErrorRate = float64(b.failures) / float64(b.requests)
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		retryAt := b.openedAt.Add(b.openDuration())
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+	return status
+}
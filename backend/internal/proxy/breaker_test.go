@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"static-mirrors/pkg/config"
+)
+
+func newTestBreaker() *circuitBreaker {
+	return newBreakerGroup(config.CircuitBreakerConfig{
+		Enabled:       true,
+		WindowSeconds: 60,
+		MinRequests:   4,
+		ErrorRate:     0.5,
+		OpenSeconds:   30,
+	}).get("cdn.example.com")
+}
+
+// expire 让打开状态的熔断器到达重试时间
+func (b *circuitBreaker) expire() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.openedAt = time.Now().Add(-b.openDuration())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name string
+		// 依次记录的普通请求结果
+		results []bool
+		want    string
+	}{
+		{"没有请求", nil, BreakerClosed},
+		{"请求数不足时不熔断", []bool{false, false, false}, BreakerClosed},
+		{"错误率未达到阈值", []bool{true, true, true, false}, BreakerClosed},
+		{"错误率达到阈值", []bool{true, true, false, false}, BreakerOpen},
+		{"全部失败", []bool{false, false, false, false}, BreakerOpen},
+		// 打开之后的结果不参与计数
+		{"打开后的成功请求不关闭熔断", []bool{false, false, false, false, true, true, true, true}, BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreaker()
+			for _, success := range tt.results {
+				b.record(success, false)
+			}
+			if state := b.status("").State; state != tt.want {
+				t.Fatalf("state = %s, want %s", state, tt.want)
+			}
+			if allowed, _ := b.allow(); allowed != (tt.want == BreakerClosed) {
+				t.Fatalf("allow() = %v in state %s", allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name string
+		// 探测请求的结果，nil表示放弃本次结果
+		probe *bool
+		want  string
+	}{
+		{"探测成功后关闭", boolPtr(true), BreakerClosed},
+		{"探测失败后重新打开", boolPtr(false), BreakerOpen},
+		{"放弃探测后保持半开", nil, BreakerHalfOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreaker()
+			for i := 0; i < 4; i++ {
+				b.record(false, false)
+			}
+			if allowed, _ := b.allow(); allowed {
+				t.Fatal("打开状态下不应放行请求")
+			}
+			if b.retryAfter() <= 0 {
+				t.Fatal("打开状态下retryAfter应大于0")
+			}
+
+			b.expire()
+			allowed, probe := b.allow()
+			if !allowed || !probe {
+				t.Fatalf("到达重试时间后 allow() = %v, %v, want true, true", allowed, probe)
+			}
+			if state := b.status("").State; state != BreakerHalfOpen {
+				t.Fatalf("state = %s, want %s", state, BreakerHalfOpen)
+			}
+			if allowed, _ := b.allow(); allowed {
+				t.Fatal("半开状态下同一时间只允许一个探测请求")
+			}
+
+			if tt.probe == nil {
+				b.cancel(probe)
+			} else {
+				b.record(*tt.probe, probe)
+			}
+			if state := b.status("").State; state != tt.want {
+				t.Fatalf("state = %s, want %s", state, tt.want)
+			}
+			if tt.want == BreakerHalfOpen {
+				if allowed, probe := b.allow(); !allowed || !probe {
+					t.Fatal("放弃探测后应允许新的探测请求")
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	b := newTestBreaker()
+	b.record(false, false)
+	b.record(false, false)
+	b.record(false, false)
+
+	// 窗口结束后重新计数，之前的失败不再参与统计
+	b.mutex.Lock()
+	b.windowStart = time.Now().Add(-time.Minute)
+	b.mutex.Unlock()
+	b.record(false, false)
+
+	status := b.status("")
+	if status.State != BreakerClosed || status.Requests != 1 || status.Failures != 1 {
+		t.Fatalf("status = %+v, want closed with 1 request", status)
+	}
+}
+
+func TestCircuitBreakerRejectsRequests(t *testing.T) {
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	p, r := newTestProxy(t, up, config.SourceConfig{Name: "cdn", Prefix: "/cdn"}, false)
+	cfg := *p.cfg()
+	cfg.Proxy.CircuitBreaker = config.CircuitBreakerConfig{Enabled: true, MinRequests: 2, ErrorRate: 0.5}
+	p.Reload(cfg)
+
+	for i := 0; i < 2; i++ {
+		if w := get(r, "/cdn/a.js"); w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("第%d次请求 status = %d, want 503", i+1, w.Code)
+		}
+	}
+
+	// 熔断打开后直接返回503，不再回源
+	w := get(r, "/cdn/a.js")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("熔断后 status = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if hits := up.hits.Load(); hits != 2 {
+		t.Fatalf("回源 %d 次, want 2", hits)
+	}
+
+	statuses := p.BreakerStatuses()
+	if len(statuses) != 1 || statuses[0].State != BreakerOpen {
+		t.Fatalf("BreakerStatuses() = %+v", statuses)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
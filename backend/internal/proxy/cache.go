@@ -0,0 +1,281 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"static-mirrors/internal/cache"
+	"static-mirrors/pkg/config"
+	"static-mirrors/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 缓存状态，通过X-Mirror-Cache响应头返回
+const (
+	CacheHit   = "HIT"
+	CacheMiss  = "MISS"
+	CacheStale = "STALE"
+)
+
+// 陈旧内容的Warning响应头（RFC 7234）
+const (
+	warningStale            = `110 - "Response is Stale"`
+	warningRevalidateFailed = `111 - "Revalidation Failed"`
+)
+
+// defaultMaxObjectSize 默认允许缓存的最大响应大小
+const defaultMaxObjectSize = 50 * 1024 * 1024
+
+// immutableTTL 不可变内容（如带版本号的包文件）的缓存时间
+const immutableTTL = 365 * 24 * time.Hour
+
+// 请求上下文中的缓存设置
+const (
+	// cachePolicyKey 源站类型为单个请求指定的缓存策略
+	cachePolicyKey = "mirror.cache_policy"
+	// cacheVariantKey 同一URL的不同响应变体（如npm精简元数据），会拼接到缓存键中
+	cacheVariantKey = "mirror.cache_variant"
+)
+
+// cachePolicy 覆盖源站Cache-Control的缓存策略
+type cachePolicy struct {
+	// 缓存有效期，为0时使用源站Cache-Control
+	ttl time.Duration
+	// 内容不可变，按immutableTTL缓存，不受cache.ttl.max限制
+	immutable bool
+	// 源站公布的摘要，不为空时只缓存校验通过的内容，并按内容寻址存储
+	checksum *checksum
+}
+
+// cacheControl 返回该策略对应的Cache-Control响应头
+func (cp *cachePolicy) cacheControl() string {
+	if cp.immutable {
+		return fmt.Sprintf("public, max-age=%d, immutable", int64(immutableTTL.Seconds()))
+	}
+	return fmt.Sprintf("public, max-age=%d", int64(cp.ttl.Seconds()))
+}
+
+// matches 判断缓存项是否符合策略中的摘要，没有摘要要求时总是符合
+// 按内容寻址存储的缓存项直接比较摘要，其他缓存项重新计算
+func (cp *cachePolicy) matches(entry *cache.Entry) bool {
+	if cp == nil || cp.checksum == nil {
+		return true
+	}
+	if entry.Digest != "" && cp.checksum.algorithm == checksumSHA256 {
+		return entry.Digest == "sha256:"+cp.checksum.value
+	}
+	_, ok := cp.checksum.verify(entry.Body)
+	return ok
+}
+
+// defaultMetadataTTL 包仓库元数据默认的缓存时间
+const defaultMetadataTTL = 5 * time.Minute
+
+// metadataTTL 返回源站元数据的缓存时间
+func metadataTTL(source *config.SourceConfig) time.Duration {
+	if source.MetadataTTL > 0 {
+		return time.Duration(source.MetadataTTL) * time.Second
+	}
+	return defaultMetadataTTL
+}
+
+// cachePolicyOf 获取请求上下文中的缓存策略，未指定时返回nil
+func cachePolicyOf(c *gin.Context) *cachePolicy {
+	if v, exists := c.Get(cachePolicyKey); exists {
+		if policy, ok := v.(*cachePolicy); ok {
+			return policy
+		}
+	}
+	return nil
+}
+
+// SetOffline 切换离线模式
+func (p *Proxy) SetOffline(offline bool) {
+	p.offline.Store(offline)
+	p.logger.Info("离线模式已" + map[bool]string{true: "开启", false: "关闭"}[offline])
+}
+
+// Offline 是否处于离线模式
+func (p *Proxy) Offline() bool {
+	return p.offline.Load()
+}
+
+// cacheKey 生成源站响应的缓存键
+func cacheKey(targetURL string) string {
+	return cache.GenerateCacheKey(targetURL, http.MethodGet)
+}
+
+// variantCacheKey 生成带响应变体的缓存键
+func variantCacheKey(c *gin.Context, targetURL string) string {
+	if variant := c.GetString(cacheVariantKey); variant != "" {
+		return cacheKey(targetURL) + "#" + variant
+	}
+	return cacheKey(targetURL)
+}
+
+// cacheVariants 返回各源站类型可能使用的全部响应变体，刷新缓存时一并删除
+func cacheVariants() []string {
+	variants := []string{npmAbbreviatedVariant, pypiSimpleJSON, pypiSimpleHTML}
+	for format := range fontUserAgents {
+		variants = append(variants, fontVariant(format))
+	}
+	return variants
+}
+
+// cacheKeys 返回源站URL的缓存键及其所有响应变体的缓存键
+func cacheKeys(targetURL string) []string {
+	key := cacheKey(targetURL)
+	keys := []string{key}
+	for _, variant := range cacheVariants() {
+		keys = append(keys, key+"#"+variant)
+	}
+	return keys
+}
+
+// isCacheable 判断请求是否可以使用缓存
+func (p *Proxy) isCacheable(c *gin.Context) bool {
+	// 范围请求直接转发，避免把部分内容当作完整响应
+	return p.cache != nil &&
+		c.Request.Method == http.MethodGet &&
+		c.GetHeader("Range") == ""
+}
+
+// lookupCache 读取缓存，读取失败视为未命中
+func (p *Proxy) lookupCache(c *gin.Context, key string) *cache.Entry {
+	entry, err := cache.GetEntry(p.cache, key)
+	if err != nil {
+		p.log(c).Warn("读取缓存失败", "key", key, "error", err)
+		return nil
+	}
+	return entry
+}
+
+// storeCache 将完整响应写入缓存
+func (p *Proxy) storeCache(c *gin.Context, key string, resp *http.Response, cacheControl string, body []byte, policy *cachePolicy) {
+	cfg := p.requestConfig(c)
+	if strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "private") {
+		return
+	}
+
+	var fresh time.Duration
+	switch {
+	case policy != nil && policy.immutable:
+		fresh = immutableTTL
+	case policy != nil && policy.ttl > 0:
+		fresh = policy.ttl
+	default:
+		fresh = p.freshness(cfg, cacheControl)
+	}
+	retain := fresh
+	if cfg.Cache.Stale.Enabled && cfg.Cache.Stale.MaxStale > 0 {
+		retain += time.Duration(cfg.Cache.Stale.MaxStale) * time.Second
+	}
+
+	header := resp.Header.Clone()
+	header.Del("Content-Length")
+
+	now := time.Now()
+	entry := &cache.Entry{
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		Body:       body,
+		StoredAt:   now,
+		ExpiresAt:  now.Add(fresh),
+	}
+	// GitHub发布附件等内容按摘要存储，不同地址指向同一内容时只保存一份
+	store := cache.SetEntry
+	if resp.Request != nil && p.isGitHubURL(cfg, resp.Request.URL) {
+		store = cache.SetContentEntry
+	}
+	if policy != nil && policy.checksum != nil {
+		if actual, ok := policy.checksum.verify(body); !ok {
+			p.log(c).Warn("内容校验失败，不写入缓存", "key", key, "expected", policy.checksum.String(), "actual", actual)
+			return
+		}
+		store = cache.SetContentEntry
+	}
+	if err := store(p.cache, key, entry, retain); err != nil {
+		p.log(c).Warn("写入缓存失败", "key", key, "error", err)
+	}
+}
+
+// freshness 根据Cache-Control计算缓存有效期
+func (p *Proxy) freshness(cfg *config.Config, cacheControl string) time.Duration {
+	maxAge := p.extractMaxAge(cacheControl)
+	if maxAge <= 0 {
+		maxAge = int64(cfg.Cache.TTL.Default)
+	}
+	if max := int64(cfg.Cache.TTL.Max); max > 0 && maxAge > max {
+		maxAge = max
+	}
+	return time.Duration(maxAge) * time.Second
+}
+
+// maxObjectSize 单个响应允许缓存的最大字节数
+func (p *Proxy) maxObjectSize(cfg *config.Config) int64 {
+	if cfg.Cache.MaxObjectSize > 0 {
+		return cfg.Cache.MaxObjectSize
+	}
+	return defaultMaxObjectSize
+}
+
+// serveEntry 使用缓存内容响应客户端
+func (p *Proxy) serveEntry(c *gin.Context, entry *cache.Entry, status string, warning string) {
+	resp := &http.Response{
+		StatusCode:    entry.StatusCode,
+		Header:        entry.Header,
+		ContentLength: int64(len(entry.Body)),
+	}
+	p.writeHeaders(c, resp)
+
+	c.Header("X-Mirror-Cache", status)
+	logging.Set(c, logging.FieldCache, status)
+	c.Header("Age", strconv.FormatInt(int64(entry.Age().Seconds()), 10))
+	if warning != "" {
+		c.Header("Warning", warning)
+	}
+
+	// 处理条件请求
+	if etag := entry.Header.Get("ETag"); etag != "" && c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Header("Content-Length", strconv.Itoa(len(entry.Body)))
+	c.Status(entry.StatusCode)
+	if c.Request.Method == http.MethodHead {
+		return
+	}
+
+	transferStart := time.Now()
+	defer pipelineTimingOf(c).addTransfer(transferStart)
+	if _, err := c.Writer.Write(entry.Body); err != nil {
+		p.log(c).Warn("写入缓存响应失败", "error", err)
+	}
+}
+
+// captureWriter 在写给客户端的同时截留响应体，超过上限后放弃截留
+type captureWriter struct {
+	dst      http.ResponseWriter
+	buf      []byte
+	limit    int64
+	overflow bool
+}
+
+// Write 写入响应体
+func (w *captureWriter) Write(b []byte) (int, error) {
+	n, err := w.dst.Write(b)
+	if !w.overflow {
+		if int64(len(w.buf)+n) > w.limit {
+			w.overflow = true
+			w.buf = nil
+		} else {
+			w.buf = append(w.buf, b[:n]...)
+		}
+	}
+	return n, err
+}
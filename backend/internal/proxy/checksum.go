@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"regexp"
+)
+
+// 支持的摘要算法
+const (
+	checksumSHA1   = "sha1"
+	checksumSHA256 = "sha256"
+)
+
+// 摘要的十六进制形式
+var (
+	sha1Pattern   = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// checksum 源站公布的内容摘要
+type checksum struct {
+	algorithm string
+	// 小写十六进制
+	value string
+}
+
+// verify 校验内容是否与摘要一致，返回实际摘要
+func (cs *checksum) verify(body []byte) (string, bool) {
+	h := cs.newHash()
+	h.Write(body)
+	return cs.match(h)
+}
+
+// newHash 创建摘要算法对应的哈希，用于边接收边计算
+func (cs *checksum) newHash() hash.Hash {
+	if cs.algorithm == checksumSHA1 {
+		return sha1.New()
+	}
+	return sha256.New()
+}
+
+// match 比较已写入全部内容的哈希与摘要，返回实际摘要
+func (cs *checksum) match(h hash.Hash) (string, bool) {
+	actual := hex.EncodeToString(h.Sum(nil))
+	return actual, actual == cs.value
+}
+
+// String 返回 算法=摘要 形式
+func (cs *checksum) String() string {
+	return cs.algorithm + "=" + cs.value
+}
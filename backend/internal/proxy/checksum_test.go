@@ -0,0 +1,257 @@
+package proxy
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"static-mirrors/pkg/config"
+	"static-mirrors/pkg/utils"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestChecksumVerify(t *testing.T) {
+	body := "artifact content"
+	tests := []struct {
+		name string
+		sum  checksum
+		ok   bool
+	}{
+		{"sha256一致", checksum{algorithm: checksumSHA256, value: sha256Hex(body)}, true},
+		{"sha1一致", checksum{algorithm: checksumSHA1, value: sha1Hex(body)}, true},
+		{"sha256不一致", checksum{algorithm: checksumSHA256, value: sha256Hex("other")}, false},
+		{"sha1不一致", checksum{algorithm: checksumSHA1, value: sha1Hex("other")}, false},
+		{"算法不匹配", checksum{algorithm: checksumSHA256, value: sha1Hex(body)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, ok := tt.sum.verify([]byte(body))
+			if ok != tt.ok {
+				t.Fatalf("verify() = %s, %v, want %v", actual, ok, tt.ok)
+			}
+
+			// 分块写入的结果与一次性校验一致
+			h := tt.sum.newHash()
+			h.Write([]byte(body[:5]))
+			h.Write([]byte(body[5:]))
+			if streamed, ok := tt.sum.match(h); streamed != actual || ok != tt.ok {
+				t.Fatalf("match() = %s, %v, want %s, %v", streamed, ok, actual, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMavenChecksum(t *testing.T) {
+	const artifact = "maven artifact content"
+	tests := []struct {
+		name string
+		// 源站公布的校验和文件，键为扩展名
+		sums map[string]string
+		// cache.max_object_size，小于制品大小时流式校验
+		maxObjectSize int64
+		status        int
+		body          string
+		// aborted 响应以 http.ErrAbortHandler 中止
+		aborted bool
+		cached  bool
+	}{
+		{
+			name:   "sha256校验通过",
+			sums:   map[string]string{".sha256": sha256Hex(artifact)},
+			status: 200, body: artifact, cached: true,
+		},
+		{
+			name:   "只有sha1且带文件名",
+			sums:   map[string]string{".sha1": sha1Hex(artifact) + "  lib-1.0.jar\n"},
+			status: 200, body: artifact, cached: true,
+		},
+		{
+			name:   "sha256优先于sha1",
+			sums:   map[string]string{".sha256": sha256Hex(artifact), ".sha1": sha1Hex("other")},
+			status: 200, body: artifact, cached: true,
+		},
+		{
+			name:   "校验失败返回502且不缓存",
+			sums:   map[string]string{".sha256": sha256Hex("other")},
+			status: 502, cached: false,
+		},
+		{
+			name:   "没有校验和时直接转发",
+			sums:   map[string]string{},
+			status: 200, body: artifact, cached: true,
+		},
+		{
+			name:   "无效的校验和",
+			sums:   map[string]string{".sha256": "not-a-digest"},
+			status: 502, cached: false,
+		},
+		{
+			name:          "流式校验通过",
+			sums:          map[string]string{".sha256": sha256Hex(artifact)},
+			maxObjectSize: 8,
+			status:        200, body: artifact, cached: false,
+		},
+		{
+			name:          "流式校验失败时不写出最后一个字节并中止响应",
+			sums:          map[string]string{".sha256": sha256Hex("other")},
+			maxObjectSize: 8,
+			status:        200, body: artifact[:len(artifact)-1], aborted: true, cached: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var artifactHits int
+			up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				const file = "/com/example/lib/1.0/lib-1.0.jar"
+				switch {
+				case r.URL.Path == file:
+					artifactHits++
+					w.Write([]byte(artifact))
+				case strings.HasPrefix(r.URL.Path, file+"."):
+					if sum, ok := tt.sums[strings.TrimPrefix(r.URL.Path, file)]; ok {
+						w.Write([]byte(sum))
+						return
+					}
+					http.NotFound(w, r)
+				default:
+					http.NotFound(w, r)
+				}
+			})
+			p, r := newTestProxy(t, up, config.SourceConfig{
+				Name:   "maven",
+				Type:   config.SourceTypeMaven,
+				Prefix: "/maven",
+			}, true)
+			if tt.maxObjectSize > 0 {
+				cfg := *p.cfg()
+				cfg.Cache.MaxObjectSize = tt.maxObjectSize
+				p.Reload(cfg)
+			}
+
+			const path = "/maven/com/example/lib/1.0/lib-1.0.jar"
+			w, aborted := getAborted(r, path)
+			if aborted != tt.aborted {
+				t.Fatalf("GET %s aborted = %v, want %v", path, aborted, tt.aborted)
+			}
+			if w.Code != tt.status {
+				t.Fatalf("GET %s status = %d, want %d, body %s", path, w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == 200 && w.Body.String() != tt.body {
+				t.Fatalf("GET %s body = %q, want %q", path, w.Body.String(), tt.body)
+			}
+
+			// 第二次请求命中缓存时不再回源
+			getAborted(r, path)
+			if cached := artifactHits == 1; cached != tt.cached {
+				t.Fatalf("制品回源 %d 次, want cached %v", artifactHits, tt.cached)
+			}
+		})
+	}
+}
+
+// getAborted 发起GET请求，处理器以 http.ErrAbortHandler 中止响应时aborted为true
+func getAborted(r http.Handler, path string) (w *httptest.ResponseRecorder, aborted bool) {
+	w = httptest.NewRecorder()
+	defer func() {
+		if err := recover(); err != nil {
+			if err != http.ErrAbortHandler {
+				panic(err)
+			}
+			aborted = true
+		}
+	}()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w, false
+}
+
+// TestMavenChecksumAbortConnection 流式校验失败时客户端必须看到中断的响应，而不是少一个字节的正常响应
+// 制品大于net/http的写缓冲，响应头和大部分内容在校验完成前已经发给客户端；
+// 源站不返回长度（分块传输）时只能靠中止连接或流让客户端发现
+func TestMavenChecksumAbortConnection(t *testing.T) {
+	artifact := strings.Repeat("maven artifact content\n", 4096)
+	tests := []struct {
+		name          string
+		http2         bool
+		contentLength bool
+	}{
+		{"HTTP/1.1", false, true},
+		{"HTTP/1.1分块传输", false, false},
+		{"HTTP/2", true, true},
+		{"HTTP/2分块传输", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, ".sha256") {
+					w.Write([]byte(sha256Hex("other")))
+					return
+				}
+				if strings.HasSuffix(r.URL.Path, ".jar") {
+					if tt.contentLength {
+						w.Header().Set("Content-Length", strconv.Itoa(len(artifact)))
+					}
+					w.Write([]byte(artifact))
+					return
+				}
+				http.NotFound(w, r)
+			})
+			p, r := newTestProxy(t, up, config.SourceConfig{
+				Name:   "maven",
+				Type:   config.SourceTypeMaven,
+				Prefix: "/maven",
+			}, true)
+			cfg := *p.cfg()
+			cfg.Cache.MaxObjectSize = 8
+			p.Reload(cfg)
+			r.Use(utils.RecoveryMiddleware())
+
+			srv := httptest.NewUnstartedServer(r)
+			srv.EnableHTTP2 = tt.http2
+			srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+			srv.StartTLS()
+			t.Cleanup(srv.Close)
+
+			resp, err := srv.Client().Get(srv.URL + "/maven/com/example/lib/1.0/lib-1.0.jar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if got := resp.ProtoMajor == 2; got != tt.http2 {
+				t.Fatalf("响应协议 %s", resp.Proto)
+			}
+			wantLength := int64(-1)
+			if tt.contentLength {
+				wantLength = int64(len(artifact))
+			}
+			if resp.ContentLength != wantLength {
+				t.Fatalf("Content-Length = %d, want %d", resp.ContentLength, wantLength)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err == nil {
+				t.Fatalf("读取响应体没有报错，收到 %d 字节", len(body))
+			}
+			if len(body) >= len(artifact) {
+				t.Fatalf("收到 %d 字节，校验失败的制品不能完整写出", len(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"html"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// URL转换状态
+const (
+	ConvertOK          = "ok"
+	ConvertUnsupported = "unsupported"
+	ConvertBlocked     = "blocked"
+	ConvertInvalid     = "invalid"
+)
+
+// URLConversion 单个URL的转换结果
+type URLConversion struct {
+	Original string `json:"original_url"`
+	Mirror   string `json:"accelerated_url,omitempty"`
+	// 匹配到的源站名称
+	Source string `json:"source,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// OK 是否转换成功
+func (r URLConversion) OK() bool {
+	return r.Status == ConvertOK
+}
+
+// ConvertURL 将源站URL转换为镜像地址，base不为空时返回以base开头的完整地址
+// 支持协议相对地址（//unpkg.com/...），配置了路径前缀的源站转换为路径代理形式
+func (p *Proxy) ConvertURL(raw string, base string) URLConversion {
+	return p.convertURL(p.cfg(), raw, base)
+}
+
+// convertURL 按给定的配置快照转换URL
+func (p *Proxy) convertURL(cfg *config.Config, raw string, base string) URLConversion {
+	result := URLConversion{Original: raw}
+
+	target := strings.TrimSpace(raw)
+	if strings.HasPrefix(target, "//") {
+		target = "https:" + target
+	}
+	parsedURL, err := url.Parse(target)
+	if err != nil || parsedURL.Host == "" {
+		result.Status = ConvertInvalid
+		result.Error = "无效的URL格式"
+		return result
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		result.Status = ConvertInvalid
+		result.Error = "不支持的协议: " + parsedURL.Scheme
+		return result
+	}
+
+	source := p.sourceFor(cfg, parsedURL.Host)
+	if source == nil {
+		result.Status = ConvertUnsupported
+		result.Error = "不支持的源站: " + parsedURL.Host
+		return result
+	}
+	result.Source = source.Name
+
+	// GitHub源站只支持原始文件和发布附件
+	if source.Type == config.SourceTypeGitHub {
+		if _, ok := p.githubMirrorPath(cfg, parsedURL); !ok {
+			result.Status = ConvertUnsupported
+			result.Error = "不支持的GitHub地址"
+			return result
+		}
+	}
+
+	// 源站根路径和账号相关路径不提供镜像
+	upstreamPath := parsedURL.Path
+	if upstreamPath == "" {
+		upstreamPath = "/"
+	}
+	if p.isBlockedPath(upstreamPath) || p.isBlockedURL(cfg, parsedURL.String()) {
+		result.Status = ConvertBlocked
+		result.Error = "该URL已被封禁"
+		return result
+	}
+
+	result.Status = ConvertOK
+	result.Mirror = strings.TrimRight(base, "/") + p.mirrorPath(cfg, parsedURL)
+	return result
+}
+
+// htmlTagPattern 匹配需要改写的script和link标签
+var htmlTagPattern = regexp.MustCompile(`(?is)<(?:script|link)\b[^>]*>`)
+
+// htmlAttrPattern 匹配标签中的src和href属性
+var htmlAttrPattern = regexp.MustCompile(`(?is)(\s(?:src|href)\s*=\s*)("[^"]*"|'[^']*'|[^\s"'>]+)`)
+
+// RewriteHTML 将HTML文档中script和link标签引用的源站地址改写为镜像地址
+// 相对地址保持不变，返回改写后的文档和每个外部地址的转换结果
+func (p *Proxy) RewriteHTML(doc string, base string) (string, []URLConversion) {
+	// 整个文档使用同一份配置快照
+	cfg := p.cfg()
+	var results []URLConversion
+
+	rewritten := htmlTagPattern.ReplaceAllStringFunc(doc, func(tag string) string {
+		return htmlAttrPattern.ReplaceAllStringFunc(tag, func(attr string) string {
+			match := htmlAttrPattern.FindStringSubmatch(attr)
+			prefix, value := match[1], match[2]
+
+			quote := ""
+			if value[0] == '"' || value[0] == '\'' {
+				quote = value[:1]
+				value = value[1 : len(value)-1]
+			}
+
+			original := html.UnescapeString(value)
+			if !isAbsoluteURL(original) {
+				return attr
+			}
+
+			result := p.convertURL(cfg, original, base)
+			results = append(results, result)
+			if !result.OK() {
+				return attr
+			}
+			return prefix + quote + html.EscapeString(result.Mirror) + quote
+		})
+	})
+
+	return rewritten, results
+}
+
+// isAbsoluteURL 判断是否为带协议或协议相对的外部地址
+func isAbsoluteURL(value string) bool {
+	value = strings.TrimSpace(value)
+	lower := strings.ToLower(value)
+	return strings.HasPrefix(value, "//") ||
+		strings.HasPrefix(lower, "http://") ||
+		strings.HasPrefix(lower, "https://")
+}
+
+// requestBaseURL 返回镜像自身的访问地址
+// 配置了 app.public_url 时直接使用；否则根据请求推断，X-Forwarded-Host/Proto 只在请求来自
+// app.trusted_proxies 时采用，并通过Vary声明响应依赖这些头，避免伪造的头污染共享缓存
+func (p *Proxy) requestBaseURL(c *gin.Context) string {
+	if publicURL := p.requestConfig(c).App.PublicURL; publicURL != "" {
+		return strings.TrimSuffix(publicURL, "/")
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	host := c.Request.Host
+	if !p.fromTrustedProxy(c) {
+		return scheme + "://" + host
+	}
+
+	c.Writer.Header().Add("Vary", "X-Forwarded-Host, X-Forwarded-Proto")
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + host
+}
+
+// fromTrustedProxy 判断请求的直接来源是否为 app.trusted_proxies 中的地址
+func (p *Proxy) fromTrustedProxy(c *gin.Context) bool {
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, entry := range p.requestConfig(c).App.TrustedProxies {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+		} else if trusted, err := netip.ParseAddr(entry); err == nil && trusted.Unmap() == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newConvertTestProxy 创建只用于地址转换的反代服务
+func newConvertTestProxy(t *testing.T, cfg config.Config) *Proxy {
+	t.Helper()
+	if cfg.Sources == nil {
+		cfg.Sources = []config.SourceConfig{
+			{Name: "unpkg", Domain: "unpkg.com", Prefix: "/unpkg", Enabled: true},
+			{Name: "disabled", Domain: "disabled.example.com", Prefix: "/disabled", Enabled: false},
+		}
+	}
+	return NewProxy(cfg, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestConvertURL(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Security.BlockedURLs = []string{"unpkg.com/private"}
+	p := newConvertTestProxy(t, cfg)
+
+	tests := []struct {
+		name   string
+		raw    string
+		status string
+		mirror string
+	}{
+		{"路径代理", "https://unpkg.com/vue@3/dist/vue.js", ConvertOK, "https://mirror.example.com/unpkg/vue@3/dist/vue.js"},
+		{"协议相对地址", "//unpkg.com/vue@3", ConvertOK, "https://mirror.example.com/unpkg/vue@3"},
+		{"前后空白", "  https://unpkg.com/vue@3  ", ConvertOK, "https://mirror.example.com/unpkg/vue@3"},
+		{"不支持的源站", "https://example.com/a.js", ConvertUnsupported, ""},
+		{"未启用的源站", "https://disabled.example.com/a.js", ConvertUnsupported, ""},
+		{"源站根路径", "https://unpkg.com", ConvertBlocked, ""},
+		{"账号相关路径", "https://unpkg.com/login", ConvertBlocked, ""},
+		{"blocked_urls", "https://unpkg.com/private/a.js", ConvertBlocked, ""},
+		{"不支持的协议", "ftp://unpkg.com/a.js", ConvertInvalid, ""},
+		{"不是URL", "not a url", ConvertInvalid, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := p.ConvertURL(tt.raw, "https://mirror.example.com/")
+			if result.Status != tt.status || result.Mirror != tt.mirror {
+				t.Fatalf("ConvertURL(%q) = %+v, want %s %s", tt.raw, result, tt.status, tt.mirror)
+			}
+			if result.OK() && result.Source != "unpkg" {
+				t.Fatalf("Source = %s", result.Source)
+			}
+			if !result.OK() && result.Error == "" {
+				t.Fatal("转换失败时缺少错误信息")
+			}
+		})
+	}
+}
+
+func TestRewriteHTML(t *testing.T) {
+	p := newConvertTestProxy(t, config.Config{})
+	doc := `<html><head>
+<script src="https://unpkg.com/vue@3/dist/vue.js"></script>
+<link rel="stylesheet" href='//unpkg.com/a.css?x=1&amp;y=2'>
+<script src=/local.js></script>
+<script src="https://example.com/other.js"></script>
+</head><body><a href="https://unpkg.com/vue@3">链接不改写</a></body></html>`
+
+	rewritten, results := p.RewriteHTML(doc, "")
+	for _, want := range []string{
+		`<script src="/unpkg/vue@3/dist/vue.js"></script>`,
+		`<link rel="stylesheet" href='/unpkg/a.css?x=1&amp;y=2'>`,
+		`<script src=/local.js></script>`,
+		`<script src="https://example.com/other.js"></script>`,
+		`<a href="https://unpkg.com/vue@3">`,
+	} {
+		if !strings.Contains(rewritten, want) {
+			t.Errorf("改写结果缺少 %s\n%s", want, rewritten)
+		}
+	}
+
+	// 相对地址和非script/link标签不计入结果
+	if len(results) != 3 {
+		t.Fatalf("转换结果 %d 个, want 3: %+v", len(results), results)
+	}
+	if results[2].Status != ConvertUnsupported {
+		t.Fatalf("不支持的源站 = %+v", results[2])
+	}
+}
+
+func TestRequestBaseURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		publicURL string
+		trusted   []string
+		header    map[string]string
+		want      string
+		vary      bool
+	}{
+		{"按请求推断", "", nil, nil, "http://mirror.local", false},
+		{"public_url优先", "https://cdn.example.com/", []string{"192.0.2.1"}, map[string]string{"X-Forwarded-Host": "evil.example.com"}, "https://cdn.example.com", false},
+		{"不信任的来源忽略转发头", "", nil, map[string]string{"X-Forwarded-Host": "evil.example.com", "X-Forwarded-Proto": "https"}, "http://mirror.local", false},
+		{"信任的代理", "", []string{"192.0.2.0/24"}, map[string]string{"X-Forwarded-Host": "cdn.example.com, proxy.local", "X-Forwarded-Proto": "https"}, "https://cdn.example.com", true},
+		{"无效的转发协议", "", []string{"192.0.2.1"}, map[string]string{"X-Forwarded-Proto": "ftp"}, "http://mirror.local", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{}
+			cfg.App.PublicURL = tt.publicURL
+			cfg.App.TrustedProxies = tt.trusted
+			p := newConvertTestProxy(t, cfg)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "http://mirror.local/api/process-url", nil)
+			c.Request.RemoteAddr = "192.0.2.1:12345"
+			for k, v := range tt.header {
+				c.Request.Header.Set(k, v)
+			}
+
+			if got := p.requestBaseURL(c); got != tt.want {
+				t.Fatalf("requestBaseURL() = %s, want %s", got, tt.want)
+			}
+			if vary := w.Header().Get("Vary") != ""; vary != tt.vary {
+				t.Fatalf("Vary = %q", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
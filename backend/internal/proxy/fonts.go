@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fontsFilesHost Google Fonts字体文件所在的域名
+const fontsFilesHost = "fonts.gstatic.com"
+
+// 浏览器支持的字体格式，决定CSS API返回的字体文件类型
+const (
+	fontFormatWOFF2 = "woff2"
+	fontFormatWOFF  = "woff"
+	fontFormatTTF   = "ttf"
+)
+
+// fontUserAgents 回源时每种字体格式使用的代表性User-Agent，同一格式的客户端共用缓存
+var fontUserAgents = map[string]string{
+	fontFormatWOFF2: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	fontFormatWOFF:  "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+	fontFormatTTF:   "Wget/1.21",
+}
+
+// fontVariant 字体CSS按格式区分的缓存变体
+func fontVariant(format string) string {
+	return "ua:" + format
+}
+
+// fontWOFF2Versions 各浏览器开始支持woff2的主版本号
+var fontWOFF2Versions = []struct {
+	pattern *regexp.Regexp
+	version int
+}{
+	{regexp.MustCompile(`Edge/(\d+)`), 14},
+	{regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)`), 36},
+	{regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+)`), 39},
+	{regexp.MustCompile(`Version/(\d+)[.\d]* (?:Mobile/\S+ )?Safari/`), 12},
+}
+
+// fontsURLPattern 匹配CSS中的字体文件地址
+var fontsURLPattern = regexp.MustCompile(`https://` + regexp.QuoteMeta(fontsFilesHost) + `/[^)'"\s]+`)
+
+// serveFonts 处理Google Fonts请求：CSS按浏览器支持的字体格式分别缓存并改写字体地址，字体文件永久缓存
+func (p *Proxy) serveFonts(c *gin.Context, source *config.SourceConfig, host string, targetURL string) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		c.JSON(405, gin.H{"error": "字体镜像只支持下载"})
+		return
+	}
+
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的URL格式"})
+		return
+	}
+
+	// 路径代理模式下 /fonts/s/... 和 /fonts/l/... 对应字体文件域名
+	if host == source.Domain && (strings.HasPrefix(parsedURL.Path, "/s/") || strings.HasPrefix(parsedURL.Path, "/l/")) {
+		host = fontsFilesHost
+		parsedURL.Host = fontsFilesHost
+	}
+
+	if host == fontsFilesHost {
+		c.Set(cachePolicyKey, &cachePolicy{immutable: true})
+		p.forward(c, host, parsedURL.String())
+		return
+	}
+
+	// CSS内容取决于User-Agent，回源时统一使用该格式的代表性User-Agent，保证缓存变体与内容一致
+	format := fontFormat(c.GetHeader("User-Agent"))
+	c.Request.Header.Set("User-Agent", fontUserAgents[format])
+	c.Set(cacheVariantKey, fontVariant(format))
+	c.Set(cachePolicyKey, &cachePolicy{ttl: metadataTTL(source)})
+	c.Header("Vary", "User-Agent")
+
+	cfg := p.requestConfig(c)
+	base := p.requestBaseURL(c)
+	p.serveRewritten(c, host, parsedURL.String(), func(body []byte) []byte {
+		return p.rewriteFontsCSS(cfg, body, base)
+	})
+}
+
+// rewriteFontsCSS 将CSS中的 fonts.gstatic.com 地址改写为镜像地址
+func (p *Proxy) rewriteFontsCSS(cfg *config.Config, body []byte, base string) []byte {
+	return fontsURLPattern.ReplaceAllFunc(body, func(match []byte) []byte {
+		target, err := url.Parse(string(match))
+		if err != nil {
+			return match
+		}
+		return []byte(base + p.mirrorPath(cfg, target))
+	})
+}
+
+// fontsMirrorPath 将字体文件地址转换为 <前缀>/s/... 路径
+func (p *Proxy) fontsMirrorPath(cfg *config.Config, u *url.URL) (string, bool) {
+	source := p.sourceOfType(cfg, config.SourceTypeFonts)
+	if source == nil || u.Scheme != "https" || u.Host != fontsFilesHost {
+		return "", false
+	}
+	prefix, ok := p.pathPrefix(cfg, source.Domain)
+	if !ok {
+		return "", false
+	}
+	return prefix + u.RequestURI(), true
+}
+
+// fontFormat 根据User-Agent判断浏览器支持的字体格式，非浏览器客户端使用ttf
+func fontFormat(userAgent string) string {
+	if !strings.HasPrefix(userAgent, "Mozilla/") {
+		return fontFormatTTF
+	}
+	if strings.Contains(userAgent, "Trident/") || strings.Contains(userAgent, "MSIE ") {
+		return fontFormatWOFF
+	}
+	for _, browser := range fontWOFF2Versions {
+		if m := browser.pattern.FindStringSubmatch(userAgent); m != nil {
+			if version, err := strconv.Atoi(m[1]); err == nil && version < browser.version {
+				return fontFormatWOFF
+			}
+			return fontFormatWOFF2
+		}
+	}
+	return fontFormatWOFF2
+}
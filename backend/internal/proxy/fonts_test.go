@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"static-mirrors/pkg/config"
+)
+
+func TestFontFormat(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{fontUserAgents[fontFormatWOFF2], fontFormatWOFF2},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0", fontFormatWOFF2},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15", fontFormatWOFF2},
+		{"Mozilla/5.0 (Windows NT 6.1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/30.0.1599.101 Safari/537.36", fontFormatWOFF},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_13) AppleWebKit/604.1 (KHTML, like Gecko) Version/11.0 Safari/604.1", fontFormatWOFF},
+		{fontUserAgents[fontFormatWOFF], fontFormatWOFF},
+		{"Mozilla/4.0 (compatible; MSIE 8.0; Windows NT 6.1)", fontFormatWOFF},
+		{"curl/8.4.0", fontFormatTTF},
+		{"", fontFormatTTF},
+	}
+
+	for _, tt := range tests {
+		if got := fontFormat(tt.userAgent); got != tt.want {
+			t.Errorf("fontFormat(%q) = %s, want %s", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestFonts(t *testing.T) {
+	var userAgents []string
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == fontsFilesHost {
+			w.Write([]byte("font " + r.URL.Path))
+			return
+		}
+		if r.URL.Path != "/css2" {
+			http.NotFound(w, r)
+			return
+		}
+		userAgents = append(userAgents, r.UserAgent())
+		format := fontFormat(r.UserAgent())
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte("@font-face { src: url(https://" + fontsFilesHost + "/s/roboto/v1/a." + format + ") format('" + format + "'); }"))
+	})
+	p, r := newTestProxy(t, up, config.SourceConfig{Name: "fonts", Type: config.SourceTypeFonts, Prefix: "/fonts"}, true)
+	routeAll(p, up)
+
+	chrome := "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36"
+	w := get(r, "/fonts/css2?family=Roboto", "User-Agent", chrome)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "url(http://example.com/fonts/s/roboto/v1/a.woff2)") {
+		t.Fatalf("字体地址没有改写为镜像地址: %s", w.Body.String())
+	}
+	if w.Header().Get("Vary") != "User-Agent" {
+		t.Fatalf("Vary = %q", w.Header().Get("Vary"))
+	}
+
+	// 不同格式分别缓存，同一格式的其他浏览器命中缓存
+	if w := get(r, "/fonts/css2?family=Roboto", "User-Agent", "Wget/1.20"); !strings.Contains(w.Body.String(), "a.ttf") {
+		t.Fatalf("ttf CSS = %s", w.Body.String())
+	}
+	firefox := "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	if w := get(r, "/fonts/css2?family=Roboto", "User-Agent", firefox); w.Header().Get("X-Mirror-Cache") != CacheHit || !strings.Contains(w.Body.String(), "a.woff2") {
+		t.Fatalf("X-Mirror-Cache = %s, body %s", w.Header().Get("X-Mirror-Cache"), w.Body.String())
+	}
+	// 回源使用该格式的代表性User-Agent
+	if len(userAgents) != 2 || userAgents[0] != fontUserAgents[fontFormatWOFF2] || userAgents[1] != fontUserAgents[fontFormatTTF] {
+		t.Fatalf("回源User-Agent = %q", userAgents)
+	}
+
+	w = get(r, "/fonts/s/roboto/v1/a.woff2")
+	if w.Code != http.StatusOK || w.Body.String() != "font /s/roboto/v1/a.woff2" {
+		t.Fatalf("字体文件 status = %d, body %q", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Header().Get("Cache-Control"), "immutable") {
+		t.Fatalf("字体文件 Cache-Control = %q", w.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"static-mirrors/pkg/config"
+)
+
+// GitHub源站的路径代理前缀
+const (
+	githubRawPrefix     = "/gh-raw"
+	githubReleasePrefix = "/gh-release"
+)
+
+// githubRawHost GitHub仓库原始文件的域名
+const githubRawHost = "raw.githubusercontent.com"
+
+// githubHosts GitHub源站除主域名外需要访问的域名，发布附件会重定向到带签名的对象存储地址
+var githubHosts = []string{
+	githubRawHost,
+	"objects.githubusercontent.com",
+	"release-assets.githubusercontent.com",
+	"github-releases.githubusercontent.com",
+}
+
+// githubNamePattern GitHub用户名和仓库名允许的字符
+var githubNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// errInvalidGitHubPath GitHub路径格式不正确
+var errInvalidGitHubPath = errors.New("无效的GitHub路径，格式应为 /gh-raw/owner/repo/ref/path 或 /gh-release/owner/repo/tag/asset")
+
+// githubTarget 将 /gh-raw 和 /gh-release 路径转换为GitHub地址，ok表示路径属于GitHub源站
+func (p *Proxy) githubTarget(cfg *config.Config, proxyPath string) (host string, targetURL string, ok bool, err error) {
+	var rest string
+	var release bool
+	switch {
+	case strings.HasPrefix(proxyPath, githubRawPrefix+"/"):
+		rest = strings.TrimPrefix(proxyPath, githubRawPrefix+"/")
+	case strings.HasPrefix(proxyPath, githubReleasePrefix+"/"):
+		rest = strings.TrimPrefix(proxyPath, githubReleasePrefix+"/")
+		release = true
+	default:
+		return "", "", false, nil
+	}
+
+	source := p.sourceOfType(cfg, config.SourceTypeGitHub)
+	if source == nil {
+		return "", "", false, nil
+	}
+
+	segments := strings.Split(rest, "/")
+	if !validGitHubSegments(segments, release) {
+		return "", "", true, errInvalidGitHubPath
+	}
+	owner, repo, ref := segments[0], segments[1], segments[2]
+
+	if release {
+		// latest 指向最新发布的同名附件
+		if ref == "latest" {
+			return source.Domain, "https://" + source.Domain + "/" + owner + "/" + repo + "/releases/latest/download/" + segments[3], true, nil
+		}
+		return source.Domain, "https://" + source.Domain + "/" + owner + "/" + repo + "/releases/download/" + ref + "/" + segments[3], true, nil
+	}
+	return githubRawHost, "https://" + githubRawHost + "/" + rest, true, nil
+}
+
+// validGitHubSegments 校验路径段：raw为 owner/repo/ref/path...，release为 owner/repo/tag/asset
+func validGitHubSegments(segments []string, release bool) bool {
+	if release && len(segments) != 4 || !release && len(segments) < 4 {
+		return false
+	}
+	if !githubNamePattern.MatchString(segments[0]) || !githubNamePattern.MatchString(segments[1]) {
+		return false
+	}
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// githubMirrorPath 将GitHub地址转换为 /gh-raw 或 /gh-release 路径
+// 支持 raw.githubusercontent.com 文件、仓库的 blob/raw 页面地址和发布附件地址
+func (p *Proxy) githubMirrorPath(cfg *config.Config, u *url.URL) (string, bool) {
+	source := p.sourceOfType(cfg, config.SourceTypeGitHub)
+	if source == nil || u.Scheme != "https" {
+		return "", false
+	}
+
+	segments := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	switch u.Host {
+	case githubRawHost:
+		if validGitHubSegments(segments, false) {
+			return githubRawPrefix + u.Path, true
+		}
+
+	case source.Domain:
+		if len(segments) < 4 {
+			return "", false
+		}
+		owner, repo := segments[0], segments[1]
+		switch {
+		// owner/repo/blob/ref/path 和 owner/repo/raw/ref/path
+		case segments[2] == "blob" || segments[2] == "raw":
+			raw := append([]string{owner, repo}, segments[3:]...)
+			if validGitHubSegments(raw, false) {
+				return githubRawPrefix + "/" + strings.Join(raw, "/"), true
+			}
+		// owner/repo/releases/download/tag/asset
+		case len(segments) == 6 && segments[2] == "releases" && segments[3] == "download":
+			asset := []string{owner, repo, segments[4], segments[5]}
+			if validGitHubSegments(asset, true) {
+				return githubReleasePrefix + "/" + strings.Join(asset, "/"), true
+			}
+		// owner/repo/releases/latest/download/asset
+		case len(segments) == 6 && segments[2] == "releases" && segments[3] == "latest" && segments[4] == "download":
+			asset := []string{owner, repo, "latest", segments[5]}
+			if validGitHubSegments(asset, true) {
+				return githubReleasePrefix + "/" + strings.Join(asset, "/"), true
+			}
+		}
+	}
+	return "", false
+}
+
+// isGitHubURL 判断URL是否属于GitHub源站
+func (p *Proxy) isGitHubURL(cfg *config.Config, u *url.URL) bool {
+	source := p.sourceFor(cfg, u.Host)
+	return source != nil && source.Type == config.SourceTypeGitHub
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"static-mirrors/internal/cache"
+	"static-mirrors/pkg/config"
+)
+
+func TestGitHubTarget(t *testing.T) {
+	cfg := config.Config{Sources: []config.SourceConfig{
+		{Name: "github", Type: config.SourceTypeGitHub, Domain: "github.com", Enabled: true},
+	}}
+	p := &Proxy{}
+	tests := []struct {
+		path   string
+		target string
+		ok     bool
+		err    bool
+	}{
+		{"/gh-raw/owner/repo/main/dist/app.js", "https://raw.githubusercontent.com/owner/repo/main/dist/app.js", true, false},
+		{"/gh-release/owner/repo/v1.0.0/app.zip", "https://github.com/owner/repo/releases/download/v1.0.0/app.zip", true, false},
+		{"/gh-release/owner/repo/latest/app.zip", "https://github.com/owner/repo/releases/latest/download/app.zip", true, false},
+		{"/gh-raw/owner/repo/main", "", true, true},
+		{"/gh-raw/owner/repo/main/../../etc/passwd", "", true, true},
+		{"/gh-raw/own:er/repo/main/a.js", "", true, true},
+		{"/gh-release/owner/repo/v1/dir/app.zip", "", true, true},
+		{"/npm/vue", "", false, false},
+	}
+
+	for _, tt := range tests {
+		_, target, ok, err := p.githubTarget(&cfg, tt.path)
+		if target != tt.target || ok != tt.ok || (err != nil) != tt.err {
+			t.Errorf("githubTarget(%s) = %s, %v, %v", tt.path, target, ok, err)
+		}
+	}
+
+	// 没有启用GitHub源站时不处理
+	if _, _, ok, _ := p.githubTarget(&config.Config{}, "/gh-raw/owner/repo/main/a.js"); ok {
+		t.Error("未启用GitHub源站时不应处理 /gh-raw 路径")
+	}
+}
+
+func TestGitHubMirrorPath(t *testing.T) {
+	cfg := config.Config{Sources: []config.SourceConfig{
+		{Name: "github", Type: config.SourceTypeGitHub, Domain: "github.com", Enabled: true},
+	}}
+	p := &Proxy{}
+	tests := []struct {
+		url  string
+		want string
+		ok   bool
+	}{
+		{"https://raw.githubusercontent.com/owner/repo/main/a.js", "/gh-raw/owner/repo/main/a.js", true},
+		{"https://github.com/owner/repo/blob/main/src/a.js", "/gh-raw/owner/repo/main/src/a.js", true},
+		{"https://github.com/owner/repo/raw/v1/a.js", "/gh-raw/owner/repo/v1/a.js", true},
+		{"https://github.com/owner/repo/releases/download/v1/app.zip", "/gh-release/owner/repo/v1/app.zip", true},
+		{"https://github.com/owner/repo/releases/latest/download/app.zip", "/gh-release/owner/repo/latest/app.zip", true},
+		{"https://github.com/owner/repo/issues/1", "", false},
+		{"https://github.com/owner/repo", "", false},
+		{"http://raw.githubusercontent.com/owner/repo/main/a.js", "", false},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got, ok := p.githubMirrorPath(&cfg, u); got != tt.want || ok != tt.ok {
+			t.Errorf("githubMirrorPath(%s) = %s, %v, want %s, %v", tt.url, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGitHubReleaseAsset(t *testing.T) {
+	const asset = "release asset content"
+	var authorization string
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host {
+		case "objects.githubusercontent.com":
+			w.Write([]byte(asset))
+		case githubRawHost:
+			w.Write([]byte("raw file"))
+		default:
+			// 发布附件重定向到带签名的对象存储地址
+			authorization = r.Header.Get("Authorization")
+			http.Redirect(w, r, "https://objects.githubusercontent.com/signed?path="+url.QueryEscape(r.URL.Path), http.StatusFound)
+		}
+	})
+	p, r := newTestProxy(t, up, config.SourceConfig{Name: "github", Type: config.SourceTypeGitHub, Token: "ghp_test"}, true)
+	routeAll(p, up)
+
+	for _, path := range []string{"/gh-release/owner/repo/v1/app.zip", "/gh-release/owner/repo/latest/app.zip"} {
+		w := get(r, path)
+		if w.Code != http.StatusOK || w.Body.String() != asset {
+			t.Fatalf("GET %s status = %d, body %q", path, w.Code, w.Body.String())
+		}
+	}
+	if authorization != "Bearer ghp_test" {
+		t.Fatalf("回源请求 Authorization = %q", authorization)
+	}
+
+	// 两个地址指向同一内容，只保存一份响应体
+	if n, _ := p.cache.(cache.Counter).Len(); n != 3 {
+		t.Fatalf("缓存条目数 = %d, want 3", n)
+	}
+	if w := get(r, "/gh-release/owner/repo/v1/app.zip"); w.Header().Get("X-Mirror-Cache") != CacheHit || w.Body.String() != asset {
+		t.Fatalf("X-Mirror-Cache = %s, body %q", w.Header().Get("X-Mirror-Cache"), w.Body.String())
+	}
+
+	if w := get(r, "/gh-raw/owner/repo/main/a.js"); w.Code != http.StatusOK || w.Body.String() != "raw file" {
+		t.Fatalf("raw status = %d, body %q", w.Code, w.Body.String())
+	}
+	if w := get(r, "/gh-raw/owner/repo/main/../x"); w.Code == http.StatusOK {
+		t.Fatalf("无效路径 status = %d", w.Code)
+	}
+}
@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GOPROXY协议的请求类型
+const (
+	goProxyList   = "list"
+	goProxyLatest = "latest"
+	goProxyInfo   = ".info"
+	goProxyMod    = ".mod"
+	goProxyZip    = ".zip"
+)
+
+// goProxyRequest 解析后的GOPROXY请求
+type goProxyRequest struct {
+	// 模块路径和版本号（未编码）
+	module  string
+	version string
+	kind    string
+}
+
+// path 返回大小写编码后的上游路径
+func (r goProxyRequest) path() string {
+	switch r.kind {
+	case goProxyList:
+		return "/" + goEscape(r.module) + "/@v/list"
+	case goProxyLatest:
+		return "/" + goEscape(r.module) + "/@latest"
+	default:
+		return "/" + goEscape(r.module) + "/@v/" + goEscape(r.version) + r.kind
+	}
+}
+
+// serveGoProxy 处理GOPROXY协议请求：.mod和.zip永久缓存，list、@latest和.info短期缓存
+func (p *Proxy) serveGoProxy(c *gin.Context, source *config.SourceConfig, host string, targetURL string) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		c.String(405, "method not allowed")
+		return
+	}
+
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		c.String(400, "无效的URL格式")
+		return
+	}
+
+	if strings.HasPrefix(parsedURL.Path, "/sumdb/") {
+		p.serveSumDB(c, source, host, parsedURL)
+		return
+	}
+
+	// go命令把404和410视为模块或版本不存在
+	req, err := parseGoProxyPath(parsedURL.Path)
+	if err != nil {
+		c.String(404, "not found: %v", err)
+		return
+	}
+
+	switch req.kind {
+	case goProxyMod, goProxyZip:
+		c.Set(cachePolicyKey, &cachePolicy{immutable: true})
+	default:
+		c.Set(cachePolicyKey, &cachePolicy{ttl: metadataTTL(source)})
+	}
+
+	// 统一使用大小写编码后的路径，未编码的请求与编码后的请求共用缓存
+	parsedURL.Path = req.path()
+	parsedURL.RawPath = ""
+	parsedURL.RawQuery = ""
+	p.forward(c, host, parsedURL.String())
+}
+
+// serveSumDB 通过上游代理透传校验和数据库请求（/sumdb/<name>/...），tile不可变，其余短期缓存
+func (p *Proxy) serveSumDB(c *gin.Context, source *config.SourceConfig, host string, parsedURL *url.URL) {
+	rest := strings.TrimPrefix(parsedURL.Path, "/sumdb/")
+	name, endpoint, _ := strings.Cut(rest, "/")
+	if source.SumDB == "" || name != source.SumDB || endpoint == "" || strings.Contains(endpoint, "..") {
+		c.String(404, "not found: 不支持的校验和数据库 %s", name)
+		return
+	}
+
+	if strings.HasPrefix(endpoint, "tile/") {
+		c.Set(cachePolicyKey, &cachePolicy{immutable: true})
+	} else {
+		c.Set(cachePolicyKey, &cachePolicy{ttl: metadataTTL(source)})
+	}
+	p.forward(c, host, parsedURL.String())
+}
+
+// parseGoProxyPath 解析 <module>/@v/list、<module>/@v/<version>.(info|mod|zip) 和 <module>/@latest
+func parseGoProxyPath(path string) (goProxyRequest, error) {
+	path = strings.TrimPrefix(path, "/")
+
+	var req goProxyRequest
+	var escapedModule string
+	if strings.HasSuffix(path, "/@latest") {
+		escapedModule = strings.TrimSuffix(path, "/@latest")
+		req.kind = goProxyLatest
+	} else {
+		i := strings.LastIndex(path, "/@v/")
+		if i < 0 {
+			return req, fmt.Errorf("无效的GOPROXY路径: %s", path)
+		}
+		escapedModule = path[:i]
+		file := path[i+len("/@v/"):]
+
+		switch {
+		case file == "list":
+			req.kind = goProxyList
+		case strings.HasSuffix(file, goProxyInfo):
+			req.kind = goProxyInfo
+		case strings.HasSuffix(file, goProxyMod):
+			req.kind = goProxyMod
+		case strings.HasSuffix(file, goProxyZip):
+			req.kind = goProxyZip
+		default:
+			return req, fmt.Errorf("无效的GOPROXY路径: %s", path)
+		}
+
+		if req.kind != goProxyList {
+			version, err := goUnescape(strings.TrimSuffix(file, req.kind))
+			if err != nil {
+				return req, err
+			}
+			if version == "" || strings.ContainsAny(version, "/\\") {
+				return req, fmt.Errorf("无效的版本号: %s", version)
+			}
+			req.version = version
+		}
+	}
+
+	module, err := goUnescape(escapedModule)
+	if err != nil {
+		return req, err
+	}
+	if err := checkModulePath(module); err != nil {
+		return req, err
+	}
+	req.module = module
+	return req, nil
+}
+
+// checkModulePath 校验模块路径，路径元素不能为空或为 . 和 ..
+func checkModulePath(module string) error {
+	if module == "" {
+		return fmt.Errorf("模块路径为空")
+	}
+	for _, element := range strings.Split(module, "/") {
+		if element == "" || element == "." || element == ".." {
+			return fmt.Errorf("无效的模块路径: %s", module)
+		}
+		for _, r := range element {
+			if !strings.ContainsRune("-._~+", r) && (r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+				return fmt.Errorf("无效的模块路径: %s", module)
+			}
+		}
+	}
+	return nil
+}
+
+// goEscape 按GOPROXY协议对模块路径和版本号进行大小写编码：大写字母编码为 ! 加小写字母
+func goEscape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if 'A' <= r && r <= 'Z' {
+			sb.WriteByte('!')
+			sb.WriteRune(unicode.ToLower(r))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// goUnescape 解码大小写编码的模块路径和版本号，同时接受未编码的大写字母
+func goUnescape(s string) (string, error) {
+	var sb strings.Builder
+	bang := false
+	for _, r := range s {
+		switch {
+		case bang:
+			if r < 'a' || r > 'z' {
+				return "", fmt.Errorf("无效的大小写编码: %s", s)
+			}
+			sb.WriteRune(unicode.ToUpper(r))
+			bang = false
+		case r == '!':
+			bang = true
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	if bang {
+		return "", fmt.Errorf("无效的大小写编码: %s", s)
+	}
+	return sb.String(), nil
+}
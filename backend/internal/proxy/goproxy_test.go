@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"static-mirrors/pkg/config"
+)
+
+func TestGoEscape(t *testing.T) {
+	tests := []struct {
+		raw     string
+		escaped string
+	}{
+		{"github.com/gin-gonic/gin", "github.com/gin-gonic/gin"},
+		{"github.com/Azure/azure-sdk-for-go", "github.com/!azure/azure-sdk-for-go"},
+		{"github.com/BurntSushi/toml", "github.com/!burnt!sushi/toml"},
+		{"v1.0.0-RC1", "v1.0.0-!r!c1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if got := goEscape(tt.raw); got != tt.escaped {
+				t.Fatalf("goEscape(%q) = %q, want %q", tt.raw, got, tt.escaped)
+			}
+			got, err := goUnescape(tt.escaped)
+			if err != nil || got != tt.raw {
+				t.Fatalf("goUnescape(%q) = %q, %v, want %q", tt.escaped, got, err, tt.raw)
+			}
+		})
+	}
+}
+
+func TestGoUnescapeInvalid(t *testing.T) {
+	for _, escaped := range []string{"github.com/!", "github.com/!A", "github.com/!1"} {
+		if _, err := goUnescape(escaped); err == nil {
+			t.Errorf("goUnescape(%q) 应返回错误", escaped)
+		}
+	}
+}
+
+func TestParseGoProxyPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		want     goProxyRequest
+		upstream string
+		wantErr  bool
+	}{
+		{
+			path:     "/github.com/!azure/sdk/@v/list",
+			want:     goProxyRequest{module: "github.com/Azure/sdk", kind: goProxyList},
+			upstream: "/github.com/!azure/sdk/@v/list",
+		},
+		{
+			path:     "/github.com/!azure/sdk/@latest",
+			want:     goProxyRequest{module: "github.com/Azure/sdk", kind: goProxyLatest},
+			upstream: "/github.com/!azure/sdk/@latest",
+		},
+		{
+			// 未编码的大写字母同样接受，上游路径统一编码
+			path:     "/github.com/Azure/sdk/@v/v1.2.0.info",
+			want:     goProxyRequest{module: "github.com/Azure/sdk", version: "v1.2.0", kind: goProxyInfo},
+			upstream: "/github.com/!azure/sdk/@v/v1.2.0.info",
+		},
+		{
+			path:     "/golang.org/x/text/@v/v0.3.0.mod",
+			want:     goProxyRequest{module: "golang.org/x/text", version: "v0.3.0", kind: goProxyMod},
+			upstream: "/golang.org/x/text/@v/v0.3.0.mod",
+		},
+		{
+			path:     "/golang.org/x/text/@v/v0.3.0-!r!c1.zip",
+			want:     goProxyRequest{module: "golang.org/x/text", version: "v0.3.0-RC1", kind: goProxyZip},
+			upstream: "/golang.org/x/text/@v/v0.3.0-!r!c1.zip",
+		},
+		{path: "/golang.org/x/text", wantErr: true},
+		{path: "/golang.org/x/text/@v/v0.3.0.tar", wantErr: true},
+		{path: "/golang.org/x/text/@v/.mod", wantErr: true},
+		{path: "/golang.org/../text/@v/list", wantErr: true},
+		{path: "/golang.org//text/@v/list", wantErr: true},
+		{path: "/golang.org/x/te xt/@v/list", wantErr: true},
+		{path: "/golang.org/!/text/@v/list", wantErr: true},
+		{path: "/@v/list", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := parseGoProxyPath(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseGoProxyPath(%q) = %+v, 应返回错误", tt.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGoProxyPath(%q) error = %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Fatalf("parseGoProxyPath(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+			if path := got.path(); path != tt.upstream {
+				t.Fatalf("path() = %q, want %q", path, tt.upstream)
+			}
+		})
+	}
+}
+
+func TestGoProxy(t *testing.T) {
+	// 只认编码后路径的本地GOPROXY
+	files := map[string]string{
+		"/github.com/!azure/sdk/@v/list":        "v1.0.0\nv1.1.0\n",
+		"/github.com/!azure/sdk/@latest":        `{"Version":"v1.1.0"}`,
+		"/github.com/!azure/sdk/@v/v1.1.0.info": `{"Version":"v1.1.0"}`,
+		"/github.com/!azure/sdk/@v/v1.1.0.mod":  "module github.com/Azure/sdk\n",
+		"/github.com/!azure/sdk/@v/v1.1.0.zip":  "PK-zip-content",
+		"/sumdb/sum.golang.org/latest":          "go.sum database tree\n",
+		"/sumdb/sum.golang.org/tile/8/0/000":    "tile",
+	}
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	})
+	_, r := newTestProxy(t, up, config.SourceConfig{
+		Name:   "goproxy",
+		Type:   config.SourceTypeGoProxy,
+		Prefix: "/goproxy",
+		SumDB:  "sum.golang.org",
+	}, true)
+
+	tests := []struct {
+		name      string
+		path      string
+		status    int
+		body      string
+		immutable bool
+	}{
+		{"版本列表", "/goproxy/github.com/!azure/sdk/@v/list", 200, files["/github.com/!azure/sdk/@v/list"], false},
+		{"最新版本", "/goproxy/github.com/!azure/sdk/@latest", 200, files["/github.com/!azure/sdk/@latest"], false},
+		{"版本信息", "/goproxy/github.com/!azure/sdk/@v/v1.1.0.info", 200, files["/github.com/!azure/sdk/@v/v1.1.0.info"], false},
+		{"go.mod", "/goproxy/github.com/!azure/sdk/@v/v1.1.0.mod", 200, files["/github.com/!azure/sdk/@v/v1.1.0.mod"], true},
+		{"模块压缩包", "/goproxy/github.com/!azure/sdk/@v/v1.1.0.zip", 200, files["/github.com/!azure/sdk/@v/v1.1.0.zip"], true},
+		{"未编码的路径", "/goproxy/github.com/Azure/sdk/@v/v1.1.0.mod", 200, files["/github.com/!azure/sdk/@v/v1.1.0.mod"], true},
+		{"校验和数据库", "/goproxy/sumdb/sum.golang.org/latest", 200, files["/sumdb/sum.golang.org/latest"], false},
+		{"校验和数据库tile", "/goproxy/sumdb/sum.golang.org/tile/8/0/000", 200, "tile", true},
+		{"未配置的校验和数据库", "/goproxy/sumdb/sum.example.com/latest", 404, "", false},
+		{"无效路径", "/goproxy/github.com/!azure/sdk", 404, "", false},
+		{"上游不存在的版本", "/goproxy/github.com/!azure/sdk/@v/v9.9.9.info", 404, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(r, tt.path)
+			if w.Code != tt.status {
+				t.Fatalf("GET %s status = %d, want %d, body %s", tt.path, w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != 200 {
+				return
+			}
+			if w.Body.String() != tt.body {
+				t.Fatalf("GET %s body = %q, want %q", tt.path, w.Body.String(), tt.body)
+			}
+			if immutable := strings.Contains(w.Header().Get("Cache-Control"), "immutable"); immutable != tt.immutable {
+				t.Fatalf("GET %s Cache-Control = %q, want immutable %v", tt.path, w.Header().Get("Cache-Control"), tt.immutable)
+			}
+		})
+	}
+}
+
+func TestGoProxyCacheSharedAcrossEncodings(t *testing.T) {
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/github.com/!azure/sdk/@v/v1.1.0.mod" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("module github.com/Azure/sdk\n"))
+	})
+	_, r := newTestProxy(t, up, config.SourceConfig{
+		Name:   "goproxy",
+		Type:   config.SourceTypeGoProxy,
+		Prefix: "/goproxy",
+	}, true)
+
+	// 编码和未编码的请求使用同一个缓存键，只回源一次
+	for _, path := range []string{
+		"/goproxy/github.com/!azure/sdk/@v/v1.1.0.mod",
+		"/goproxy/github.com/Azure/sdk/@v/v1.1.0.mod",
+	} {
+		if w := get(r, path); w.Code != 200 {
+			t.Fatalf("GET %s status = %d", path, w.Code)
+		}
+	}
+	if hits := up.hits.Load(); hits != 1 {
+		t.Fatalf("回源 %d 次, want 1", hits)
+	}
+}
@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"static-mirrors/pkg/config"
+	"static-mirrors/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// helmIndex chart仓库的索引文件名
+const helmIndex = "index.yaml"
+
+// index.yaml 中的 urls 字段及其列表项
+var (
+	helmURLsPattern    = regexp.MustCompile(`^(\s*(?:-\s+)?)urls:\s*$`)
+	helmURLItemPattern = regexp.MustCompile(`^(\s*)-\s+(\S.*?)\s*$`)
+)
+
+// serveHelm 处理Helm chart仓库请求：index.yaml短期缓存并改写chart地址，chart包永久缓存
+func (p *Proxy) serveHelm(c *gin.Context, source *config.SourceConfig, host string, targetURL string) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		c.JSON(405, gin.H{"error": "Helm镜像只支持下载"})
+		return
+	}
+
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的URL格式"})
+		return
+	}
+
+	switch name := path.Base(parsedURL.Path); {
+	case name == helmIndex:
+		c.Set(cachePolicyKey, &cachePolicy{ttl: metadataTTL(source)})
+		cfg := p.requestConfig(c)
+		base := p.requestBaseURL(c)
+		p.serveRewritten(c, host, targetURL, func(body []byte) []byte {
+			rewritten, unsupported := p.rewriteHelmIndex(cfg, body, base)
+			p.reportHelmUnsupported(c, host, unsupported)
+			return rewritten
+		})
+	case strings.HasSuffix(name, ".tgz"), strings.HasSuffix(name, ".tgz.prov"):
+		c.Set(cachePolicyKey, &cachePolicy{immutable: true})
+		p.forward(c, host, targetURL)
+	default:
+		c.JSON(404, gin.H{"error": "Helm镜像只支持 index.yaml 和 chart 包"})
+	}
+}
+
+// chart地址无法通过镜像下载的原因
+const (
+	helmUnsupportedOCI        = "oci"
+	helmUnsupportedScheme     = "scheme"
+	helmUnsupportedNotAllowed = "not_allowed"
+	helmUnsupportedInvalid    = "invalid"
+)
+
+// helmUnsupportedNotes 写入index.yaml行尾注释的说明
+var helmUnsupportedNotes = map[string]string{
+	helmUnsupportedOCI:        "不支持 oci:// 地址，请直接从OCI仓库拉取",
+	helmUnsupportedScheme:     "只支持 http(s) 地址",
+	helmUnsupportedNotAllowed: "不在源站白名单中",
+	helmUnsupportedInvalid:    "无效的chart地址",
+}
+
+// rewriteHelmIndex 逐行改写index.yaml中chart的urls列表，返回改写结果和按原因统计的无法镜像的地址数
+// 相对地址本身就指向镜像，保持不变；源站白名单中的地址改写为镜像地址；
+// oci:// 和白名单以外的地址保持原样，在行尾注释说明原因，并在文件开头汇总数量
+func (p *Proxy) rewriteHelmIndex(cfg *config.Config, body []byte, base string) ([]byte, map[string]int) {
+	lines := bytes.Split(body, []byte("\n"))
+	inURLs := false
+	indent := 0
+	unsupported := make(map[string]int)
+	total := 0
+
+	for i, line := range lines {
+		// urls也可能是列表项的第一个键（- urls:），列表项的缩进按键所在的列计算
+		if m := helmURLsPattern.FindSubmatch(line); m != nil {
+			inURLs = true
+			indent = len(m[1])
+			continue
+		}
+		if !inURLs {
+			continue
+		}
+
+		m := helmURLItemPattern.FindSubmatch(line)
+		if m == nil || len(m[1]) < indent {
+			inURLs = false
+			continue
+		}
+
+		rewritten, reason := p.helmChartURL(cfg, unquoteYAML(string(m[2])), base)
+		switch {
+		case rewritten != "":
+			lines[i] = []byte(string(m[1]) + "- " + rewritten)
+		case reason != "":
+			lines[i] = []byte(string(line) + " # static-mirrors: " + helmUnsupportedNotes[reason])
+			unsupported[reason]++
+			total++
+		}
+	}
+
+	rewritten := bytes.Join(lines, []byte("\n"))
+	if total > 0 {
+		header := fmt.Sprintf("# static-mirrors: %d 个chart地址不支持通过镜像下载，详见对应行的注释\n", total)
+		rewritten = append([]byte(header), rewritten...)
+	}
+	return rewritten, unsupported
+}
+
+// reportHelmUnsupported 通过响应头、告警日志和指标报告无法通过镜像下载的chart地址
+// 响应头格式为 原因=数量，多个原因以逗号分隔，如 X-Mirror-Unsupported-Charts: oci=3, not_allowed=1
+func (p *Proxy) reportHelmUnsupported(c *gin.Context, host string, unsupported map[string]int) {
+	if len(unsupported) == 0 {
+		return
+	}
+
+	reasons := make([]string, 0, len(unsupported))
+	for reason := range unsupported {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	parts := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		parts = append(parts, fmt.Sprintf("%s=%d", reason, unsupported[reason]))
+		metrics.HelmUnsupportedURLs.With(host, reason).Add(float64(unsupported[reason]))
+	}
+	summary := strings.Join(parts, ", ")
+	c.Header("X-Mirror-Unsupported-Charts", summary)
+	p.log(c).Warn("Helm索引中有chart地址不支持通过镜像下载", "unsupported", summary)
+}
+
+// helmChartURL 返回chart地址改写后的镜像地址，无法镜像时返回原因
+func (p *Proxy) helmChartURL(cfg *config.Config, chartURL string, base string) (string, string) {
+	target, err := url.Parse(chartURL)
+	if err != nil {
+		return "", helmUnsupportedInvalid
+	}
+	if target.Scheme == "oci" {
+		return "", helmUnsupportedOCI
+	}
+	if !target.IsAbs() {
+		return "", ""
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return "", helmUnsupportedScheme
+	}
+	if p.sourceFor(cfg, target.Host) == nil {
+		return "", helmUnsupportedNotAllowed
+	}
+	return base + p.mirrorPath(cfg, target), ""
+}
+
+// unquoteYAML 去掉YAML标量两侧的引号
+func unquoteYAML(value string) string {
+	if len(value) >= 2 && (value[0] == '"' && value[len(value)-1] == '"' || value[0] == '\'' && value[len(value)-1] == '\'') {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
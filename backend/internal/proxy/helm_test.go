@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"static-mirrors/pkg/config"
+)
+
+func TestRewriteHelmIndex(t *testing.T) {
+	cfg := config.Config{Sources: []config.SourceConfig{
+		{Name: "helm", Type: config.SourceTypeHelm, Domain: "charts.example.com", Prefix: "/helm", Enabled: true},
+	}}
+	p := &Proxy{}
+	index := `apiVersion: v1
+entries:
+  demo:
+  - name: demo
+    urls:
+    - https://charts.example.com/demo-1.0.0.tgz
+    - "https://charts.example.com/demo-1.0.0-mirror.tgz"
+    - demo-1.0.0-relative.tgz
+    - oci://registry.example.com/demo
+    - https://other.example.com/demo-1.0.0.tgz
+    - ftp://charts.example.com/demo.tgz
+    version: 1.0.0
+  - name: demo
+    urls:
+      - https://charts.example.com/demo-0.9.0.tgz
+    version: 0.9.0
+generated: "2024-01-01T00:00:00Z"
+`
+
+	rewritten, unsupported := p.rewriteHelmIndex(&cfg, []byte(index), "https://mirror.example.com")
+	out := string(rewritten)
+	for _, want := range []string{
+		"# static-mirrors: 3 个chart地址不支持通过镜像下载",
+		"    - https://mirror.example.com/helm/demo-1.0.0.tgz\n",
+		"    - https://mirror.example.com/helm/demo-1.0.0-mirror.tgz\n",
+		"    - demo-1.0.0-relative.tgz\n",
+		"    - oci://registry.example.com/demo # static-mirrors: " + helmUnsupportedNotes[helmUnsupportedOCI],
+		"    - https://other.example.com/demo-1.0.0.tgz # static-mirrors: " + helmUnsupportedNotes[helmUnsupportedNotAllowed],
+		"    - ftp://charts.example.com/demo.tgz # static-mirrors: " + helmUnsupportedNotes[helmUnsupportedScheme],
+		"      - https://mirror.example.com/helm/demo-0.9.0.tgz\n",
+		"    version: 1.0.0\n",
+		`generated: "2024-01-01T00:00:00Z"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("改写结果缺少 %q\n%s", want, out)
+		}
+	}
+
+	want := map[string]int{helmUnsupportedOCI: 1, helmUnsupportedNotAllowed: 1, helmUnsupportedScheme: 1}
+	if len(unsupported) != len(want) {
+		t.Fatalf("unsupported = %v, want %v", unsupported, want)
+	}
+	for reason, n := range want {
+		if unsupported[reason] != n {
+			t.Fatalf("unsupported = %v, want %v", unsupported, want)
+		}
+	}
+
+	// urls为列表项的第一个键
+	rewritten, _ = p.rewriteHelmIndex(&cfg, []byte("entries:\n  demo:\n  - urls:\n    - https://charts.example.com/demo.tgz\n    version: 1.0.0\n"), "")
+	if want := "  - urls:\n    - /helm/demo.tgz\n    version: 1.0.0\n"; !strings.Contains(string(rewritten), want) {
+		t.Fatalf("rewriteHelmIndex() = %s, want %s", rewritten, want)
+	}
+
+	// 全部可以镜像时不添加汇总注释
+	rewritten, unsupported = p.rewriteHelmIndex(&cfg, []byte("entries:\n  demo:\n  - urls:\n    - demo.tgz\n"), "")
+	if strings.Contains(string(rewritten), "static-mirrors") || len(unsupported) != 0 {
+		t.Fatalf("rewriteHelmIndex() = %s, %v", rewritten, unsupported)
+	}
+}
+
+func TestHelm(t *testing.T) {
+	var up *testUpstream
+	up = newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stable/index.yaml":
+			w.Write([]byte("entries:\n  demo:\n  - urls:\n    - https://" + up.host() + "/stable/demo-1.0.0.tgz\n    - oci://registry.example.com/demo\n"))
+		case "/stable/demo-1.0.0.tgz":
+			w.Write([]byte("chart"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	_, r := newTestProxy(t, up, config.SourceConfig{Name: "helm", Type: config.SourceTypeHelm, Prefix: "/helm"}, true)
+
+	w := get(r, "/helm/stable/index.yaml")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "- http://example.com/helm/stable/demo-1.0.0.tgz\n") {
+		t.Fatalf("chart地址没有改写为镜像地址:\n%s", w.Body.String())
+	}
+	if got := w.Header().Get("X-Mirror-Unsupported-Charts"); got != "oci=1" {
+		t.Fatalf("X-Mirror-Unsupported-Charts = %q", got)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=300" {
+		t.Fatalf("index.yaml Cache-Control = %q", got)
+	}
+
+	w = get(r, "/helm/stable/demo-1.0.0.tgz")
+	if w.Code != http.StatusOK || w.Body.String() != "chart" || !strings.Contains(w.Header().Get("Cache-Control"), "immutable") {
+		t.Fatalf("chart status = %d, body %q, Cache-Control %q", w.Code, w.Body.String(), w.Header().Get("Cache-Control"))
+	}
+
+	if w := get(r, "/helm/stable/README.md"); w.Code != http.StatusNotFound {
+		t.Fatalf("其他文件 status = %d, want 404", w.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/helm/stable/demo-1.0.0.tgz", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// 延迟测试参数
+const (
+	// MaxLatencySamples 单次测试的最大采样次数，每次采样会分别请求源站和镜像
+	MaxLatencySamples = 5
+	latencyTimeout    = 5 * time.Second
+)
+
+// LatencySample 单次请求的各阶段耗时（毫秒）
+type LatencySample struct {
+	Status      int     `json:"status"`
+	Cache       string  `json:"cache,omitempty"`
+	Bytes       int64   `json:"bytes"`
+	Reused      bool    `json:"reused"`
+	DNS         float64 `json:"dns_ms"`
+	Connect     float64 `json:"connect_ms"`
+	TLS         float64 `json:"tls_ms"`
+	TTFB        float64 `json:"ttfb_ms"`
+	Transfer    float64 `json:"transfer_ms"`
+	Total       float64 `json:"total_ms"`
+	Error       string  `json:"error,omitempty"`
+	requestedAt time.Time
+}
+
+// LatencyStats 多次采样的统计值（毫秒）
+type LatencyStats struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min_ms"`
+	Median float64 `json:"median_ms"`
+	P95    float64 `json:"p95_ms"`
+	Max    float64 `json:"max_ms"`
+}
+
+// LatencyReport 延迟测试报告
+type LatencyReport struct {
+	URL     string          `json:"url"`
+	Samples []LatencySample `json:"samples"`
+	// 全部成功采样的总耗时与首字节时间
+	Total LatencyStats `json:"total"`
+	TTFB  LatencyStats `json:"ttfb"`
+	// 新建连接（包含DNS、连接和TLS握手）与复用连接的总耗时对比，只反映连接复用，与镜像缓存无关
+	NewConnection    LatencyStats `json:"new_connection"`
+	ReusedConnection LatencyStats `json:"reused_connection"`
+	// 按镜像缓存状态（X-Mirror-Cache）分组的总耗时
+	ByCache map[string]LatencyStats `json:"by_cache,omitempty"`
+}
+
+// MeasureLatency 对URL进行多次采样，记录DNS、连接、TLS、首字节和传输耗时
+// 所有采样共用一个连接池，第一次需要建立连接，之后的请求通常复用连接
+func (p *Proxy) MeasureLatency(ctx context.Context, target string, samples int) (*LatencyReport, error) {
+	if samples <= 0 {
+		samples = 1
+	}
+	if samples > MaxLatencySamples {
+		samples = MaxLatencySamples
+	}
+
+	// 只允许测试源站白名单中的公网地址
+	parsedURL, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.guard.CheckURL(parsedURL); err != nil {
+		return nil, err
+	}
+
+	transport := p.guard.Transport()
+	transport.MaxIdleConnsPerHost = 1
+	transport.IdleConnTimeout = 30 * time.Second
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Timeout: latencyTimeout, Transport: transport, CheckRedirect: p.guard.CheckRedirect}
+
+	report := &LatencyReport{URL: target}
+	for i := 0; i < samples; i++ {
+		sample := measureOnce(ctx, client, target)
+		report.Samples = append(report.Samples, sample)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// 全部失败时返回第一次的错误
+	var ok []LatencySample
+	for _, sample := range report.Samples {
+		if sample.Error == "" {
+			ok = append(ok, sample)
+		}
+	}
+	if len(ok) == 0 {
+		return nil, fmt.Errorf("%s", report.Samples[0].Error)
+	}
+
+	report.Total = summarizeLatency(ok, func(s LatencySample) float64 { return s.Total })
+	report.TTFB = summarizeLatency(ok, func(s LatencySample) float64 { return s.TTFB })
+
+	var fresh, reused []LatencySample
+	for _, sample := range ok {
+		if sample.Reused {
+			reused = append(reused, sample)
+		} else {
+			fresh = append(fresh, sample)
+		}
+	}
+	report.NewConnection = summarizeLatency(fresh, func(s LatencySample) float64 { return s.Total })
+	report.ReusedConnection = summarizeLatency(reused, func(s LatencySample) float64 { return s.Total })
+
+	groups := make(map[string][]LatencySample)
+	for _, sample := range ok {
+		if sample.Cache != "" {
+			groups[sample.Cache] = append(groups[sample.Cache], sample)
+		}
+	}
+	if len(groups) > 0 {
+		report.ByCache = make(map[string]LatencyStats, len(groups))
+		for status, group := range groups {
+			report.ByCache[status] = summarizeLatency(group, func(s LatencySample) float64 { return s.Total })
+		}
+	}
+
+	return report, nil
+}
+
+// measureOnce 发送一次请求并通过httptrace记录各阶段耗时
+func measureOnce(ctx context.Context, client *http.Client, target string) LatencySample {
+	var sample LatencySample
+	var dnsStart, connectStart, tlsStart time.Time
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			sample.DNS = milliseconds(time.Since(dnsStart))
+		},
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(string, string, error) {
+			sample.Connect = milliseconds(time.Since(connectStart))
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			sample.TLS = milliseconds(time.Since(tlsStart))
+		},
+		GotConn: func(info httptrace.GotConnInfo) { sample.Reused = info.Reused },
+		GotFirstResponseByte: func() {
+			sample.TTFB = milliseconds(time.Since(sample.requestedAt))
+		},
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, target, nil)
+	if err != nil {
+		sample.Error = err.Error()
+		return sample
+	}
+
+	sample.requestedAt = time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		sample.Error = err.Error()
+		return sample
+	}
+	defer resp.Body.Close()
+
+	// 读取响应体以确保完整请求
+	sample.Bytes, _ = io.Copy(io.Discard, resp.Body)
+	sample.Total = milliseconds(time.Since(sample.requestedAt))
+	sample.Transfer = round2(sample.Total - sample.TTFB)
+	sample.Status = resp.StatusCode
+	sample.Cache = resp.Header.Get("X-Mirror-Cache")
+	return sample
+}
+
+// summarizeLatency 计算成功采样的统计值
+func summarizeLatency(samples []LatencySample, value func(LatencySample) float64) LatencyStats {
+	values := make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		if sample.Error == "" {
+			values = append(values, value(sample))
+		}
+	}
+	return summarizeValues(values)
+}
+
+// summarizeValues 计算最小值、中位数、P95和最大值
+func summarizeValues(values []float64) LatencyStats {
+	if len(values) == 0 {
+		return LatencyStats{}
+	}
+	sort.Float64s(values)
+
+	return LatencyStats{
+		Count:  len(values),
+		Min:    values[0],
+		Median: round2(percentile(values, 0.5)),
+		P95:    round2(percentile(values, 0.95)),
+		Max:    values[len(values)-1],
+	}
+}
+
+// percentile 计算已排序数据的百分位数（线性插值）
+func percentile(sorted []float64, q float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// milliseconds 将时长转换为保留两位小数的毫秒数
+func milliseconds(d time.Duration) float64 {
+	return round2(float64(d) / float64(time.Millisecond))
+}
+
+// round2 保留两位小数
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
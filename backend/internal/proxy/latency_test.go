@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"static-mirrors/internal/outbound"
+	"static-mirrors/pkg/config"
+)
+
+func TestSummarizeValues(t *testing.T) {
+	stats := summarizeValues([]float64{5, 1, 4, 2, 3})
+	want := LatencyStats{Count: 5, Min: 1, Median: 3, P95: 4.8, Max: 5}
+	if stats != want {
+		t.Fatalf("summarizeValues() = %+v, want %+v", stats, want)
+	}
+	if stats := summarizeValues(nil); stats != (LatencyStats{}) {
+		t.Fatalf("summarizeValues(nil) = %+v", stats)
+	}
+	if stats := summarizeValues([]float64{7}); stats.Median != 7 || stats.P95 != 7 {
+		t.Fatalf("summarizeValues([7]) = %+v", stats)
+	}
+}
+
+func TestMeasureLatency(t *testing.T) {
+	var hits atomic.Int64
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("content"))
+	}))
+	t.Cleanup(up.Close)
+	host := strings.TrimPrefix(up.URL, "http://")
+
+	cfg := config.Config{}
+	cfg.Security.AllowPrivateNetworks = true
+	cfg.Sources = []config.SourceConfig{{Name: "test", Domain: host, Prefix: "/test", Enabled: true}}
+	p := NewProxy(cfg, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// 采样次数不超过上限，第一次建立连接，之后复用连接
+	report, err := p.MeasureLatency(context.Background(), up.URL+"/file.js", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Samples) != MaxLatencySamples || hits.Load() != MaxLatencySamples {
+		t.Fatalf("采样 %d 次，源站请求 %d 次，want %d", len(report.Samples), hits.Load(), MaxLatencySamples)
+	}
+	for i, sample := range report.Samples {
+		if sample.Status != http.StatusOK || sample.Bytes != int64(len("content")) {
+			t.Fatalf("第%d次采样 = %+v", i, sample)
+		}
+		if sample.Reused != (i > 0) {
+			t.Fatalf("第%d次采样 reused = %v", i, sample.Reused)
+		}
+	}
+	if report.Total.Count != MaxLatencySamples {
+		t.Fatalf("Total.Count = %d", report.Total.Count)
+	}
+	if report.NewConnection.Count != 1 || report.ReusedConnection.Count != MaxLatencySamples-1 {
+		t.Fatalf("新建连接 %d 次，复用连接 %d 次", report.NewConnection.Count, report.ReusedConnection.Count)
+	}
+
+	// 不在源站白名单中的地址不发起请求
+	if _, err := p.MeasureLatency(context.Background(), "http://example.com/", 1); !outbound.IsBlocked(err) {
+		t.Fatalf("MeasureLatency() error = %v, want blocked", err)
+	}
+}
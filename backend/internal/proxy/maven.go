@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mavenMetadata 仓库元数据文件名，会随新版本发布而变化
+const mavenMetadata = "maven-metadata.xml"
+
+// mavenChecksumExts 校验和与签名文件的扩展名
+var mavenChecksumExts = []string{".sha1", ".sha256", ".sha512", ".md5", ".asc"}
+
+// serveMaven 处理Maven仓库请求：元数据短期缓存，校验和文件永久缓存，制品校验通过后永久缓存
+func (p *Proxy) serveMaven(c *gin.Context, source *config.SourceConfig, host string, targetURL string) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		c.JSON(405, gin.H{"error": "Maven镜像只支持下载，不支持部署"})
+		return
+	}
+
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的URL格式"})
+		return
+	}
+
+	name := path.Base(parsedURL.Path)
+	snapshot := strings.Contains(parsedURL.Path, "-SNAPSHOT/")
+	switch {
+	// 元数据及其校验和、目录列表和快照版本都会变化
+	case strings.HasPrefix(name, mavenMetadata), strings.HasSuffix(parsedURL.Path, "/"):
+		c.Set(cachePolicyKey, &cachePolicy{ttl: metadataTTL(source)})
+		p.forward(c, host, targetURL)
+	case isMavenChecksum(name):
+		if snapshot {
+			c.Set(cachePolicyKey, &cachePolicy{ttl: metadataTTL(source)})
+		} else {
+			c.Set(cachePolicyKey, &cachePolicy{immutable: true})
+		}
+		p.forward(c, host, targetURL)
+	default:
+		policy := &cachePolicy{immutable: true}
+		if snapshot {
+			policy = &cachePolicy{ttl: metadataTTL(source)}
+		}
+		p.serveMavenArtifact(c, host, targetURL, policy)
+	}
+}
+
+// serveMavenArtifact 下载制品并与源站公布的校验和比对，校验失败时拒绝响应且不写入缓存
+func (p *Proxy) serveMavenArtifact(c *gin.Context, host string, targetURL string, policy *cachePolicy) {
+	cfg := p.requestConfig(c)
+	c.Set(cachePolicyKey, policy)
+	if c.Request.Method != http.MethodGet {
+		p.forward(c, host, targetURL)
+		return
+	}
+
+	// 缓存中的制品在写入时已经校验过
+	if p.cache != nil && !c.GetBool(refreshKey) {
+		if entry := p.lookupCache(c, variantCacheKey(c, targetURL)); entry != nil && entry.Fresh() {
+			p.forward(c, host, targetURL)
+			return
+		}
+	}
+
+	sum, err := p.mavenChecksum(c.Request.Context(), host, targetURL)
+	if err != nil {
+		c.JSON(502, gin.H{"error": "获取制品校验和失败", "details": err.Error()})
+		return
+	}
+	if sum == nil {
+		p.log(c).Info("制品没有公布校验和，跳过校验", "url", targetURL)
+		p.forward(c, host, targetURL)
+		return
+	}
+	policy.checksum = sum
+
+	// 不超过 cache.max_object_size 的制品先完整接收，校验失败时返回502；
+	// 更大的制品边转发边校验，校验通过后才写出最后一个字节，失败时中止响应
+	w := newVerifyingWriter(c.Writer, sum, p.maxObjectSize(cfg))
+	c.Set(fullBodyKey, true)
+	c.Writer = w
+	p.forward(c, host, targetURL)
+	c.Writer = w.ResponseWriter
+
+	if w.streaming {
+		if actual, ok := w.finish(); !ok {
+			p.log(c).Warn("制品校验失败，已中止响应", "url", targetURL, "expected", sum.String(), "actual", actual)
+			// 由net/http中止响应：HTTP/1.1关闭连接，HTTP/2发送RST_STREAM，
+			// 客户端不会把缺少最后一个字节的响应当作正常结束
+			panic(http.ErrAbortHandler)
+		}
+		return
+	}
+
+	if w.Status() == http.StatusOK {
+		if actual, ok := sum.verify(w.body.Bytes()); !ok {
+			p.log(c).Warn("制品校验失败", "url", targetURL, "expected", sum.String(), "actual", actual)
+			// 丢弃已经设置的源站响应头
+			for k := range c.Writer.Header() {
+				c.Writer.Header().Del(k)
+			}
+			c.JSON(502, gin.H{"error": "制品校验失败", "expected": sum.String(), "actual": actual})
+			return
+		}
+	}
+	if err := w.flush(c, w.body.Bytes()); err != nil {
+		p.log(c).Warn("写入响应失败", "error", err)
+	}
+}
+
+// verifyingWriter 校验制品摘要的ResponseWriter
+// 响应体不超过limit时与bufferedWriter一样暂存；超过后改为流式转发并计算摘要，
+// 始终保留最后一个字节，由finish在校验通过后写出
+type verifyingWriter struct {
+	*bufferedWriter
+	sum  *checksum
+	hash hash.Hash
+	// pending 流式转发时尚未写出的最后一个字节
+	pending []byte
+}
+
+// newVerifyingWriter 创建校验摘要的ResponseWriter
+func newVerifyingWriter(w gin.ResponseWriter, sum *checksum, limit int64) *verifyingWriter {
+	return &verifyingWriter{
+		bufferedWriter: &bufferedWriter{ResponseWriter: w, limit: limit},
+		sum:            sum,
+		hash:           sum.newHash(),
+	}
+}
+
+// Write 暂存或转发响应体，同时计算摘要
+func (w *verifyingWriter) Write(b []byte) (int, error) {
+	w.hash.Write(b)
+	if w.streaming {
+		return len(b), w.stream(b)
+	}
+	if int64(w.body.Len()+len(b)) <= w.limit {
+		return w.body.Write(b)
+	}
+
+	// 超过暂存上限，写出状态码和已暂存的内容，之后直接转发
+	return len(b), w.stream(w.startStreaming(b))
+}
+
+// WriteString 暂存或转发响应体，同时计算摘要
+func (w *verifyingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// stream 写出上次保留的字节和本次除最后一个字节以外的内容
+func (w *verifyingWriter) stream(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if len(w.pending) > 0 {
+		if _, err := w.ResponseWriter.Write(w.pending); err != nil {
+			return err
+		}
+	}
+	if _, err := w.ResponseWriter.Write(data[:len(data)-1]); err != nil {
+		return err
+	}
+	w.pending = []byte{data[len(data)-1]}
+	return nil
+}
+
+// finish 结束流式转发，非200响应和校验通过的制品写出最后一个字节并返回true；
+// 校验失败时不写出，由调用方中止响应。流式转发的响应带有源站的Content-Length，
+// 客户端收到的内容缺少最后一个字节，也无法通过客户端自身的校验
+func (w *verifyingWriter) finish() (string, bool) {
+	if w.Status() == http.StatusOK {
+		if actual, ok := w.sum.match(w.hash); !ok {
+			return actual, false
+		}
+	}
+	if len(w.pending) > 0 {
+		// 客户端已断开时写入失败，与普通转发一样忽略
+		w.ResponseWriter.Write(w.pending)
+	}
+	return "", true
+}
+
+// mavenChecksum 获取制品公布的校验和，优先使用sha256，都不存在时返回nil
+// 校验和文件同样经过镜像缓存
+func (p *Proxy) mavenChecksum(ctx context.Context, host string, targetURL string) (*checksum, error) {
+	for _, algorithm := range []string{checksumSHA256, checksumSHA1} {
+		result, err := p.serveInternal(ctx, host, targetURL+"."+algorithm, false, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		switch result.status {
+		case http.StatusOK:
+			// 校验和文件的内容可能带有文件名，只取第一段
+			fields := strings.Fields(string(result.head))
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("%s校验和为空", algorithm)
+			}
+			value := strings.ToLower(fields[0])
+			if algorithm == checksumSHA1 && !sha1Pattern.MatchString(value) ||
+				algorithm == checksumSHA256 && !sha256Pattern.MatchString(value) {
+				return nil, fmt.Errorf("无效的%s校验和: %s", algorithm, value)
+			}
+			return &checksum{algorithm: algorithm, value: value}, nil
+		case http.StatusNotFound:
+			continue
+		default:
+			return nil, fmt.Errorf("获取%s校验和失败: HTTP %d", algorithm, result.status)
+		}
+	}
+	return nil, nil
+}
+
+// isMavenChecksum 判断文件是否为校验和或签名文件
+func isMavenChecksum(name string) bool {
+	for _, ext := range mavenChecksumExts {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
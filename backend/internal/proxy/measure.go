@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timingKey 上下文中记录反代流程耗时的键
+const timingKey = "mirror.timing"
+
+// pipelineTiming 一次反代请求中各阶段的耗时
+type pipelineTiming struct {
+	cacheLookup time.Duration
+	upstream    time.Duration
+	transfer    time.Duration
+}
+
+// pipelineTimingOf 获取请求上下文中的耗时记录，未开启时返回nil
+func pipelineTimingOf(c *gin.Context) *pipelineTiming {
+	if v, exists := c.Get(timingKey); exists {
+		if timing, ok := v.(*pipelineTiming); ok {
+			return timing
+		}
+	}
+	return nil
+}
+
+// addCacheLookup 记录缓存查询耗时
+func (t *pipelineTiming) addCacheLookup(start time.Time) {
+	if t != nil {
+		t.cacheLookup += time.Since(start)
+	}
+}
+
+// addUpstream 记录等待源站响应头的耗时
+func (t *pipelineTiming) addUpstream(start time.Time) {
+	if t != nil {
+		t.upstream += time.Since(start)
+	}
+}
+
+// addTransfer 记录响应体传输耗时
+func (t *pipelineTiming) addTransfer(start time.Time) {
+	if t != nil {
+		t.transfer += time.Since(start)
+	}
+}
+
+// MirrorSample 镜像处理一次请求的各阶段耗时（毫秒）
+type MirrorSample struct {
+	Status      int     `json:"status"`
+	Cache       string  `json:"cache,omitempty"`
+	Bytes       int64   `json:"bytes"`
+	CacheLookup float64 `json:"cache_lookup_ms"`
+	Upstream    float64 `json:"upstream_ms"`
+	Transfer    float64 `json:"transfer_ms"`
+	Total       float64 `json:"total_ms"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// MirrorLatencyReport 镜像处理耗时报告
+type MirrorLatencyReport struct {
+	URL         string         `json:"url"`
+	MirrorPath  string         `json:"mirror_path"`
+	Samples     []MirrorSample `json:"samples"`
+	Total       LatencyStats   `json:"total"`
+	CacheLookup LatencyStats   `json:"cache_lookup"`
+	Upstream    LatencyStats   `json:"upstream"`
+	Transfer    LatencyStats   `json:"transfer"`
+	// 按每次请求实际的缓存状态（X-Mirror-Cache）分组的总耗时
+	// 缓存中已有该URL时第一次请求就会命中，因此不能按请求顺序区分冷热
+	ByCache map[string]LatencyStats `json:"by_cache,omitempty"`
+}
+
+// MeasureMirror 在进程内运行完整的反代流程（缓存查询与回源），测量镜像处理请求的耗时
+// 不经过网络回环，因此结果只反映镜像自身的开销与回源时间
+func (p *Proxy) MeasureMirror(ctx context.Context, target string, samples int) (*MirrorLatencyReport, error) {
+	cfg := p.cfg()
+	if samples <= 0 {
+		samples = 1
+	}
+	if samples > MaxLatencySamples {
+		samples = MaxLatencySamples
+	}
+
+	host, targetURL, err := p.resolveTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MirrorLatencyReport{
+		URL:        targetURL,
+		MirrorPath: p.mirrorPath(cfg, parsedURL),
+	}
+	for i := 0; i < samples && ctx.Err() == nil; i++ {
+		report.Samples = append(report.Samples, p.measureMirrorOnce(ctx, host, targetURL))
+	}
+
+	var ok []MirrorSample
+	for _, sample := range report.Samples {
+		if sample.Error == "" {
+			ok = append(ok, sample)
+		}
+	}
+	if len(ok) == 0 {
+		return nil, fmt.Errorf("%s", report.Samples[0].Error)
+	}
+
+	values := func(field func(MirrorSample) float64, samples []MirrorSample) []float64 {
+		result := make([]float64, 0, len(samples))
+		for _, sample := range samples {
+			result = append(result, field(sample))
+		}
+		return result
+	}
+	total := func(s MirrorSample) float64 { return s.Total }
+
+	report.Total = summarizeValues(values(total, ok))
+	report.CacheLookup = summarizeValues(values(func(s MirrorSample) float64 { return s.CacheLookup }, ok))
+	report.Upstream = summarizeValues(values(func(s MirrorSample) float64 { return s.Upstream }, ok))
+	report.Transfer = summarizeValues(values(func(s MirrorSample) float64 { return s.Transfer }, ok))
+
+	groups := make(map[string][]MirrorSample)
+	for _, sample := range ok {
+		if sample.Cache != "" {
+			groups[sample.Cache] = append(groups[sample.Cache], sample)
+		}
+	}
+	if len(groups) > 0 {
+		report.ByCache = make(map[string]LatencyStats, len(groups))
+		for status, group := range groups {
+			report.ByCache[status] = summarizeValues(values(total, group))
+		}
+	}
+
+	return report, nil
+}
+
+// measureMirrorOnce 在进程内处理一次请求并记录各阶段耗时
+func (p *Proxy) measureMirrorOnce(ctx context.Context, host string, targetURL string) MirrorSample {
+	timing := &pipelineTiming{}
+	start := time.Now()
+	result, err := p.serveInternal(ctx, host, targetURL, false, timing)
+	if err != nil {
+		return MirrorSample{Error: err.Error()}
+	}
+
+	sample := MirrorSample{
+		Status:      result.status,
+		Cache:       result.cache,
+		Bytes:       result.size,
+		CacheLookup: milliseconds(timing.cacheLookup),
+		Upstream:    milliseconds(timing.upstream),
+		Transfer:    milliseconds(timing.transfer),
+		Total:       milliseconds(time.Since(start)),
+	}
+	if result.status >= 500 {
+		sample.Error = strings.TrimSpace(string(result.head))
+		if sample.Error == "" {
+			sample.Error = http.StatusText(result.status)
+		}
+	}
+	return sample
+}
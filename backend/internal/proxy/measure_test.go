@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"static-mirrors/pkg/config"
+)
+
+func TestMeasureMirror(t *testing.T) {
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=600")
+		w.Write([]byte("content"))
+	})
+	p, _ := newTestProxy(t, up, config.SourceConfig{Name: "test", Prefix: "/test"}, true)
+	target := "https://" + up.host() + "/file.js"
+
+	report, err := p.MeasureMirror(context.Background(), target, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.MirrorPath != "/test/file.js" {
+		t.Fatalf("MirrorPath = %s", report.MirrorPath)
+	}
+	if len(report.Samples) != 3 || report.Samples[0].Cache != CacheMiss || report.Samples[0].Bytes != int64(len("content")) {
+		t.Fatalf("Samples = %+v", report.Samples)
+	}
+	if report.ByCache[CacheMiss].Count != 1 || report.ByCache[CacheHit].Count != 2 {
+		t.Fatalf("ByCache = %+v", report.ByCache)
+	}
+
+	// 缓存中已有内容时第一次请求同样命中，按实际缓存状态分组
+	report, err = p.MeasureMirror(context.Background(), target, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ByCache[CacheHit].Count != 2 || report.ByCache[CacheMiss].Count != 0 {
+		t.Fatalf("ByCache = %+v", report.ByCache)
+	}
+	if got := up.hits.Load(); got != 1 {
+		t.Fatalf("源站请求次数 = %d, want 1", got)
+	}
+}
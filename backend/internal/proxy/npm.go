@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// npmAbbreviatedMetadata npm客户端安装时请求的精简元数据格式
+const npmAbbreviatedMetadata = "application/vnd.npm.install-v1+json"
+
+// npmAbbreviatedVariant 精简元数据的缓存变体
+const npmAbbreviatedVariant = "abbreviated"
+
+// npmTarballPattern 匹配包元数据中的dist.tarball地址
+var npmTarballPattern = regexp.MustCompile(`("tarball"\s*:\s*")([^"]+)(")`)
+
+// serveNPM 处理npm registry请求：tarball永久缓存，包元数据短期缓存并把tarball地址改写为镜像地址
+func (p *Proxy) serveNPM(c *gin.Context, source *config.SourceConfig, host string, targetURL string) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		c.JSON(405, gin.H{"error": "npm镜像只支持下载，不支持发布等操作"})
+		return
+	}
+
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的URL格式"})
+		return
+	}
+
+	// tarball带版本号，内容不会变化
+	if isNPMTarball(parsedURL.Path) {
+		c.Set(cachePolicyKey, &cachePolicy{immutable: true})
+		p.forward(c, host, targetURL)
+		return
+	}
+
+	// 精简元数据和完整元数据分别缓存
+	c.Set(cachePolicyKey, &cachePolicy{ttl: metadataTTL(source)})
+	if strings.Contains(c.GetHeader("Accept"), npmAbbreviatedMetadata) {
+		c.Set(cacheVariantKey, npmAbbreviatedVariant)
+	}
+	c.Header("Vary", "Accept")
+
+	cfg := p.requestConfig(c)
+	base := p.requestBaseURL(c)
+	p.serveRewritten(c, host, targetURL, func(body []byte) []byte {
+		return p.rewriteTarballs(cfg, body, base)
+	})
+}
+
+// isNPMTarball 判断路径是否为tarball下载地址（<包名>/-/<文件名>.tgz）
+func isNPMTarball(path string) bool {
+	return strings.Contains(path, "/-/") && strings.HasSuffix(path, ".tgz")
+}
+
+// rewriteTarballs 将包元数据中指向npm源站的tarball地址改写为镜像地址
+func (p *Proxy) rewriteTarballs(cfg *config.Config, body []byte, base string) []byte {
+	return npmTarballPattern.ReplaceAllFunc(body, func(match []byte) []byte {
+		parts := npmTarballPattern.FindSubmatch(match)
+		tarballURL, err := url.Parse(string(parts[2]))
+		if err != nil {
+			return match
+		}
+		if source := p.sourceFor(cfg, tarballURL.Host); source == nil || source.Type != config.SourceTypeNPM {
+			return match
+		}
+
+		rewritten := make([]byte, 0, len(match)+len(base))
+		rewritten = append(rewritten, parts[1]...)
+		rewritten = append(rewritten, base+p.mirrorPath(cfg, tarballURL)...)
+		return append(rewritten, parts[3]...)
+	})
+}
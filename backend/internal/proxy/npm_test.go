@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"static-mirrors/pkg/config"
+)
+
+// npmMetadata 返回tarball指向host的包元数据
+func npmMetadata(host string) string {
+	return `{"name":"demo","versions":{"1.0.0":{"dist":{"tarball":"https://` + host + `/demo/-/demo-1.0.0.tgz"}},` +
+		`"0.9.0":{"dist":{"tarball":"https://other.example.com/demo/-/demo-0.9.0.tgz"}}}}`
+}
+
+func newNPMTestProxy(t *testing.T) (*testUpstream, *Proxy, http.Handler) {
+	t.Helper()
+	var up *testUpstream
+	up = newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/demo":
+			body := npmMetadata(up.host())
+			if strings.Contains(r.Header.Get("Accept"), npmAbbreviatedMetadata) {
+				body = strings.Replace(body, `"name"`, `"abbreviated":true,"name"`, 1)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write([]byte(body))
+		case r.URL.Path == "/demo/-/demo-1.0.0.tgz":
+			w.Write([]byte("tarball"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	p, r := newTestProxy(t, up, config.SourceConfig{Name: "npm", Type: config.SourceTypeNPM, Prefix: "/npm"}, true)
+	return up, p, r
+}
+
+func TestNPMMetadataRewrite(t *testing.T) {
+	up, _, r := newNPMTestProxy(t)
+
+	w := get(r, "/npm/demo")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"tarball":"http://example.com/npm/demo/-/demo-1.0.0.tgz"`) {
+		t.Fatalf("tarball地址没有改写为镜像地址: %s", body)
+	}
+	if !strings.Contains(body, `"tarball":"https://other.example.com/demo/-/demo-0.9.0.tgz"`) {
+		t.Fatalf("其他域名的tarball地址不应改写: %s", body)
+	}
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(body)) {
+		t.Fatalf("Content-Length = %s, want %d", got, len(body))
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=300" {
+		t.Fatalf("Cache-Control = %q", got)
+	}
+
+	// 精简元数据单独缓存，完整元数据再次请求时命中缓存
+	abbreviated := get(r, "/npm/demo", "Accept", npmAbbreviatedMetadata)
+	if !strings.Contains(abbreviated.Body.String(), `"abbreviated":true`) {
+		t.Fatalf("精简元数据 = %s", abbreviated.Body.String())
+	}
+	if w := get(r, "/npm/demo"); w.Header().Get("X-Mirror-Cache") != CacheHit || w.Body.String() != body {
+		t.Fatalf("完整元数据没有命中缓存: %s %s", w.Header().Get("X-Mirror-Cache"), w.Body.String())
+	}
+	if got := up.hits.Load(); got != 2 {
+		t.Fatalf("源站请求次数 = %d, want 2", got)
+	}
+}
+
+func TestNPMTarball(t *testing.T) {
+	_, _, r := newNPMTestProxy(t)
+
+	w := get(r, "/npm/demo/-/demo-1.0.0.tgz")
+	if w.Code != http.StatusOK || w.Body.String() != "tarball" {
+		t.Fatalf("status = %d, body %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Cache-Control"); !strings.Contains(got, "immutable") {
+		t.Fatalf("Cache-Control = %q, tarball应按不可变内容缓存", got)
+	}
+}
+
+func TestNPMPublishRejected(t *testing.T) {
+	_, _, r := newNPMTestProxy(t)
+	req := httptest.NewRequest(http.MethodPut, "/npm/demo", strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want 405", w.Code)
+	}
+}
+
+// TestRewriteOverMaxObjectSize 超过 cache.max_object_size 的元数据不暂存，按源站原始内容转发
+func TestRewriteOverMaxObjectSize(t *testing.T) {
+	up, p, r := newNPMTestProxy(t)
+	cfg := *p.cfg()
+	cfg.Cache.MaxObjectSize = 64
+	p.Reload(cfg)
+
+	w := get(r, "/npm/demo")
+	want := npmMetadata(up.host())
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(want)) {
+		t.Fatalf("Content-Length = %s, want %d", got, len(want))
+	}
+
+	// 过大的响应不写入缓存
+	get(r, "/npm/demo")
+	if got := up.hits.Load(); got != 2 {
+		t.Fatalf("源站请求次数 = %d, want 2", got)
+	}
+}
@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"static-mirrors/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refreshKey 上下文标记：忽略有效缓存，强制回源刷新
+const refreshKey = "mirror.refresh"
+
+// PrefetchResult 单个URL的预取结果
+type PrefetchResult struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Cache    string `json:"cache,omitempty"`
+	Size     int64  `json:"size"`
+	Duration int64  `json:"duration_ms"`
+	Error    string `json:"error,omitempty"`
+}
+
+// OK 预取是否成功
+func (r PrefetchResult) OK() bool {
+	return r.Error == "" && r.Status >= 200 && r.Status < 300
+}
+
+// Prefetch 通过完整的反代与缓存流程获取URL，用于缓存预热
+// target可以是源站完整URL，也可以是镜像路径（如 /npm/vue@3/dist/vue.js）
+func (p *Proxy) Prefetch(ctx context.Context, target string, refresh bool) PrefetchResult {
+	start := time.Now()
+	result := PrefetchResult{URL: target}
+
+	host, targetURL, err := p.resolveTarget(target)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	internal, err := p.serveInternal(ctx, host, targetURL, refresh, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = internal.status
+	result.Cache = internal.cache
+	result.Size = internal.size
+	result.Duration = time.Since(start).Milliseconds()
+	if result.Status >= 400 {
+		result.Error = strings.TrimSpace(string(internal.head))
+		if result.Error == "" {
+			result.Error = http.StatusText(result.Status)
+		}
+	}
+	return result
+}
+
+// internalResult 进程内请求的结果
+type internalResult struct {
+	status int
+	cache  string
+	size   int64
+	head   []byte
+}
+
+// serveInternal 在进程内通过完整的反代与缓存流程处理一次GET请求，响应体被丢弃
+func (p *Proxy) serveInternal(ctx context.Context, host string, targetURL string, refresh bool, timing *pipelineTiming) (internalResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return internalResult{}, err
+	}
+
+	w := &discardWriter{header: make(http.Header)}
+	c := gin.CreateTestContextOnly(w, p.engine)
+	c.Request = req
+	logging.Attach(c, p.logger, "")
+	if refresh {
+		c.Set(refreshKey, true)
+	}
+	if timing != nil {
+		c.Set(timingKey, timing)
+	}
+
+	if !serveAbortable(func() { p.serve(c, host, targetURL) }) {
+		return internalResult{}, fmt.Errorf("响应已中止")
+	}
+
+	result := internalResult{
+		status: c.Writer.Status(),
+		cache:  w.header.Get("X-Mirror-Cache"),
+		head:   w.head,
+	}
+	if size := c.Writer.Size(); size > 0 {
+		result.size = int64(size)
+	}
+	return result, nil
+}
+
+// serveAbortable 执行处理函数，处理器通过 http.ErrAbortHandler 中止响应（如制品校验失败）时返回false
+// 进程内请求没有net/http替它中止连接，其他panic继续抛出
+func serveAbortable(serve func()) (completed bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			if err != http.ErrAbortHandler {
+				panic(err)
+			}
+			completed = false
+		}
+	}()
+	serve()
+	return true
+}
+
+// resolveTarget 将完整URL或镜像路径解析为源站域名和目标URL
+func (p *Proxy) resolveTarget(target string) (string, string, error) {
+	cfg := p.cfg()
+	if strings.HasPrefix(target, "/") {
+		host, targetURL, err := p.resolvePath(cfg, target)
+		if err != nil {
+			return "", "", err
+		}
+		if p.isBlockedURL(cfg, targetURL) {
+			return "", "", fmt.Errorf("该URL已被封禁")
+		}
+		return host, targetURL, nil
+	}
+
+	parsedURL, err := url.Parse(target)
+	if err != nil || parsedURL.Host == "" {
+		return "", "", fmt.Errorf("无效的URL格式")
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return "", "", fmt.Errorf("不支持的协议: %s", parsedURL.Scheme)
+	}
+	if !p.isValidSource(cfg, parsedURL.Host) {
+		return "", "", fmt.Errorf("不支持的源站")
+	}
+	if p.isGitHubURL(cfg, parsedURL) {
+		if _, ok := p.githubMirrorPath(cfg, parsedURL); !ok {
+			return "", "", fmt.Errorf("不支持的GitHub地址")
+		}
+	}
+	if p.isBlockedURL(cfg, target) {
+		return "", "", fmt.Errorf("该URL已被封禁")
+	}
+	return parsedURL.Host, target, nil
+}
+
+// discardWriter 丢弃响应体的ResponseWriter，只保留开头部分用于错误信息
+type discardWriter struct {
+	header http.Header
+	head   []byte
+}
+
+// Header 返回响应头
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+// Write 丢弃响应体
+func (w *discardWriter) Write(b []byte) (int, error) {
+	if remaining := 512 - len(w.head); remaining > 0 {
+		if len(b) < remaining {
+			remaining = len(b)
+		}
+		w.head = append(w.head, b[:remaining]...)
+	}
+	return len(b), nil
+}
+
+// WriteHeader 写入状态码
+func (w *discardWriter) WriteHeader(int) {}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"static-mirrors/pkg/config"
+)
+
+func TestPrefetch(t *testing.T) {
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/lib/file.js" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=600")
+		w.Write([]byte("content"))
+	})
+	p, r := newTestProxy(t, up, config.SourceConfig{Name: "test", Prefix: "/test"}, true)
+	ctx := context.Background()
+
+	// 完整URL和镜像路径都可以预热，第二次命中缓存
+	result := p.Prefetch(ctx, "https://"+up.host()+"/lib/file.js", false)
+	if !result.OK() || result.Cache != CacheMiss || result.Size != int64(len("content")) {
+		t.Fatalf("Prefetch() = %+v", result)
+	}
+	if result := p.Prefetch(ctx, "/test/lib/file.js", false); !result.OK() || result.Cache != CacheHit {
+		t.Fatalf("Prefetch() = %+v", result)
+	}
+	if w := get(r, "/test/lib/file.js"); w.Header().Get("X-Mirror-Cache") != CacheHit {
+		t.Fatalf("预热后的请求没有命中缓存: %s", w.Header().Get("X-Mirror-Cache"))
+	}
+
+	// refresh忽略有效缓存，强制回源
+	if result := p.Prefetch(ctx, "/test/lib/file.js", true); !result.OK() || result.Cache != CacheMiss {
+		t.Fatalf("Prefetch(refresh) = %+v", result)
+	}
+	if got := up.hits.Load(); got != 2 {
+		t.Fatalf("源站请求次数 = %d, want 2", got)
+	}
+
+	// 源站错误和无法解析的地址记录为失败
+	if result := p.Prefetch(ctx, "/test/missing.js", false); result.OK() || result.Status != http.StatusNotFound || result.Error == "" {
+		t.Fatalf("Prefetch(missing) = %+v", result)
+	}
+	if result := p.Prefetch(ctx, "https://example.com/file.js", false); result.OK() || result.Error == "" {
+		t.Fatalf("Prefetch(未配置的源站) = %+v", result)
+	}
+}
@@ -1,48 +1,124 @@
 package proxy
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"static-mirrors/internal/cache"
+	"static-mirrors/internal/outbound"
 	"static-mirrors/pkg/config"
+	"static-mirrors/pkg/logging"
+	"static-mirrors/pkg/metrics"
 
 	"github.com/gin-gonic/gin"
 )
 
+// defaultSource 路径代理模式使用的默认源站
+const defaultSource = "cdn.jsdelivr.net"
+
+// purgeCountWindow cache.purge.max_purge_count 的统计周期
+const purgeCountWindow = time.Hour
+
+// 回源连接（含TLS握手）、等待响应头和读取响应体的默认超时
+const (
+	defaultConnectTimeout        = 5 * time.Second
+	defaultResponseHeaderTimeout = 15 * time.Second
+	defaultReadTimeout           = 30 * time.Second
+)
+
 // Proxy 反代服务结构
 type Proxy struct {
 	client          *http.Client
-	config          config.Config
+	guard           *outbound.Guard
+	config          atomic.Pointer[config.Config]
 	purgeRecords    map[string]time.Time
 	purgeMutex      sync.RWMutex
 	purgeCount      int
 	purgeCountMutex sync.Mutex
+	// purgeWindowStart 当前刷新次数统计周期的开始时间
+	purgeWindowStart time.Time
+	breakers         atomic.Pointer[breakerGroup]
+	cache            cache.Cache
+	offline          atomic.Bool
+	fills            *fillTracker
+	logger           *slog.Logger
+	// engine 用于在进程内构造请求上下文（缓存预热等）
+	engine *gin.Engine
 }
 
-// NewProxy 创建新的反代服务实例
-func NewProxy(cfg config.Config) *Proxy {
-	return &Proxy{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 100,
-				IdleConnTimeout:     90 * time.Second,
-			},
-		},
-		config:       cfg,
+// NewProxy 创建新的反代服务实例，cacheService为nil时不使用缓存
+func NewProxy(cfg config.Config, cacheService cache.Cache, logger *slog.Logger) *Proxy {
+	guard := newGuard(cfg)
+	p := &Proxy{
+		client:       newUpstreamClient(guard, cfg.Proxy),
+		guard:        guard,
 		purgeRecords: make(map[string]time.Time),
+		cache:        cacheService,
+		fills:        newFillTracker(),
+		logger:       logger,
+		engine:       gin.New(),
 	}
+	p.config.Store(&cfg)
+	p.offline.Store(cfg.Cache.Offline)
+
+	// 按源站启用熔断器
+	if cfg.Proxy.CircuitBreaker.Enabled {
+		p.breakers.Store(newBreakerGroup(cfg.Proxy.CircuitBreaker))
+	}
+
+	return p
+}
+
+// newUpstreamClient 创建回源客户端
+// 连接和等待响应头分别设置较短的超时，源站无响应时尽快失败，让熔断器及时统计到错误；
+// 不设置整体超时，大文件下载只要源站持续发送数据就不会被中断，源站停止发送超过读取超时时才失败
+func newUpstreamClient(guard *outbound.Guard, cfg config.ProxyConfig) *http.Client {
+	connectTimeout := durationOr(cfg.ConnectTimeout, defaultConnectTimeout)
+	transport := guard.Transport()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+		defer cancel()
+		return guard.DialContext(ctx, network, address)
+	}
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.ResponseHeaderTimeout = durationOr(cfg.ResponseHeaderTimeout, defaultResponseHeaderTimeout)
+
+	return &http.Client{
+		Transport: &readTimeoutTransport{
+			RoundTripper: transport,
+			timeout:      durationOr(cfg.ReadTimeout, defaultReadTimeout),
+		},
+		CheckRedirect: checkRedirect,
+	}
+}
+
+// durationOr 把以秒为单位的配置转换为时长，未配置时使用默认值
+func durationOr(seconds int, fallback time.Duration) time.Duration {
+	if seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// log 返回带有请求字段（请求ID、源站、缓存状态、回源状态码）的日志记录器
+func (p *Proxy) log(c *gin.Context) *slog.Logger {
+	return logging.For(c, p.logger)
 }
 
 // HandleMirror 处理镜像请求
 func (p *Proxy) HandleMirror(c *gin.Context) {
+	cfg := p.requestConfig(c)
 	// 获取目标URL
 	targetURL := c.Query("url")
 	if targetURL == "" {
@@ -58,90 +134,177 @@ func (p *Proxy) HandleMirror(c *gin.Context) {
 	}
 
 	// 验证源站是否在白名单中
-	if !p.isValidSource(parsedURL.Host) {
+	if !p.isValidSource(cfg, parsedURL.Host) {
 		c.JSON(403, gin.H{"error": "不支持的源站"})
 		return
 	}
 
+	// GitHub源站只代理原始文件和发布附件
+	if p.isGitHubURL(cfg, parsedURL) {
+		if _, ok := p.githubMirrorPath(cfg, parsedURL); !ok {
+			c.JSON(403, gin.H{"error": "不支持的GitHub地址"})
+			return
+		}
+	}
+
 	// 验证URL是否被封禁
-	if p.isBlockedURL(targetURL) {
+	if p.isBlockedURL(cfg, targetURL) {
 		c.JSON(403, gin.H{"error": "该URL已被封禁"})
 		return
 	}
 
-	// 创建请求
-	req, err := http.NewRequest(c.Request.Method, targetURL, c.Request.Body)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "创建请求失败"})
+	p.serve(c, parsedURL.Host, targetURL)
+}
+
+// HandlePathProxy 处理路径代理请求
+func (p *Proxy) HandlePathProxy(c *gin.Context, proxyPath string) {
+	cfg := p.requestConfig(c)
+	// 如果路径为空或根路径，返回前端页面
+	if proxyPath == "" || proxyPath == "/" {
+		c.File("./frontend/dist/index.html")
 		return
 	}
 
-	// 复制请求头
-	for k, v := range c.Request.Header {
-		if k != "Host" && k != "Content-Length" {
-			req.Header[k] = v
-		}
+	// 按路径前缀选择源站并构建目标URL，未匹配前缀时使用默认源站
+	host, targetURL, err := p.resolvePath(cfg, proxyPath)
+	if errors.Is(err, errPathBlocked) {
+		c.JSON(403, gin.H{"error": "该路径已被封禁"})
+		return
 	}
-
-	// 设置正确的Host头
-	req.Host = parsedURL.Host
-
-	// 发送请求到源站
-	resp, err := p.client.Do(req)
 	if err != nil {
-		c.JSON(502, gin.H{"error": "连接源站失败", "details": err.Error()})
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	defer resp.Body.Close()
+	if c.Request.URL.RawQuery != "" {
+		targetURL += "?" + c.Request.URL.RawQuery
+	}
 
-	// 复制响应头
-	for k, v := range resp.Header {
-		if k != "Content-Length" {
-			c.Header(k, strings.Join(v, ", "))
-		}
+	// 验证URL是否被封禁
+	if p.isBlockedURL(cfg, targetURL) {
+		c.JSON(403, gin.H{"error": "该URL已被封禁"})
+		return
 	}
 
-	// 设置缓存头
-	p.setCacheHeaders(c, resp)
+	p.serve(c, host, targetURL)
+}
 
-	// 设置响应状态码
-	c.Status(resp.StatusCode)
+// targetKey 请求上下文中保存实际回源目标的键
+const targetKey = "mirror.target"
 
-	// 复制响应体
-	if _, err := io.Copy(c.Writer, resp.Body); err != nil {
-		log.Printf("复制响应体失败: %v", err)
-	}
+// target 请求对应的源站和源站URL
+type target struct {
+	host string
+	url  string
+}
 
-	// 记录访问统计
-	// 这里将来会实现统计功能
+// Target 返回请求实际代理的源站和源站URL，请求被拒绝（如封禁、地址无效）时ok为false
+func Target(c *gin.Context) (host string, targetURL string, ok bool) {
+	t, ok := c.Value(targetKey).(target)
+	return t.host, t.url, ok
 }
 
-// HandlePathProxy 处理路径代理请求
-func (p *Proxy) HandlePathProxy(c *gin.Context, proxyPath string) {
-	// 如果路径为空或根路径，返回前端页面
-	if proxyPath == "" || proxyPath == "/" {
-		c.File("./frontend/dist/index.html")
-		return
+// serve 按源站类型处理请求，静态CDN直接转发
+func (p *Proxy) serve(c *gin.Context, host string, targetURL string) {
+	cfg := p.requestConfig(c)
+	logging.Set(c, logging.FieldSource, host)
+	c.Set(targetKey, target{host: host, url: targetURL})
+	if source := p.sourceFor(cfg, host); source != nil {
+		switch source.Type {
+		case config.SourceTypeNPM:
+			p.serveNPM(c, source, host, targetURL)
+			return
+		case config.SourceTypeGoProxy:
+			p.serveGoProxy(c, source, host, targetURL)
+			return
+		case config.SourceTypePyPI:
+			p.servePyPI(c, source, host, targetURL)
+			return
+		case config.SourceTypeMaven:
+			p.serveMaven(c, source, host, targetURL)
+			return
+		case config.SourceTypeHelm:
+			p.serveHelm(c, source, host, targetURL)
+			return
+		case config.SourceTypeFonts:
+			p.serveFonts(c, source, host, targetURL)
+			return
+		}
 	}
+	p.forward(c, host, targetURL)
+}
 
-	// 验证路径是否被封禁
-	if p.isBlockedPath(proxyPath) {
-		c.JSON(403, gin.H{"error": "该路径已被封禁"})
-		return
+// forward 将请求转发到源站并把响应写回客户端
+func (p *Proxy) forward(c *gin.Context, host string, targetURL string) {
+	cfg := p.requestConfig(c)
+	timing := pipelineTimingOf(c)
+
+	// 查询缓存
+	cacheable := p.isCacheable(c)
+	var key string
+	var cached *cache.Entry
+	if cacheable {
+		key = variantCacheKey(c, targetURL)
+		lookupStart := time.Now()
+		cached = p.lookupCache(c, key)
+		timing.addCacheLookup(lookupStart)
+		// 同一缓存键可能由不同摘要的链接请求，内容与本次请求的摘要不符时按未命中处理，也不作为陈旧内容使用
+		if cached != nil && !cachePolicyOf(c).matches(cached) {
+			p.log(c).Warn("缓存内容与请求的摘要不符，重新回源", "key", key, "expected", cachePolicyOf(c).checksum.String())
+			cached = nil
+		}
+		if cached != nil && cached.Fresh() && !c.GetBool(refreshKey) {
+			p.serveEntry(c, cached, CacheHit, "")
+			return
+		}
 	}
 
-	// 构建目标URL（使用cdn.jsdelivr.net作为默认源站）
-	targetURL := fmt.Sprintf("https://cdn.jsdelivr.net%s", proxyPath)
+	// 离线模式只从缓存提供内容
+	if p.Offline() {
+		if cached != nil {
+			p.serveEntry(c, cached, CacheStale, warningStale)
+			return
+		}
+		c.JSON(504, gin.H{"error": "离线模式下缓存未命中", "url": targetURL})
+		return
+	}
 
-	// 验证URL是否被封禁
-	if p.isBlockedURL(targetURL) {
-		c.JSON(403, gin.H{"error": "该URL已被封禁"})
+	// 登记回源请求，服务关闭时中止
+	fillCtx, done, ok := p.fills.begin(c.Request.Context())
+	if !ok {
+		if cached != nil {
+			p.serveEntry(c, cached, CacheStale, warningStale)
+			return
+		}
+		c.JSON(503, gin.H{"error": "服务正在关闭"})
 		return
 	}
+	defer done()
+
+	// 检查源站熔断状态
+	var breaker *circuitBreaker
+	probe := false
+	if breakers := p.breakers.Load(); breakers != nil {
+		breaker = breakers.get(host)
+		allowed, isProbe := breaker.allow()
+		if !allowed {
+			if cached != nil {
+				p.serveEntry(c, cached, CacheStale, warningStale)
+				return
+			}
+			p.rejectOpenCircuit(c, host, breaker)
+			return
+		}
+		probe = isProbe
+	}
 
-	// 创建请求
-	req, err := http.NewRequest(c.Request.Method, targetURL, c.Request.Body)
+	// 创建请求，按源站配置处理重定向
+	policy := p.redirectPolicy(cfg, host)
+	ctx := context.WithValue(fillCtx, redirectPolicyKey{}, policy)
+	req, err := http.NewRequestWithContext(ctx, c.Request.Method, targetURL, c.Request.Body)
 	if err != nil {
+		if breaker != nil {
+			breaker.cancel(probe)
+		}
 		c.JSON(500, gin.H{"error": "创建请求失败"})
 		return
 	}
@@ -153,56 +316,181 @@ func (p *Proxy) HandlePathProxy(c *gin.Context, proxyPath string) {
 		}
 	}
 
+	// 可缓存的请求和需要读取响应体的请求（元数据改写、校验和验证）必须拿到完整的未压缩响应，
+	// 条件请求由镜像自行处理；否则缓存关闭时源站返回的gzip或304会让改写失败
+	if cacheable || c.GetBool(fullBodyKey) {
+		req.Header.Del("Accept-Encoding")
+		req.Header.Del("If-None-Match")
+		req.Header.Del("If-Modified-Since")
+	}
+
+	// 使用服务端配置的源站令牌，避免匿名请求被限流
+	if source := p.sourceFor(cfg, host); source != nil && source.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+source.Token)
+	}
+
 	// 设置正确的Host头
-	req.Host = "cdn.jsdelivr.net"
+	req.Host = host
 
 	// 发送请求到源站
+	upstreamStart := time.Now()
 	resp, err := p.client.Do(req)
+	timing.addUpstream(upstreamStart)
+	metrics.UpstreamDuration.With(host).Observe(time.Since(upstreamStart).Seconds())
 	if err != nil {
+		if breaker != nil {
+			switch {
+			case c.Request.Context().Err() != nil, p.fills.aborted():
+				// 客户端主动断开和服务关闭不计入源站错误
+				breaker.cancel(probe)
+			case outbound.IsBlocked(err):
+				// 被出站守卫拦截的请求没有真正访问源站
+				breaker.cancel(probe)
+			case errors.Is(err, errRedirectNotAllowed):
+				// 源站可以访问，只是重定向目标不受支持
+				breaker.record(true, probe)
+			default:
+				breaker.record(false, probe)
+			}
+		}
+		if outbound.IsBlocked(err) {
+			c.JSON(403, gin.H{"error": "禁止访问的上游地址", "details": err.Error()})
+			return
+		}
+		if c.Request.Context().Err() == nil {
+			p.log(c).Warn("连接源站失败", "url", targetURL, "error", err)
+		}
+		if cached != nil {
+			p.serveEntry(c, cached, CacheStale, warningRevalidateFailed)
+			return
+		}
 		c.JSON(502, gin.H{"error": "连接源站失败", "details": err.Error()})
 		return
 	}
 	defer resp.Body.Close()
+	logging.Set(c, logging.FieldUpstreamStatus, resp.StatusCode)
+
+	if breaker != nil {
+		breaker.record(resp.StatusCode < 500, probe)
+	}
+
+	// 源站异常时优先返回陈旧缓存
+	if resp.StatusCode >= 500 && cached != nil {
+		p.serveEntry(c, cached, CacheStale, warningRevalidateFailed)
+		return
+	}
+
+	// 改写指向上游的重定向地址
+	p.rewriteLocation(cfg, resp, policy)
+
+	// 复制响应头并设置缓存头
+	p.writeHeaders(c, resp)
 
-	// 复制响应头
+	// 设置响应状态码
+	c.Status(resp.StatusCode)
+
+	// 只缓存大小在限制内的完整成功响应
+	var capture *captureWriter
+	if cacheable && resp.StatusCode == http.StatusOK && resp.ContentLength <= p.maxObjectSize(cfg) {
+		capture = &captureWriter{dst: c.Writer, limit: p.maxObjectSize(cfg)}
+	}
+
+	// 复制响应体
+	var dst io.Writer = c.Writer
+	if capture != nil {
+		dst = capture
+	}
+	transferStart := time.Now()
+	_, err = io.Copy(dst, resp.Body)
+	timing.addTransfer(transferStart)
+	if err != nil {
+		p.log(c).Warn("复制响应体失败", "url", targetURL, "error", err)
+		return
+	}
+
+	// 服务关闭时中止的下载可能不完整，不写入缓存
+	if p.fills.aborted() {
+		p.log(c).Info("服务正在关闭，放弃写入缓存", "url", targetURL)
+		return
+	}
+
+	if capture != nil && !capture.overflow {
+		p.storeCache(c, key, resp, c.Writer.Header().Get("Cache-Control"), capture.buf, cachePolicyOf(c))
+	}
+}
+
+// writeHeaders 复制源站响应头并设置缓存头
+func (p *Proxy) writeHeaders(c *gin.Context, resp *http.Response) {
 	for k, v := range resp.Header {
 		if k != "Content-Length" {
 			c.Header(k, strings.Join(v, ", "))
 		}
 	}
+	// 读取完整响应体的处理器暂存后会按实际内容重新设置长度；响应体过大改为流式转发时内容不变，
+	// 保留源站的长度，中止的响应在客户端看来不完整
+	if c.GetBool(fullBodyKey) && resp.ContentLength >= 0 {
+		c.Header("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
 
-	// 设置缓存头
 	p.setCacheHeaders(c, resp)
+}
 
-	// 设置响应状态码
-	c.Status(resp.StatusCode)
+// rejectOpenCircuit 源站熔断时快速失败
+func (p *Proxy) rejectOpenCircuit(c *gin.Context, host string, breaker *circuitBreaker) {
+	if retryAfter := breaker.retryAfter(); retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	}
+	c.JSON(503, gin.H{"error": "源站暂时不可用，已触发熔断", "source": host})
+}
 
-	// 复制响应体
-	if _, err := io.Copy(c.Writer, resp.Body); err != nil {
-		log.Printf("复制响应体失败: %v", err)
+// BreakerStatuses 获取各源站熔断器状态
+func (p *Proxy) BreakerStatuses() []BreakerStatus {
+	breakers := p.breakers.Load()
+	if breakers == nil {
+		return []BreakerStatus{}
 	}
+	return breakers.statuses()
 }
 
 // isValidSource 验证源站是否在白名单中
-func (p *Proxy) isValidSource(host string) bool {
-	for _, source := range p.config.Sources {
-		if source.Domain == host && source.Enabled {
-			return true
-		}
-	}
-	return false
+func (p *Proxy) isValidSource(cfg *config.Config, host string) bool {
+	return p.sourceFor(cfg, host) != nil
 }
 
 // isBlockedURL 验证URL是否被封禁
-func (p *Proxy) isBlockedURL(url string) bool {
-	for _, blockedPattern := range p.config.Security.BlockedURLs {
-		if strings.Contains(url, blockedPattern) {
+// 规则格式为 域名[/路径前缀]，域名不区分大小写，路径按整段匹配：
+// "cdn.jsdelivr.net/login" 封禁 /login 和 /login/...，不影响 /login-form；只写域名时封禁整个域名
+func (p *Proxy) isBlockedURL(cfg *config.Config, rawURL string) bool {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range cfg.Security.BlockedURLs {
+		if matchBlockedURL(pattern, target) {
 			return true
 		}
 	}
 	return false
 }
 
+// matchBlockedURL 判断URL是否命中一条封禁规则，规则中的协议前缀会被忽略
+func matchBlockedURL(pattern string, target *url.URL) bool {
+	pattern = strings.TrimSpace(pattern)
+	if i := strings.Index(pattern, "://"); i >= 0 {
+		pattern = pattern[i+3:]
+	}
+	host, prefix, _ := strings.Cut(pattern, "/")
+	if host == "" || !strings.EqualFold(host, target.Host) {
+		return false
+	}
+
+	prefix = strings.TrimSuffix("/"+prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	return strings.EqualFold(target.Path, prefix) || strings.HasPrefix(strings.ToLower(target.Path), strings.ToLower(prefix)+"/")
+}
+
 // isBlockedPath 验证路径是否被封禁
 func (p *Proxy) isBlockedPath(path string) bool {
 	blockedPaths := []string{
@@ -231,23 +519,26 @@ func (p *Proxy) isBlockedPath(path string) bool {
 
 // setCacheHeaders 设置缓存头
 func (p *Proxy) setCacheHeaders(c *gin.Context, resp *http.Response) {
+	cfg := p.requestConfig(c)
 	contentType := resp.Header.Get("Content-Type")
 	contentLength := resp.ContentLength
 	var cacheControl string
 
-	// 如果源站已经设置了缓存头，使用源站的缓存头
-	if resp.Header.Get("Cache-Control") != "" {
+	// 源站类型为成功响应指定了缓存策略时优先使用，其次使用源站的缓存头
+	if policy := cachePolicyOf(c); policy != nil && resp.StatusCode == http.StatusOK {
+		cacheControl = policy.cacheControl()
+	} else if resp.Header.Get("Cache-Control") != "" {
 		cacheControl = resp.Header.Get("Cache-Control")
 	} else {
 		// 根据文件大小和类型设置合理的缓存时间
-		cacheControl = p.calculateCacheControl(contentType, contentLength)
+		cacheControl = p.calculateCacheControl(cfg, contentType, contentLength)
 	}
 
 	c.Header("Cache-Control", cacheControl)
 
 	// 设置Expires头
 	if resp.Header.Get("Expires") == "" {
-		expiresTime := p.calculateExpires(cacheControl)
+		expiresTime := p.calculateExpires(cfg, cacheControl)
 		c.Header("Expires", expiresTime)
 	}
 
@@ -261,28 +552,39 @@ func (p *Proxy) setCacheHeaders(c *gin.Context, resp *http.Response) {
 		c.Header("Last-Modified", resp.Header.Get("Last-Modified"))
 	}
 
-	c.Header("X-Mirror-Cache", "MISS")
+	c.Header("X-Mirror-Cache", CacheMiss)
+	logging.Set(c, logging.FieldCache, CacheMiss)
 }
 
 // calculateCacheControl 根据文件类型和大小计算缓存控制
-func (p *Proxy) calculateCacheControl(contentType string, contentLength int64) string {
-	strategy := p.config.Cache.Strategy
-
-	// 首先根据文件类型判断
-	fileType := p.getFileType(contentType)
-	if ttl, exists := strategy.FileTypes[fileType]; exists {
-		return fmt.Sprintf("public, max-age=%d, s-maxage=%d, immutable", ttl, ttl)
-	}
-
-	// 根据文件大小判断
-	if contentLength > strategy.LargeFileThreshold {
-		return fmt.Sprintf("public, max-age=%d, s-maxage=%d, immutable", strategy.LargeFileTTL, strategy.LargeFileTTL)
+func (p *Proxy) calculateCacheControl(cfg *config.Config, contentType string, contentLength int64) string {
+	strategy := cfg.Cache.Strategy
+
+	// 首先根据文件类型判断，其次根据文件大小判断，默认使用普通文件缓存时间
+	// 源站没有给出缓存头的内容可能被原地更新，不标记immutable
+	ttl := strategy.NormalFileTTL
+	if fileTTL, exists := strategy.FileTypes[p.getFileType(contentType)]; exists {
+		ttl = fileTTL
+	} else if strategy.LargeFileThreshold > 0 && contentLength > strategy.LargeFileThreshold {
+		ttl = strategy.LargeFileTTL
 	} else if contentLength > 0 && contentLength < 1048576 {
-		return fmt.Sprintf("public, max-age=%d, s-maxage=%d", strategy.SmallFileTTL, strategy.SmallFileTTL)
+		ttl = strategy.SmallFileTTL
 	}
 
-	// 默认使用普通文件缓存时间
-	return fmt.Sprintf("public, max-age=%d, s-maxage=%d", strategy.NormalFileTTL, strategy.NormalFileTTL)
+	ttl = p.clampTTL(cfg, ttl)
+	return fmt.Sprintf("public, max-age=%d, s-maxage=%d", ttl, ttl)
+}
+
+// clampTTL 未配置的缓存时间使用 cache.ttl.default，超过 cache.ttl.max 时按上限计算
+func (p *Proxy) clampTTL(cfg *config.Config, ttl int64) int64 {
+	limits := cfg.Cache.TTL
+	if ttl <= 0 {
+		ttl = int64(limits.Default)
+	}
+	if limits.Max > 0 && ttl > int64(limits.Max) {
+		ttl = int64(limits.Max)
+	}
+	return ttl
 }
 
 // getFileType 根据Content-Type获取文件类型
@@ -308,10 +610,10 @@ func (p *Proxy) getFileType(contentType string) string {
 }
 
 // calculateExpires 根据Cache-Control计算Expires时间
-func (p *Proxy) calculateExpires(cacheControl string) string {
+func (p *Proxy) calculateExpires(cfg *config.Config, cacheControl string) string {
 	maxAge := p.extractMaxAge(cacheControl)
 	if maxAge <= 0 {
-		maxAge = int64(p.config.Cache.TTL.Default)
+		maxAge = int64(cfg.Cache.TTL.Default)
 	}
 
 	expiresTime := time.Now().Add(time.Duration(maxAge) * time.Second)
@@ -333,33 +635,10 @@ func (p *Proxy) extractMaxAge(cacheControl string) int64 {
 	return 0
 }
 
-// TestLatency 测试URL延迟
-func (p *Proxy) TestLatency(url string) (int64, error) {
-	start := time.Now()
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return 0, err
-	}
-
-	// 设置超时
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	// 读取响应体以确保完整请求
-	_, _ = io.Copy(io.Discard, resp.Body)
-
-	latency := time.Since(start).Milliseconds()
-	return latency, nil
-}
-
 // HandlePurge 处理缓存刷新请求
 func (p *Proxy) HandlePurge(c *gin.Context) {
-	if !p.config.Cache.Purge.Enabled {
+	cfg := p.requestConfig(c)
+	if !cfg.Cache.Purge.Enabled {
 		c.JSON(403, gin.H{"error": "缓存刷新功能未启用"})
 		return
 	}
@@ -386,28 +665,34 @@ func (p *Proxy) HandlePurge(c *gin.Context) {
 	}
 
 	// 检查刷新频率限制
-	if !p.checkPurgeRateLimit(targetURL) {
+	if !p.checkPurgeRateLimit(cfg, targetURL) {
+		metrics.Purges.With(metrics.PurgeRejected).Inc()
 		c.JSON(429, gin.H{
 			"error":   "刷新频率超限",
-			"message": "每30分钟内最多允许执行一次缓存刷新操作",
+			"message": fmt.Sprintf("同一URL每%d分钟内最多允许执行一次缓存刷新操作", cfg.Cache.Purge.RateLimitMinutes),
 		})
 		return
 	}
 
 	// 检查总刷新次数限制
-	if !p.checkPurgeCountLimit() {
+	if !p.checkPurgeCountLimit(cfg) {
+		metrics.Purges.With(metrics.PurgeRejected).Inc()
 		c.JSON(429, gin.H{
 			"error":   "刷新次数超限",
-			"message": "已达到最大刷新次数限制",
+			"message": fmt.Sprintf("每小时最多允许执行%d次缓存刷新操作", cfg.Cache.Purge.MaxPurgeCount),
 		})
 		return
 	}
 
 	// 记录刷新操作
-	p.recordPurge(targetURL)
+	p.recordPurge(cfg, targetURL)
+
+	// 删除缓存内容
+	if _, err := p.Purge(targetURL); err != nil {
+		c.JSON(500, gin.H{"error": "删除缓存失败", "details": err.Error()})
+		return
+	}
 
-	// 这里将来会实现实际的缓存刷新逻辑
-	// 目前只是返回成功响应
 	c.JSON(200, gin.H{
 		"success": true,
 		"message": "缓存刷新请求已处理",
@@ -416,8 +701,36 @@ func (p *Proxy) HandlePurge(c *gin.Context) {
 	})
 }
 
+// Purge 删除缓存内容，target可以是源站URL或镜像路径，返回对应的源站URL
+// 不受刷新频率和次数限制，供管理接口使用
+func (p *Proxy) Purge(target string) (string, error) {
+	cfg := p.cfg()
+	targetURL := target
+	if strings.HasPrefix(target, "/") {
+		_, resolved, err := p.resolvePath(cfg, target)
+		if err != nil {
+			return "", err
+		}
+		targetURL = resolved
+	}
+
+	if p.cache == nil {
+		metrics.Purges.With(metrics.PurgeOK).Inc()
+		return targetURL, nil
+	}
+	// 同一URL的各个响应变体（如npm精简元数据、不同格式的字体CSS）分别缓存，需要一并删除
+	for _, key := range cacheKeys(targetURL) {
+		if err := cache.DeleteEntry(p.cache, key); err != nil {
+			metrics.Purges.With(metrics.PurgeError).Inc()
+			return targetURL, err
+		}
+	}
+	metrics.Purges.With(metrics.PurgeOK).Inc()
+	return targetURL, nil
+}
+
 // checkPurgeRateLimit 检查刷新频率限制
-func (p *Proxy) checkPurgeRateLimit(url string) bool {
+func (p *Proxy) checkPurgeRateLimit(cfg *config.Config, url string) bool {
 	p.purgeMutex.RLock()
 	lastPurgeTime, exists := p.purgeRecords[url]
 	p.purgeMutex.RUnlock()
@@ -426,16 +739,20 @@ func (p *Proxy) checkPurgeRateLimit(url string) bool {
 		return true
 	}
 
-	rateLimitDuration := time.Duration(p.config.Cache.Purge.RateLimitMinutes) * time.Minute
+	rateLimitDuration := time.Duration(cfg.Cache.Purge.RateLimitMinutes) * time.Minute
 	return time.Since(lastPurgeTime) >= rateLimitDuration
 }
 
-// checkPurgeCountLimit 检查总刷新次数限制
-func (p *Proxy) checkPurgeCountLimit() bool {
+// checkPurgeCountLimit 检查每个统计周期内的刷新次数限制，周期结束后重新计数
+func (p *Proxy) checkPurgeCountLimit(cfg *config.Config) bool {
 	p.purgeCountMutex.Lock()
 	defer p.purgeCountMutex.Unlock()
 
-	if p.purgeCount >= p.config.Cache.Purge.MaxPurgeCount {
+	if now := time.Now(); now.Sub(p.purgeWindowStart) >= purgeCountWindow {
+		p.purgeWindowStart = now
+		p.purgeCount = 0
+	}
+	if p.purgeCount >= cfg.Cache.Purge.MaxPurgeCount {
 		return false
 	}
 
@@ -443,10 +760,17 @@ func (p *Proxy) checkPurgeCountLimit() bool {
 	return true
 }
 
-// recordPurge 记录刷新操作
-func (p *Proxy) recordPurge(url string) {
+// recordPurge 记录刷新操作，同时清理已超出频率限制时间的记录
+func (p *Proxy) recordPurge(cfg *config.Config, url string) {
 	p.purgeMutex.Lock()
 	defer p.purgeMutex.Unlock()
 
-	p.purgeRecords[url] = time.Now()
+	now := time.Now()
+	rateLimitDuration := time.Duration(cfg.Cache.Purge.RateLimitMinutes) * time.Minute
+	for recordURL, purgedAt := range p.purgeRecords {
+		if now.Sub(purgedAt) >= rateLimitDuration {
+			delete(p.purgeRecords, recordURL)
+		}
+	}
+	p.purgeRecords[url] = now
 }
@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"static-mirrors/internal/cache"
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testUpstream 记录请求次数的本地源站，路径代理固定使用https，因此使用TLS服务器
+type testUpstream struct {
+	*httptest.Server
+	hits atomic.Int64
+}
+
+// newTestUpstream 启动本地源站
+func newTestUpstream(t *testing.T, handler http.HandlerFunc) *testUpstream {
+	t.Helper()
+	up := &testUpstream{}
+	up.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		up.hits.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(up.Close)
+	return up
+}
+
+// host 返回源站的 域名:端口
+func (up *testUpstream) host() string {
+	u, _ := url.Parse(up.URL)
+	return u.Host
+}
+
+// newTestProxy 创建只有一个源站的反代服务，源站按路径前缀访问，所有请求都交给路径代理处理
+func newTestProxy(t *testing.T, up *testUpstream, source config.SourceConfig, withCache bool) (*Proxy, *gin.Engine) {
+	t.Helper()
+	source.Domain = up.host()
+	source.Enabled = true
+
+	cfg := config.Config{}
+	cfg.Security.AllowPrivateNetworks = true
+	cfg.Cache.TTL.Default = 3600
+	cfg.Sources = []config.SourceConfig{source}
+
+	var cacheService cache.Cache
+	if withCache {
+		cacheService = cache.NewMemoryCache(1000)
+	}
+	p := NewProxy(cfg, cacheService, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	upstreamTransport(p).TLSClientConfig = up.Client().Transport.(*http.Transport).TLSClientConfig
+
+	r := gin.New()
+	r.NoRoute(func(c *gin.Context) {
+		p.HandlePathProxy(c, c.Request.URL.Path)
+	})
+	return p, r
+}
+
+// upstreamTransport 返回反代服务回源使用的Transport
+func upstreamTransport(p *Proxy) *http.Transport {
+	return p.client.Transport.(*readTimeoutTransport).RoundTripper.(*http.Transport)
+}
+
+// routeAll 把所有回源连接指向本地源站，用于测试固定域名（如files.pythonhosted.org）的请求
+func routeAll(p *Proxy, up *testUpstream) {
+	transport := upstreamTransport(p)
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, up.Listener.Addr().String())
+	}
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+}
+
+// get 发起GET请求并返回响应
+func get(r http.Handler, path string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMatchBlockedURL(t *testing.T) {
+	tests := []struct {
+		pattern string
+		target  string
+		want    bool
+	}{
+		{"cdn.example.com", "https://cdn.example.com/a.js", true},
+		{"cdn.example.com", "https://other.example.com/a.js", false},
+		{"https://CDN.example.com", "https://cdn.example.com/a.js", true},
+		{"cdn.example.com/npm/evil", "https://cdn.example.com/npm/evil/index.js", true},
+		{"cdn.example.com/npm/evil", "https://cdn.example.com/npm/evil", true},
+		{"cdn.example.com/npm/evil", "https://cdn.example.com/npm/evil-twin/index.js", false},
+		{"cdn.example.com/npm/evil/", "https://cdn.example.com/npm/evil/index.js", true},
+		{"cdn.example.com/npm", "https://cdn.example.com/gh/npm/index.js", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.target, func(t *testing.T) {
+			target, err := url.Parse(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchBlockedURL(tt.pattern, target); got != tt.want {
+				t.Fatalf("matchBlockedURL(%q, %q) = %v, want %v", tt.pattern, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateCacheControl(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Cache.TTL.Default = 3600
+	cfg.Cache.TTL.Max = 86400
+	cfg.Cache.Strategy = config.CacheStrategyConfig{
+		LargeFileThreshold: 100 << 20,
+		LargeFileTTL:       2592000,
+		NormalFileTTL:      43200,
+		SmallFileTTL:       0,
+		FileTypes:          map[string]int64{"css": 600},
+	}
+
+	tests := []struct {
+		name          string
+		contentType   string
+		contentLength int64
+		want          string
+	}{
+		{"按文件类型", "text/css", 10, "public, max-age=600, s-maxage=600"},
+		{"大文件不超过ttl.max", "application/octet-stream", 200 << 20, "public, max-age=86400, s-maxage=86400"},
+		{"未配置时使用ttl.default", "application/octet-stream", 10, "public, max-age=3600, s-maxage=3600"},
+		{"普通文件", "application/octet-stream", 2 << 20, "public, max-age=43200, s-maxage=43200"},
+		{"长度未知", "application/octet-stream", -1, "public, max-age=43200, s-maxage=43200"},
+	}
+
+	p := &Proxy{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.calculateCacheControl(cfg, tt.contentType, tt.contentLength); got != tt.want {
+				t.Fatalf("calculateCacheControl = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPurgeCountLimit(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Cache.Purge.MaxPurgeCount = 2
+	p := &Proxy{purgeRecords: make(map[string]time.Time)}
+
+	for i := 0; i < 2; i++ {
+		if !p.checkPurgeCountLimit(cfg) {
+			t.Fatalf("第%d次刷新被拒绝", i+1)
+		}
+	}
+	if p.checkPurgeCountLimit(cfg) {
+		t.Fatal("超过max_purge_count的刷新没有被拒绝")
+	}
+
+	// 统计周期结束后重新计数
+	p.purgeWindowStart = p.purgeWindowStart.Add(-purgeCountWindow)
+	if !p.checkPurgeCountLimit(cfg) {
+		t.Fatal("新统计周期的刷新被拒绝")
+	}
+}
+
+func TestRecordPurgePrunesExpiredRecords(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Cache.Purge.RateLimitMinutes = 30
+	p := &Proxy{purgeRecords: map[string]time.Time{
+		"https://cdn.example.com/old.js": time.Now().Add(-time.Hour),
+	}}
+
+	p.recordPurge(cfg, "https://cdn.example.com/new.js")
+	if _, ok := p.purgeRecords["https://cdn.example.com/old.js"]; ok {
+		t.Fatal("过期的刷新记录没有被清理")
+	}
+	if p.checkPurgeRateLimit(cfg, "https://cdn.example.com/new.js") {
+		t.Fatal("刚刷新过的URL没有被限流")
+	}
+}
+
+func TestBlockedURLRequest(t *testing.T) {
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	p, r := newTestProxy(t, up, config.SourceConfig{Name: "cdn", Prefix: "/cdn"}, false)
+	cfg := *p.cfg()
+	cfg.Security.BlockedURLs = []string{up.host() + "/npm/evil"}
+	p.Reload(cfg)
+
+	if w := get(r, "/cdn/npm/evil/index.js"); w.Code != http.StatusForbidden {
+		t.Fatalf("封禁路径状态码 = %d, want 403", w.Code)
+	}
+	if w := get(r, "/cdn/npm/evil-twin/index.js"); w.Code != http.StatusOK {
+		t.Fatalf("相邻路径状态码 = %d, want 200", w.Code)
+	}
+	if got := up.hits.Load(); got != 1 {
+		t.Fatalf("源站请求次数 = %d, want 1", got)
+	}
+}
@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pypiFilesHost PyPI发布文件（wheel和sdist）所在的域名
+const pypiFilesHost = "files.pythonhosted.org"
+
+// PEP 691 简单索引的响应格式
+const (
+	pypiSimpleJSON = "application/vnd.pypi.simple.v1+json"
+	pypiSimpleHTML = "application/vnd.pypi.simple.v1+html"
+)
+
+// pypiLinkPattern 匹配简单索引HTML页面中的链接
+var pypiLinkPattern = regexp.MustCompile(`(?i)(<a\s[^>]*?href\s*=\s*")([^"]*)(")`)
+
+// servePyPI 处理PyPI请求：/simple/ 索引短期缓存并改写文件链接，/packages/ 文件校验后永久缓存
+func (p *Proxy) servePyPI(c *gin.Context, source *config.SourceConfig, host string, targetURL string) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		c.JSON(405, gin.H{"error": "PyPI镜像只支持下载"})
+		return
+	}
+
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的URL格式"})
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(parsedURL.Path, "/simple/") && host == source.Domain:
+		p.servePyPIIndex(c, source, host, parsedURL)
+	case strings.HasPrefix(parsedURL.Path, "/packages/"):
+		p.servePyPIFile(c, source, host, parsedURL)
+	default:
+		c.JSON(404, gin.H{"error": "PyPI镜像只支持 /simple/ 索引和 /packages/ 文件"})
+	}
+}
+
+// servePyPIIndex 代理简单索引，HTML和JSON（PEP 691）格式分别缓存
+func (p *Proxy) servePyPIIndex(c *gin.Context, source *config.SourceConfig, host string, page *url.URL) {
+	// 按客户端偏好选择一种格式，回源时只请求该格式，保证缓存变体与内容一致
+	format := pypiSimpleHTML
+	if strings.Contains(c.GetHeader("Accept"), pypiSimpleJSON) {
+		format = pypiSimpleJSON
+	}
+	c.Request.Header.Set("Accept", format)
+	c.Set(cacheVariantKey, format)
+	c.Set(cachePolicyKey, &cachePolicy{ttl: metadataTTL(source)})
+	c.Header("Vary", "Accept")
+
+	cfg := p.requestConfig(c)
+	base := p.requestBaseURL(c)
+	p.serveRewritten(c, host, page.String(), func(body []byte) []byte {
+		if format == pypiSimpleJSON {
+			return p.rewritePyPIJSON(cfg, body, page, base)
+		}
+		return p.rewritePyPIHTML(cfg, body, page, base)
+	})
+}
+
+// servePyPIFile 代理发布文件，链接中带有sha256时只缓存校验通过的内容，并按不可变内容缓存
+func (p *Proxy) servePyPIFile(c *gin.Context, source *config.SourceConfig, host string, fileURL *url.URL) {
+	// 路径代理模式下 /pypi/packages/... 对应文件域名
+	if host == source.Domain {
+		host = pypiFilesHost
+	}
+
+	digest := fileURL.Query().Get("sha256")
+	if digest == "" {
+		digest = strings.TrimPrefix(fileURL.Fragment, "sha256=")
+	}
+	digest = strings.ToLower(digest)
+	if digest != "" && !sha256Pattern.MatchString(digest) {
+		c.JSON(400, gin.H{"error": "无效的sha256摘要"})
+		return
+	}
+
+	// 缓存键只包含文件路径，同一文件的不同链接共用缓存；命中时按链接中的摘要核对缓存内容
+	// 没有摘要时无法确认内容，按源站缓存头缓存，不超过 cache.ttl.max
+	if digest != "" {
+		c.Set(cachePolicyKey, &cachePolicy{
+			immutable: true,
+			checksum:  &checksum{algorithm: checksumSHA256, value: digest},
+		})
+	}
+	p.forward(c, host, "https://"+host+fileURL.EscapedPath())
+}
+
+// rewritePyPIHTML 改写HTML简单索引中的链接，href中的#sha256=片段保持不变
+func (p *Proxy) rewritePyPIHTML(cfg *config.Config, body []byte, page *url.URL, base string) []byte {
+	return pypiLinkPattern.ReplaceAllFunc(body, func(match []byte) []byte {
+		parts := pypiLinkPattern.FindSubmatch(match)
+		link, ok := p.pypiLink(cfg, page, html.UnescapeString(string(parts[2])), "", base)
+		if !ok {
+			return match
+		}
+
+		rewritten := make([]byte, 0, len(match)+len(base))
+		rewritten = append(rewritten, parts[1]...)
+		rewritten = append(rewritten, html.EscapeString(link)...)
+		return append(rewritten, parts[3]...)
+	})
+}
+
+// rewritePyPIJSON 改写PEP 691 JSON简单索引中files[].url，解析失败时原样返回
+func (p *Proxy) rewritePyPIJSON(cfg *config.Config, body []byte, page *url.URL, base string) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var index map[string]interface{}
+	if err := decoder.Decode(&index); err != nil {
+		return body
+	}
+
+	files, _ := index["files"].([]interface{})
+	for _, f := range files {
+		file, ok := f.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fileURL, _ := file["url"].(string)
+		digest := ""
+		if hashes, ok := file["hashes"].(map[string]interface{}); ok {
+			digest, _ = hashes["sha256"].(string)
+		}
+		if link, ok := p.pypiLink(cfg, page, fileURL, digest, base); ok {
+			file["url"] = link
+		}
+	}
+
+	rewritten, err := json.Marshal(index)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+// pypiLink 将索引中的链接（可以是相对地址）转换为镜像地址，只改写属于PyPI源站的链接
+func (p *Proxy) pypiLink(cfg *config.Config, page *url.URL, link string, digest string, base string) (string, bool) {
+	target, err := page.Parse(link)
+	if err != nil {
+		return "", false
+	}
+	if source := p.sourceFor(cfg, target.Host); source == nil || source.Type != config.SourceTypePyPI {
+		return "", false
+	}
+	if digest != "" && target.Fragment == "" {
+		target.Fragment = "sha256=" + digest
+	}
+
+	mirrored := base + p.mirrorPath(cfg, target)
+	if target.Fragment != "" {
+		mirrored += "#" + target.Fragment
+	}
+	return mirrored, true
+}
+
+// pypiMirrorPath 将PyPI发布文件地址转换为 <前缀>/packages/... 路径，并把sha256放入查询参数供镜像校验
+func (p *Proxy) pypiMirrorPath(cfg *config.Config, u *url.URL) (string, bool) {
+	source := p.sourceOfType(cfg, config.SourceTypePyPI)
+	if source == nil || u.Scheme != "https" || !strings.HasPrefix(u.Path, "/packages/") {
+		return "", false
+	}
+	if u.Host != pypiFilesHost && u.Host != source.Domain {
+		return "", false
+	}
+	prefix, ok := p.pathPrefix(cfg, source.Domain)
+	if !ok {
+		return "", false
+	}
+
+	mirrored := prefix + u.EscapedPath()
+	if digest := strings.TrimPrefix(u.Fragment, "sha256="); digest != u.Fragment && sha256Pattern.MatchString(digest) {
+		mirrored += "?sha256=" + digest
+	}
+	return mirrored, true
+}
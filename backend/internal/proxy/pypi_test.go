@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"static-mirrors/pkg/config"
+)
+
+func newPyPITestProxy(t *testing.T, handler http.HandlerFunc) (*testUpstream, http.Handler) {
+	t.Helper()
+	up := newTestUpstream(t, handler)
+	p, r := newTestProxy(t, up, config.SourceConfig{Name: "pypi", Type: config.SourceTypePyPI, Prefix: "/pypi"}, true)
+	routeAll(p, up)
+	return up, r
+}
+
+func TestPyPIIndexRewrite(t *testing.T) {
+	digest := sha256Hex("demo")
+	up, r := newPyPITestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept"), pypiSimpleJSON) {
+			w.Header().Set("Content-Type", pypiSimpleJSON)
+			w.Write([]byte(`{"name":"demo","files":[{"filename":"demo-1.0.tar.gz","url":"https://files.pythonhosted.org/packages/ab/cd/demo-1.0.tar.gz","hashes":{"sha256":"` + digest + `"}}]}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<a href="https://files.pythonhosted.org/packages/ab/cd/demo-1.0.tar.gz#sha256=` + digest + `">demo-1.0.tar.gz</a>
+<a href="https://example.org/demo-1.0.tar.gz">external</a>`))
+	})
+
+	mirrored := "http://example.com/pypi/packages/ab/cd/demo-1.0.tar.gz?sha256=" + digest + "#sha256=" + digest
+	tests := []struct {
+		name   string
+		accept string
+		want   []string
+	}{
+		{"HTML", "", []string{`href="` + mirrored + `"`, `href="https://example.org/demo-1.0.tar.gz"`}},
+		{"JSON", pypiSimpleJSON, []string{`"url":"` + mirrored + `"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(r, "/pypi/simple/demo/", "Accept", tt.accept)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Fatalf("索引中没有 %s:\n%s", want, w.Body.String())
+				}
+			}
+			if vary := w.Header().Get("Vary"); vary != "Accept" {
+				t.Fatalf("Vary = %q, want Accept", vary)
+			}
+		})
+	}
+
+	// 两种格式分别缓存
+	get(r, "/pypi/simple/demo/")
+	get(r, "/pypi/simple/demo/", "Accept", pypiSimpleJSON)
+	if got := up.hits.Load(); got != 2 {
+		t.Fatalf("源站请求次数 = %d, want 2", got)
+	}
+}
+
+func TestPyPIFile(t *testing.T) {
+	const content = "demo package"
+	const path = "/pypi/packages/ab/cd/demo-1.0.tar.gz"
+	good, bad := sha256Hex(content), sha256Hex("other")
+
+	tests := []struct {
+		name string
+		// 依次请求的摘要，空字符串表示链接中没有摘要
+		digests []string
+		// 每次请求是否回源
+		fetched   []bool
+		immutable []bool
+	}{
+		{"摘要正确时永久缓存", []string{good, good}, []bool{true, false}, []bool{true, true}},
+		{"摘要错误时不缓存", []string{bad, bad}, []bool{true, true}, []bool{true, true}},
+		{"没有摘要时按源站缓存头缓存", []string{"", ""}, []bool{true, false}, []bool{false, false}},
+		{"缓存内容与请求的摘要不符时重新回源", []string{good, bad}, []bool{true, true}, []bool{true, true}},
+		{"没有摘要缓存的内容校验后使用", []string{"", good}, []bool{true, false}, []bool{false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, r := newPyPITestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Host != pypiFilesHost || r.URL.Path != "/packages/ab/cd/demo-1.0.tar.gz" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Cache-Control", "max-age=600")
+				w.Write([]byte(content))
+			})
+
+			for i, digest := range tt.digests {
+				target := path
+				if digest != "" {
+					target += "?sha256=" + digest
+				}
+				before := up.hits.Load()
+				w := get(r, target)
+				if w.Code != http.StatusOK || w.Body.String() != content {
+					t.Fatalf("第%d次请求 status = %d, body %q", i+1, w.Code, w.Body.String())
+				}
+				if fetched := up.hits.Load() > before; fetched != tt.fetched[i] {
+					t.Fatalf("第%d次请求回源 = %v, want %v", i+1, fetched, tt.fetched[i])
+				}
+				cacheControl := w.Header().Get("Cache-Control")
+				if immutable := strings.Contains(cacheControl, "immutable"); immutable != tt.immutable[i] {
+					t.Fatalf("第%d次请求 Cache-Control = %q", i+1, cacheControl)
+				}
+			}
+		})
+	}
+}
+
+func TestPyPIInvalidDigest(t *testing.T) {
+	_, r := newPyPITestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("demo"))
+	})
+	if w := get(r, "/pypi/packages/ab/cd/demo-1.0.tar.gz?sha256=xyz"); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// readTimeoutTransport 为回源响应体设置读取空闲超时
+// 只限制每次读取等待源站数据的时间，不限制整个下载的时长；向慢速客户端写出数据的时间不计入
+type readTimeoutTransport struct {
+	http.RoundTripper
+	timeout time.Duration
+}
+
+// RoundTrip 发送请求，超时后取消请求让正在进行的读取返回错误
+func (t *readTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.RoundTripper.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	body := &readTimeoutBody{ReadCloser: resp.Body, timeout: t.timeout, cancel: cancel}
+	body.timer = time.AfterFunc(t.timeout, func() {
+		body.expired.Store(true)
+		cancel()
+	})
+	body.timer.Stop()
+	resp.Body = body
+	return resp, nil
+}
+
+// readTimeoutBody 每次读取前启动计时，读取返回后停止
+type readTimeoutBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	expired atomic.Bool
+}
+
+// Read 读取响应体，超过timeout没有收到数据时返回超时错误
+func (b *readTimeoutBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.timeout)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	if err != nil && b.expired.Load() {
+		err = fmt.Errorf("读取源站响应超时：%s内没有收到数据", b.timeout)
+	}
+	return n, err
+}
+
+// Close 关闭响应体并释放请求上下文
+func (b *readTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
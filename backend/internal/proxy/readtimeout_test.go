@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadTimeoutTransport(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	tests := []struct {
+		name string
+		// 每次写出之间的间隔
+		interval time.Duration
+		chunks   int
+		wantErr  bool
+	}{
+		// 总时长超过读取超时，但源站一直在发送数据
+		{"持续发送的慢速下载", timeout / 4, 12, false},
+		{"源站停止发送", 3 * timeout, 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < tt.chunks; i++ {
+					if i > 0 {
+						select {
+						case <-time.After(tt.interval):
+						case <-r.Context().Done():
+							return
+						}
+					}
+					w.Write([]byte("chunk\n"))
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer up.Close()
+
+			client := &http.Client{Transport: &readTimeoutTransport{RoundTripper: http.DefaultTransport, timeout: timeout}}
+			resp, err := client.Get(up.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadAll error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "读取源站响应超时") {
+				t.Fatalf("ReadAll error = %v", err)
+			}
+			if !tt.wantErr && len(body) != tt.chunks*len("chunk\n") {
+				t.Fatalf("收到 %d 字节", len(body))
+			}
+		})
+	}
+}
+
+// TestReadTimeoutExcludesClientWrites 读取之间的时间（向客户端写出）不计入读取超时
+func TestReadTimeoutExcludesClientWrites(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 4)))
+	}))
+	defer up.Close()
+
+	client := &http.Client{Transport: &readTimeoutTransport{RoundTripper: http.DefaultTransport, timeout: timeout}}
+	resp, err := client.Get(up.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	buf := make([]byte, 1)
+	for i := 0; i < 4; i++ {
+		// 最后一次读取可能同时返回数据和io.EOF
+		if n, err := resp.Body.Read(buf); n != 1 || (err != nil && err != io.EOF) {
+			t.Fatalf("第%d次读取 = %d, %v", i+1, n, err)
+		}
+		time.Sleep(2 * timeout)
+	}
+}
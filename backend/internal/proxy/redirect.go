@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"static-mirrors/internal/outbound"
+	"static-mirrors/pkg/config"
+)
+
+// maxRedirects 镜像内部跟随重定向的最大次数
+const maxRedirects = 10
+
+// errRedirectNotAllowed 源站重定向到了不在白名单中的域名
+var errRedirectNotAllowed = errors.New("源站重定向到不受支持的域名")
+
+// redirectPolicyKey 请求上下文中重定向策略的键
+type redirectPolicyKey struct{}
+
+// redirectPolicy 单次请求的重定向策略
+type redirectPolicy struct {
+	mode  string
+	hosts map[string]bool
+}
+
+// redirectPolicy 获取源站的重定向策略，白名单为所有已启用源站的域名及其重定向域名
+func (p *Proxy) redirectPolicy(cfg *config.Config, host string) *redirectPolicy {
+	policy := &redirectPolicy{
+		mode:  config.RedirectFollow,
+		hosts: make(map[string]bool),
+	}
+	if source := p.sourceFor(cfg, host); source != nil && source.Redirect == config.RedirectRewrite {
+		policy.mode = config.RedirectRewrite
+	}
+
+	for _, source := range cfg.Sources {
+		if !source.Enabled {
+			continue
+		}
+		for _, sourceHost := range sourceHosts(source) {
+			policy.hosts[sourceHost] = true
+		}
+	}
+	return policy
+}
+
+// checkRedirect 按请求上下文中的策略决定是否跟随重定向
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	policy, ok := req.Context().Value(redirectPolicyKey{}).(*redirectPolicy)
+	if ok && policy.mode == config.RedirectRewrite {
+		// 不跟随，把重定向响应交给rewriteLocation处理
+		return http.ErrUseLastResponse
+	}
+	if err := outbound.CheckScheme(req.URL); err != nil {
+		return err
+	}
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("重定向次数超过%d次", maxRedirects)
+	}
+	if ok && !policy.hosts[req.URL.Host] {
+		return errRedirectNotAllowed
+	}
+	return nil
+}
+
+// rewriteLocation 将指向白名单域名的Location改写为镜像路径，避免客户端被重定向到源站
+func (p *Proxy) rewriteLocation(cfg *config.Config, resp *http.Response, policy *redirectPolicy) {
+	location := resp.Header.Get("Location")
+	if location == "" || resp.Request == nil {
+		return
+	}
+
+	target, err := resp.Request.URL.Parse(location)
+	if err != nil || !policy.hosts[target.Host] {
+		return
+	}
+	resp.Header.Set("Location", p.mirrorPath(cfg, target))
+}
+
+// mirrorPath 返回源站URL在镜像上的访问路径，配置了路径前缀的源站使用路径代理形式
+func (p *Proxy) mirrorPath(cfg *config.Config, u *url.URL) string {
+	if path, ok := p.githubMirrorPath(cfg, u); ok {
+		return path
+	}
+	if path, ok := p.pypiMirrorPath(cfg, u); ok {
+		return path
+	}
+	if path, ok := p.fontsMirrorPath(cfg, u); ok {
+		return path
+	}
+	if u.Scheme == "https" {
+		if prefix, ok := p.pathPrefix(cfg, u.Host); ok {
+			return prefix + u.RequestURI()
+		}
+	}
+	return "/mirror?url=" + url.QueryEscape(u.String())
+}
+
+// pathPrefix 返回源站在路径代理模式下的前缀，默认源站的前缀为空
+func (p *Proxy) pathPrefix(cfg *config.Config, host string) (string, bool) {
+	if host == defaultSource {
+		return "", true
+	}
+	for _, source := range cfg.Sources {
+		if source.Enabled && source.Domain == host && source.Prefix != "" {
+			return "/" + strings.Trim(source.Prefix, "/"), true
+		}
+	}
+	return "", false
+}
+
+// 路径解析错误
+var errPathBlocked = errors.New("该路径已被封禁")
+
+// resolvePath 将路径代理模式的路径解析为源站域名和目标URL
+func (p *Proxy) resolvePath(cfg *config.Config, proxyPath string) (string, string, error) {
+	if host, targetURL, ok, err := p.githubTarget(cfg, proxyPath); ok {
+		return host, targetURL, err
+	}
+
+	host, upstreamPath := p.sourceForPath(cfg, proxyPath)
+	if p.isBlockedPath(upstreamPath) {
+		return "", "", errPathBlocked
+	}
+	return host, fmt.Sprintf("https://%s%s", host, upstreamPath), nil
+}
+
+// sourceForPath 根据路径前缀查找源站，返回源站域名和去掉前缀后的上游路径
+func (p *Proxy) sourceForPath(cfg *config.Config, proxyPath string) (string, string) {
+	for _, source := range cfg.Sources {
+		if !source.Enabled || source.Prefix == "" {
+			continue
+		}
+		prefix := "/" + strings.Trim(source.Prefix, "/")
+		if proxyPath == prefix || strings.HasPrefix(proxyPath, prefix+"/") {
+			if rest := strings.TrimPrefix(proxyPath, prefix); rest != "" {
+				return source.Domain, rest
+			}
+			return source.Domain, "/"
+		}
+	}
+	return defaultSource, proxyPath
+}
+
+// sourceFor 根据域名查找已启用的源站配置，域名可以是源站域名或其重定向域名
+func (p *Proxy) sourceFor(cfg *config.Config, host string) *config.SourceConfig {
+	sources := cfg.Sources
+	for i := range sources {
+		source := &sources[i]
+		if !source.Enabled {
+			continue
+		}
+		for _, sourceHost := range sourceHosts(*source) {
+			if sourceHost == host {
+				return source
+			}
+		}
+	}
+	return nil
+}
+
+// sourceOfType 返回第一个已启用的指定类型的源站配置
+func (p *Proxy) sourceOfType(cfg *config.Config, sourceType string) *config.SourceConfig {
+	sources := cfg.Sources
+	for i := range sources {
+		source := &sources[i]
+		if source.Enabled && source.Type == sourceType {
+			return source
+		}
+	}
+	return nil
+}
+
+// sourceHosts 返回源站可以访问的全部域名：源站域名、重定向域名和源站类型内置的域名
+func sourceHosts(source config.SourceConfig) []string {
+	hosts := append([]string{source.Domain}, source.RedirectHosts...)
+	switch source.Type {
+	case config.SourceTypeGitHub:
+		hosts = append(hosts, githubHosts...)
+	case config.SourceTypePyPI:
+		hosts = append(hosts, pypiFilesHost)
+	case config.SourceTypeFonts:
+		hosts = append(hosts, fontsFilesHost)
+	}
+	return hosts
+}
+
+// newGuard 创建出站请求守卫，白名单为所有已启用源站的域名及其重定向域名
+func newGuard(cfg config.Config) *outbound.Guard {
+	return outbound.NewGuard(guardHosts(cfg), cfg.Security.AllowPrivateNetworks)
+}
+
+// guardHosts 返回所有已启用源站可以访问的域名
+func guardHosts(cfg config.Config) []string {
+	var hosts []string
+	for _, source := range cfg.Sources {
+		if !source.Enabled {
+			continue
+		}
+		hosts = append(hosts, sourceHosts(source)...)
+	}
+	return hosts
+}
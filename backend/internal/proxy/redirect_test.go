@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"static-mirrors/pkg/config"
+)
+
+func TestRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		path     string
+		status   int
+		location string
+		body     string
+	}{
+		{"跟随源站内的重定向", config.RedirectFollow, "/test/old", http.StatusOK, "", "content"},
+		{"跟随多次重定向", config.RedirectFollow, "/test/chain", http.StatusOK, "", "content"},
+		{"拒绝重定向到其他域名", config.RedirectFollow, "/test/external", http.StatusBadGateway, "", ""},
+		{"重定向次数超限", config.RedirectFollow, "/test/loop", http.StatusBadGateway, "", ""},
+		{"改写为镜像地址", config.RedirectRewrite, "/test/old", http.StatusFound, "/test/new?v=1", ""},
+		{"改写相对地址", config.RedirectRewrite, "/test/relative", http.StatusFound, "/test/new?v=1", ""},
+		{"其他域名的地址不改写", config.RedirectRewrite, "/test/external", http.StatusFound, "https://other.example.com/file", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var up *testUpstream
+			up = newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/old":
+					http.Redirect(w, r, "https://"+up.host()+"/new?v=1", http.StatusFound)
+				case "/chain":
+					http.Redirect(w, r, "/old", http.StatusMovedPermanently)
+				case "/relative":
+					http.Redirect(w, r, "new?v=1", http.StatusFound)
+				case "/external":
+					http.Redirect(w, r, "https://other.example.com/file", http.StatusFound)
+				case "/loop":
+					http.Redirect(w, r, "/loop", http.StatusFound)
+				case "/new":
+					w.Write([]byte("content"))
+				default:
+					http.NotFound(w, r)
+				}
+			})
+			_, r := newTestProxy(t, up, config.SourceConfig{Name: "test", Prefix: "/test", Redirect: tt.mode}, true)
+
+			w := get(r, tt.path)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.status, w.Body.String())
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Fatalf("Location = %q, want %q", got, tt.location)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Fatalf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestMirrorPath(t *testing.T) {
+	cfg := config.Config{Sources: []config.SourceConfig{
+		{Name: "npm", Domain: "registry.npmjs.org", Prefix: "/npm/", Enabled: true},
+		{Name: "disabled", Domain: "disabled.example.com", Prefix: "/disabled", Enabled: false},
+	}}
+	p := &Proxy{}
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://registry.npmjs.org/vue?x=1", "/npm/vue?x=1"},
+		{"https://" + defaultSource + "/npm/vue@3/dist/vue.js", "/npm/vue@3/dist/vue.js"},
+		{"http://registry.npmjs.org/vue", "/mirror?url=" + url.QueryEscape("http://registry.npmjs.org/vue")},
+		{"https://disabled.example.com/file", "/mirror?url=" + url.QueryEscape("https://disabled.example.com/file")},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := p.mirrorPath(&cfg, u); got != tt.want {
+			t.Errorf("mirrorPath(%s) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
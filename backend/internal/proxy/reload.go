@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"reflect"
+
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// configKey 请求上下文中配置快照的键
+const configKey = "mirror.config"
+
+// cfg 返回当前配置的快照，快照在热更新时整体替换，不会被修改
+func (p *Proxy) cfg() *config.Config {
+	return p.config.Load()
+}
+
+// requestConfig 返回本次请求使用的配置快照
+// 请求第一次读取配置时固定快照，之后的读取都使用同一份，避免请求处理中途热更新导致前后配置不一致
+func (p *Proxy) requestConfig(c *gin.Context) *config.Config {
+	if v, exists := c.Get(configKey); exists {
+		if cfg, ok := v.(*config.Config); ok {
+			return cfg
+		}
+	}
+	cfg := p.cfg()
+	c.Set(configKey, cfg)
+	return cfg
+}
+
+// Reload 应用热更新后的配置：替换配置快照和出站白名单，熔断配置变化时重建熔断器
+// 已经开始的回源请求不受影响
+func (p *Proxy) Reload(cfg config.Config) {
+	old := p.cfg()
+	p.config.Store(&cfg)
+	p.guard.Update(guardHosts(cfg), cfg.Security.AllowPrivateNetworks)
+
+	if !reflect.DeepEqual(old.Proxy.CircuitBreaker, cfg.Proxy.CircuitBreaker) {
+		if cfg.Proxy.CircuitBreaker.Enabled {
+			p.breakers.Store(newBreakerGroup(cfg.Proxy.CircuitBreaker))
+		} else {
+			p.breakers.Store(nil)
+		}
+	}
+
+	// 只有配置文件中的离线模式变化时才覆盖后台设置的状态
+	if old.Cache.Offline != cfg.Cache.Offline {
+		p.SetOffline(cfg.Cache.Offline)
+	}
+}
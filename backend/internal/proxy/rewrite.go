@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveRewritten 通过完整的反代与缓存流程获取响应，成功时改写响应体后再写给客户端
+// 缓存中保存的是源站原始内容，改写在每次响应时进行，因此改写结果可以依赖当前请求（如镜像地址）
+func (p *Proxy) serveRewritten(c *gin.Context, host string, targetURL string, rewrite func([]byte) []byte) {
+	buf := p.forwardBuffered(c, host, targetURL)
+	if buf.streaming {
+		// 超过 cache.max_object_size 的响应已经按源站原始内容转发，其中的地址没有改写
+		p.log(c).Warn("响应体超过暂存上限，未改写直接转发", "url", targetURL, "limit", buf.limit)
+		return
+	}
+
+	body := buf.body.Bytes()
+	if c.Request.Method == http.MethodGet && buf.Status() == http.StatusOK {
+		body = rewrite(body)
+	}
+	if err := buf.flush(c, body); err != nil {
+		p.log(c).Warn("写入响应失败", "error", err)
+	}
+}
+
+// fullBodyKey 标记调用方需要读取完整的响应体（改写或校验），回源时必须拿到未压缩的完整响应
+const fullBodyKey = "mirror.full_body"
+
+// forwardBuffered 执行反代流程，但把状态码和响应体暂存起来，由调用方处理后再通过flush写出
+// 暂存不超过 cache.max_object_size，更大的响应直接转发，调用方通过streaming判断
+func (p *Proxy) forwardBuffered(c *gin.Context, host string, targetURL string) *bufferedWriter {
+	buf := &bufferedWriter{ResponseWriter: c.Writer, limit: p.maxObjectSize(p.requestConfig(c))}
+	c.Set(fullBodyKey, true)
+	c.Writer = buf
+	p.forward(c, host, targetURL)
+	c.Writer = buf.ResponseWriter
+	return buf
+}
+
+// bufferedWriter 暂存状态码和响应体的ResponseWriter，响应头直接写入原始的ResponseWriter
+// 暂存内容超过limit后写出状态码和已暂存的内容，之后的内容直接转发
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+	// limit 暂存上限，为0时不限制
+	limit int64
+	// streaming 已超过暂存上限，改为直接转发
+	streaming bool
+}
+
+// WriteHeader 记录状态码
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+// WriteHeaderNow 状态码在改写完成后才真正写出
+func (w *bufferedWriter) WriteHeaderNow() {
+	if w.streaming {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Write 暂存响应体，超过暂存上限后直接转发
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.limit > 0 && int64(w.body.Len()+len(b)) > w.limit {
+		data := w.startStreaming(b)
+		if _, err := w.ResponseWriter.Write(data); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	return w.body.Write(b)
+}
+
+// WriteString 暂存响应体，超过暂存上限后直接转发
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// startStreaming 改为直接转发：写出状态码，返回已暂存的内容与b拼接后的数据，由调用方写出
+func (w *bufferedWriter) startStreaming(b []byte) []byte {
+	w.streaming = true
+	w.ResponseWriter.WriteHeader(w.Status())
+	data := append(w.body.Bytes(), b...)
+	w.body = bytes.Buffer{}
+	return data
+}
+
+// Status 返回记录的状态码
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size 返回暂存的响应体大小，直接转发后返回已写出的大小
+func (w *bufferedWriter) Size() int {
+	if w.streaming {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+// Written 是否已经写入状态码
+func (w *bufferedWriter) Written() bool {
+	return w.status != 0 || w.streaming
+}
+
+// flush 把暂存的状态码和处理后的响应体写给客户端
+func (w *bufferedWriter) flush(c *gin.Context, body []byte) error {
+	status := w.Status()
+	if c.Request.Method != http.MethodGet || status == http.StatusNotModified {
+		c.Status(status)
+		return nil
+	}
+
+	c.Header("Content-Length", strconv.Itoa(len(body)))
+	c.Status(status)
+
+	transferStart := time.Now()
+	defer pipelineTimingOf(c).addTransfer(transferStart)
+	_, err := c.Writer.Write(body)
+	return err
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+)
+
+// fillTracker 跟踪进行中的回源请求，服务关闭时中止它们并停止写入缓存
+type fillTracker struct {
+	mu     sync.RWMutex
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// newFillTracker 创建回源请求跟踪器
+func newFillTracker() *fillTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &fillTracker{ctx: ctx, cancel: cancel}
+}
+
+// begin 登记一个回源请求，返回的上下文在服务中止时取消，请求结束后必须调用done
+// 服务已经中止时返回false
+func (t *fillTracker) begin(parent context.Context) (ctx context.Context, done func(), ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.ctx.Err() != nil {
+		return nil, nil, false
+	}
+
+	t.wg.Add(1)
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(t.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		t.wg.Done()
+	}, true
+}
+
+// aborted 判断服务是否已经中止回源请求
+func (t *fillTracker) aborted() bool {
+	return t.ctx.Err() != nil
+}
+
+// Abort 中止所有进行中的回源请求，未完成的下载不会写入缓存，之后的回源请求直接失败
+// 等待进行中的请求退出，ctx结束时提前返回
+func (p *Proxy) Abort(ctx context.Context) error {
+	p.fills.mu.Lock()
+	p.fills.cancel()
+	p.fills.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.fills.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
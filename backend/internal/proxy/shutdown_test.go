@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"static-mirrors/internal/cache"
+	"static-mirrors/pkg/config"
+)
+
+func TestAbort(t *testing.T) {
+	started := make(chan struct{})
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/slow.js" {
+			w.Write([]byte("content"))
+			return
+		}
+		// 写出一部分内容后停住，直到回源请求被中止
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+	})
+	p, r := newTestProxy(t, up, config.SourceConfig{Name: "test", Prefix: "/test"}, true)
+	storeExpired(t, p, "https://"+up.host()+"/cached.js", "stale")
+
+	finished := make(chan *httptest.ResponseRecorder)
+	go func() {
+		finished <- get(r, "/test/slow.js")
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Abort(ctx); err != nil {
+		t.Fatalf("Abort() = %v", err)
+	}
+
+	// 中止可能发生在收到源站响应头之前（502）或传输过程中（响应不完整），都不写入缓存
+	w := <-finished
+	if w.Code == http.StatusOK && w.Body.Len() == 100 {
+		t.Fatalf("中止的下载不应完整返回: %q", w.Body.String())
+	}
+	if entry, _ := cache.GetEntry(p.cache, cacheKey("https://"+up.host()+"/slow.js")); entry != nil {
+		t.Fatal("中止的下载不应写入缓存")
+	}
+
+	// 中止后不再回源：有缓存时返回陈旧内容，否则返回503
+	if w := get(r, "/test/cached.js"); w.Code != http.StatusOK || w.Header().Get("X-Mirror-Cache") != CacheStale {
+		t.Fatalf("status = %d, X-Mirror-Cache %s", w.Code, w.Header().Get("X-Mirror-Cache"))
+	}
+	if w := get(r, "/test/other.js"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	if got := up.hits.Load(); got != 1 {
+		t.Fatalf("源站请求次数 = %d, want 1", got)
+	}
+}
+
+func TestAbortIdle(t *testing.T) {
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
+	p, _ := newTestProxy(t, up, config.SourceConfig{Name: "test", Prefix: "/test"}, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.Abort(ctx); err != nil {
+		t.Fatalf("没有回源请求时 Abort() = %v", err)
+	}
+	// 重复调用不会出错
+	if err := p.Abort(ctx); err != nil {
+		t.Fatalf("再次调用 Abort() = %v", err)
+	}
+}
+
+func TestAbortTimeout(t *testing.T) {
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
+	p, _ := newTestProxy(t, up, config.SourceConfig{Name: "test", Prefix: "/test"}, false)
+
+	// 未退出的回源请求会让 Abort 等到ctx结束
+	fillCtx, done, ok := p.fills.begin(context.Background())
+	if !ok {
+		t.Fatal("中止前 begin() 应成功")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Abort(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Abort() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if fillCtx.Err() == nil {
+		t.Fatal("Abort 应取消进行中的回源请求")
+	}
+	if !p.fills.aborted() {
+		t.Fatal("aborted() = false")
+	}
+	if _, _, ok := p.fills.begin(context.Background()); ok {
+		t.Fatal("中止后 begin() 应失败")
+	}
+	done()
+}
@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"static-mirrors/internal/cache"
+	"static-mirrors/pkg/config"
+)
+
+// storeExpired 写入一条已过期的缓存，模拟可以作为陈旧内容使用的缓存
+func storeExpired(t *testing.T, p *Proxy, targetURL string, body string) {
+	t.Helper()
+	entry := &cache.Entry{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/javascript"}},
+		Body:       []byte(body),
+		StoredAt:   time.Now().Add(-2 * time.Hour),
+		ExpiresAt:  time.Now().Add(-time.Hour),
+	}
+	if err := cache.SetEntry(p.cache, cacheKey(targetURL), entry, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeStale(t *testing.T) {
+	tests := []struct {
+		name string
+		// 源站行为：返回状态码，为0时关闭源站
+		upstream int
+		cached   bool
+		status   int
+		cache    string
+		warning  string
+	}{
+		{"源站5xx时返回陈旧缓存", http.StatusServiceUnavailable, true, http.StatusOK, CacheStale, warningRevalidateFailed},
+		{"源站无法连接时返回陈旧缓存", 0, true, http.StatusOK, CacheStale, warningRevalidateFailed},
+		{"源站正常时刷新缓存", http.StatusOK, true, http.StatusOK, CacheMiss, ""},
+		{"源站4xx不使用陈旧缓存", http.StatusNotFound, true, http.StatusNotFound, CacheMiss, ""},
+		{"没有缓存时返回源站错误", http.StatusServiceUnavailable, false, http.StatusServiceUnavailable, CacheMiss, ""},
+		{"没有缓存且源站无法连接", 0, false, http.StatusBadGateway, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.upstream)
+				w.Write([]byte("fresh"))
+			})
+			p, r := newTestProxy(t, up, config.SourceConfig{Name: "test", Prefix: "/test"}, true)
+			if tt.cached {
+				storeExpired(t, p, "https://"+up.host()+"/file.js", "stale")
+			}
+			if tt.upstream == 0 {
+				up.Close()
+			}
+
+			w := get(r, "/test/file.js")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.status, w.Body.String())
+			}
+			if got := w.Header().Get("X-Mirror-Cache"); got != tt.cache {
+				t.Fatalf("X-Mirror-Cache = %q, want %q", got, tt.cache)
+			}
+			if got := w.Header().Get("Warning"); got != tt.warning {
+				t.Fatalf("Warning = %q, want %q", got, tt.warning)
+			}
+			if tt.cache == CacheStale && w.Body.String() != "stale" {
+				t.Fatalf("body = %q, want stale", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestOffline(t *testing.T) {
+	up := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fresh"))
+	})
+	p, r := newTestProxy(t, up, config.SourceConfig{Name: "test", Prefix: "/test"}, true)
+	storeExpired(t, p, "https://"+up.host()+"/cached.js", "stale")
+
+	p.SetOffline(true)
+	if !p.Offline() {
+		t.Fatal("Offline() = false")
+	}
+
+	// 离线模式下过期的缓存同样可用，未缓存的内容返回504，都不访问源站
+	w := get(r, "/test/cached.js")
+	if w.Code != http.StatusOK || w.Body.String() != "stale" || w.Header().Get("Warning") != warningStale {
+		t.Fatalf("status = %d, body %q, Warning %q", w.Code, w.Body.String(), w.Header().Get("Warning"))
+	}
+	if w := get(r, "/test/missing.js"); w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("未缓存的内容 status = %d, want 504", w.Code)
+	}
+	if got := up.hits.Load(); got != 0 {
+		t.Fatalf("离线模式下访问了源站 %d 次", got)
+	}
+
+	// 关闭离线模式后恢复回源
+	p.SetOffline(false)
+	if w := get(r, "/test/cached.js"); w.Body.String() != "fresh" || w.Header().Get("X-Mirror-Cache") != CacheMiss {
+		t.Fatalf("body = %q, X-Mirror-Cache %q", w.Body.String(), w.Header().Get("X-Mirror-Cache"))
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"static-mirrors/pkg/config"
+	"static-mirrors/pkg/logging"
+)
+
+// Server 镜像服务的HTTP监听，启用TLS时提供HTTPS和HTTP/2，并可附带HTTP跳转监听
+type Server struct {
+	httpServer     *http.Server
+	redirectServer *http.Server
+	certs          *CertStore
+	reloadInterval time.Duration
+	done           chan struct{}
+	closeOnce      sync.Once
+	logger         *slog.Logger
+}
+
+// New 根据应用配置创建服务，证书和TLS参数有误时返回错误
+func New(cfg config.AppConfig, handler http.Handler, logger *slog.Logger) (*Server, error) {
+	s := &Server{
+		httpServer: &http.Server{
+			Addr:              net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+			ErrorLog:          logging.StdLogger(logger, slog.LevelWarn),
+		},
+		done:   make(chan struct{}),
+		logger: logger,
+	}
+	if !cfg.TLS.Enabled {
+		return s, nil
+	}
+
+	certs, err := NewCertStore(cfg.TLS.Certificates, logger)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTLSConfig(cfg.TLS, certs)
+	if err != nil {
+		return nil, err
+	}
+	s.httpServer.TLSConfig = tlsConfig
+	s.certs = certs
+	s.reloadInterval = time.Duration(cfg.TLS.ReloadInterval) * time.Second
+
+	if cfg.TLS.RedirectPort > 0 {
+		s.redirectServer = &http.Server{
+			Addr:              net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.TLS.RedirectPort)),
+			Handler:           redirectHandler(cfg.Port),
+			ReadHeaderTimeout: 10 * time.Second,
+			ErrorLog:          logging.StdLogger(logger, slog.LevelWarn),
+		}
+	}
+	return s, nil
+}
+
+// Addr 返回主监听地址
+func (s *Server) Addr() string {
+	return s.httpServer.Addr
+}
+
+// TLS 判断是否启用了HTTPS
+func (s *Server) TLS() bool {
+	return s.certs != nil
+}
+
+// ListenAndServe 启动监听，阻塞直到主监听退出
+func (s *Server) ListenAndServe() error {
+	if s.certs == nil {
+		return ignoreClosed(s.httpServer.ListenAndServe())
+	}
+
+	go s.certs.Watch(s.reloadInterval, s.done)
+	if s.redirectServer != nil {
+		go func() {
+			s.logger.Info("HTTP跳转监听已启动", "addr", s.redirectServer.Addr)
+			if err := ignoreClosed(s.redirectServer.ListenAndServe()); err != nil {
+				s.logger.Error("HTTP跳转监听退出", "error", err)
+			}
+		}()
+	}
+	return ignoreClosed(s.httpServer.ListenAndServeTLS("", ""))
+}
+
+// Shutdown 停止接收新连接并等待进行中的请求完成，ctx结束时返回错误，此时仍有连接未关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopWatch()
+	if s.redirectServer != nil {
+		if err := s.redirectServer.Shutdown(ctx); err != nil {
+			s.redirectServer.Close()
+		}
+	}
+	return s.httpServer.Shutdown(ctx)
+}
+
+// Close 立即关闭所有监听和连接
+func (s *Server) Close() error {
+	s.stopWatch()
+	if s.redirectServer != nil {
+		s.redirectServer.Close()
+	}
+	return s.httpServer.Close()
+}
+
+// stopWatch 停止检查证书文件
+func (s *Server) stopWatch() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// redirectHandler 将HTTP请求跳转到HTTPS端口的相同地址
+func redirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hostname := (&url.URL{Host: r.Host}).Hostname()
+		if hostname == "" {
+			http.Error(w, "缺少Host头", http.StatusBadRequest)
+			return
+		}
+		host := net.JoinHostPort(hostname, strconv.Itoa(httpsPort))
+		if httpsPort == 443 {
+			host = strings.TrimSuffix(host, ":443")
+		}
+
+		// 非GET/HEAD请求使用308，保证客户端不改变请求方法
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, fmt.Sprintf("https://%s%s", host, r.URL.RequestURI()), status)
+	})
+}
+
+// ignoreClosed 服务正常关闭时不视为错误
+func ignoreClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"static-mirrors/pkg/config"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     int
+		method   string
+		host     string
+		status   int
+		location string
+	}{
+		{"GET跳转到HTTPS端口", 8443, http.MethodGet, "mirror.example.com:8080", http.StatusMovedPermanently, "https://mirror.example.com:8443/npm/vue?x=1"},
+		{"443端口省略端口号", 443, http.MethodGet, "mirror.example.com", http.StatusMovedPermanently, "https://mirror.example.com/npm/vue?x=1"},
+		{"POST使用308", 443, http.MethodPost, "mirror.example.com", http.StatusPermanentRedirect, "https://mirror.example.com/npm/vue?x=1"},
+		{"IPv6地址", 8443, http.MethodGet, "[::1]:8080", http.StatusMovedPermanently, "https://[::1]:8443/npm/vue?x=1"},
+		{"缺少Host", 443, http.MethodGet, "", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/npm/vue?x=1", nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+			redirectHandler(tt.port).ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Fatalf("Location = %q, want %q", got, tt.location)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	handler := http.NotFoundHandler()
+
+	s, err := New(config.AppConfig{Host: "127.0.0.1", Port: 1108}, handler, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.TLS() || s.Addr() != "127.0.0.1:1108" {
+		t.Fatalf("TLS() = %v, Addr() = %s", s.TLS(), s.Addr())
+	}
+
+	dir := t.TempDir()
+	cfg := config.AppConfig{Port: 8443}
+	cfg.TLS.Enabled = true
+	cfg.TLS.Certificates = []config.CertificateConfig{writeCert(t, dir, "a", "a.example.com")}
+	cfg.TLS.RedirectPort = 8080
+	s, err = New(cfg, handler, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.TLS() || s.redirectServer == nil || s.redirectServer.Addr != ":8080" {
+		t.Fatalf("TLS() = %v, redirectServer = %+v", s.TLS(), s.redirectServer)
+	}
+
+	// 证书或TLS参数有误时返回错误
+	cfg.TLS.MinVersion = "1.0"
+	if _, err := New(cfg, handler, testLogger); err == nil {
+		t.Fatal("不支持的TLS版本应返回错误")
+	}
+	cfg.TLS.MinVersion = ""
+	cfg.TLS.Certificates = nil
+	if _, err := New(cfg, handler, testLogger); err == nil {
+		t.Fatal("没有证书时应返回错误")
+	}
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"static-mirrors/pkg/config"
+)
+
+// defaultReloadInterval 默认的证书文件检查间隔
+const defaultReloadInterval = 30 * time.Second
+
+// tlsVersions 允许配置的最低TLS版本
+var tlsVersions = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// CertStore 按SNI选择证书，并在证书文件变化后重新加载
+type CertStore struct {
+	files  []config.CertificateConfig
+	logger *slog.Logger
+
+	mu      sync.RWMutex
+	certs   []*tls.Certificate
+	modTime []time.Time
+}
+
+// NewCertStore 加载证书，任意证书加载失败都返回错误
+func NewCertStore(files []config.CertificateConfig, logger *slog.Logger) (*CertStore, error) {
+	if len(files) == 0 {
+		return nil, errors.New("启用TLS时至少需要配置一个证书")
+	}
+
+	s := &CertStore{files: files, logger: logger}
+	certs, modTime, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	s.certs, s.modTime = certs, modTime
+	return s, nil
+}
+
+// GetCertificate 实现 tls.Config.GetCertificate，返回第一个支持客户端SNI和签名算法的证书
+func (s *CertStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, cert := range s.certs {
+		if hello.SupportsCertificate(cert) == nil {
+			return cert, nil
+		}
+	}
+	return s.certs[0], nil
+}
+
+// Watch 定期检查证书文件的修改时间，变化后重新加载，加载失败时继续使用旧证书
+func (s *CertStore) Watch(interval time.Duration, done <-chan struct{}) {
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !s.changed() {
+				continue
+			}
+			certs, modTime, err := s.load()
+			if err != nil {
+				s.logger.Warn("重新加载证书失败，继续使用旧证书", "error", err)
+				continue
+			}
+			s.mu.Lock()
+			s.certs, s.modTime = certs, modTime
+			s.mu.Unlock()
+			s.logger.Info("证书已重新加载")
+		}
+	}
+}
+
+// changed 判断证书或私钥文件的修改时间是否变化
+func (s *CertStore) changed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i, file := range s.files {
+		for j, path := range []string{file.CertFile, file.KeyFile} {
+			info, err := os.Stat(path)
+			if err == nil && !info.ModTime().Equal(s.modTime[i*2+j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// load 读取所有证书，同时记录证书和私钥文件的修改时间
+func (s *CertStore) load() ([]*tls.Certificate, []time.Time, error) {
+	certs := make([]*tls.Certificate, 0, len(s.files))
+	modTime := make([]time.Time, 0, len(s.files)*2)
+	for _, file := range s.files {
+		for _, path := range []string{file.CertFile, file.KeyFile} {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("读取证书文件失败: %w", err)
+			}
+			modTime = append(modTime, info.ModTime())
+		}
+
+		cert, err := tls.LoadX509KeyPair(file.CertFile, file.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("加载证书 %s 失败: %w", file.CertFile, err)
+		}
+		certs = append(certs, &cert)
+	}
+	return certs, modTime, nil
+}
+
+// newTLSConfig 根据配置创建TLS配置，启用HTTP/2并限制最低版本和加密套件
+func newTLSConfig(cfg config.TLSConfig, certs *CertStore) (*tls.Config, error) {
+	minVersion, ok := tlsVersions[cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("不支持的最低TLS版本: %s", cfg.MinVersion)
+	}
+
+	cipherSuites, err := cipherSuiteIDs(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: certs.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// cipherSuiteIDs 将加密套件名称转换为ID，只允许Go认为安全的TLS 1.2套件
+// TLS 1.3 的加密套件不可配置
+func cipherSuiteIDs(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	secure := make(map[string]*tls.CipherSuite)
+	for _, suite := range tls.CipherSuites() {
+		secure[suite.Name] = suite
+	}
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		suite, ok := secure[name]
+		switch {
+		case insecure[name]:
+			return nil, fmt.Errorf("不安全的加密套件: %s", name)
+		case !ok:
+			return nil, fmt.Errorf("未知的加密套件: %s", name)
+		case len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13:
+			return nil, fmt.Errorf("TLS 1.3 加密套件不可配置: %s", name)
+		}
+		ids = append(ids, suite.ID)
+	}
+	return ids, nil
+}
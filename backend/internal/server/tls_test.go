@@ -0,0 +1,225 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"static-mirrors/pkg/config"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// writeCert 生成自签名证书写入dir，返回证书配置
+func writeCert(t *testing.T, dir string, name string, dnsName string) config.CertificateConfig {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := config.CertificateConfig{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+	if err := os.WriteFile(files.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(files.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// handshake 以serverName完成一次TLS握手，返回服务端证书的域名
+func handshake(t *testing.T, store *CertStore, serverName string) string {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	go func() {
+		defer serverConn.Close()
+		tls.Server(serverConn, &tls.Config{GetCertificate: store.GetCertificate}).Handshake()
+	}()
+
+	client := tls.Client(clientConn, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	if err := client.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	return client.ConnectionState().PeerCertificates[0].DNSNames[0]
+}
+
+func TestCertStoreSNI(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewCertStore([]config.CertificateConfig{
+		writeCert(t, dir, "a", "a.example.com"),
+		writeCert(t, dir, "b", "b.example.com"),
+	}, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for serverName, want := range map[string]string{
+		"a.example.com":     "a.example.com",
+		"b.example.com":     "b.example.com",
+		"other.example.com": "a.example.com",
+	} {
+		if got := handshake(t, store, serverName); got != want {
+			t.Errorf("SNI %s 使用的证书 = %s, want %s", serverName, got, want)
+		}
+	}
+}
+
+func TestCertStoreErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewCertStore(nil, testLogger); err == nil {
+		t.Fatal("没有证书时应返回错误")
+	}
+	if _, err := NewCertStore([]config.CertificateConfig{{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "missing.key")}}, testLogger); err == nil {
+		t.Fatal("证书文件不存在时应返回错误")
+	}
+
+	// 证书与私钥不匹配
+	a := writeCert(t, dir, "a", "a.example.com")
+	b := writeCert(t, dir, "b", "b.example.com")
+	if _, err := NewCertStore([]config.CertificateConfig{{CertFile: a.CertFile, KeyFile: b.KeyFile}}, testLogger); err == nil {
+		t.Fatal("证书与私钥不匹配时应返回错误")
+	}
+}
+
+func TestCertStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	files := writeCert(t, dir, "site", "old.example.com")
+	store, err := NewCertStore([]config.CertificateConfig{files}, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go store.Watch(10*time.Millisecond, done)
+
+	// touch 把文件修改时间设为未来，避免文件系统时间精度导致检测不到变化
+	touch := func() {
+		future := time.Now().Add(time.Hour)
+		for _, path := range []string{files.CertFile, files.KeyFile} {
+			if err := os.Chtimes(path, future, future); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// 无效的证书文件不替换旧证书
+	if err := os.WriteFile(files.CertFile, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	touch()
+	time.Sleep(50 * time.Millisecond)
+	if got := handshake(t, store, "old.example.com"); got != "old.example.com" {
+		t.Fatalf("加载失败后使用的证书 = %s", got)
+	}
+
+	writeCert(t, dir, "site", "new.example.com")
+	touch()
+	deadline := time.Now().Add(5 * time.Second)
+	for handshake(t, store, "new.example.com") != "new.example.com" {
+		if time.Now().After(deadline) {
+			t.Fatal("证书文件变化后没有重新加载")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewCertStore([]config.CertificateConfig{writeCert(t, dir, "a", "a.example.com")}, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.TLSConfig
+		ok   bool
+	}{
+		{"默认配置", config.TLSConfig{}, true},
+		{"TLS 1.3", config.TLSConfig{MinVersion: "1.3"}, true},
+		{"不支持的版本", config.TLSConfig{MinVersion: "1.0"}, false},
+		{"安全的加密套件", config.TLSConfig{CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}}, true},
+		{"不安全的加密套件", config.TLSConfig{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, false},
+		{"未知的加密套件", config.TLSConfig{CipherSuites: []string{"TLS_UNKNOWN"}}, false},
+		{"TLS 1.3加密套件", config.TLSConfig{CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTLSConfig(tt.cfg, store); (err == nil) != tt.ok {
+				t.Fatalf("newTLSConfig() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+// TestHTTP2 TLS配置通过ALPN协商HTTP/2
+func TestHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewCertStore([]config.CertificateConfig{writeCert(t, dir, "a", "a.example.com")}, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := newTLSConfig(config.TLSConfig{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	srv.TLS = tlsConfig
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "HTTP/2.0" {
+		t.Fatalf("协议 = %s", body)
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"static-mirrors/pkg/config"
@@ -20,37 +20,41 @@ type Stats interface {
 	GetTopSources() ([]string, error)
 	GetTraffic() (int64, error)
 	GetRequests() (int64, error)
+	// Close 写入未完成的统计并关闭连接，服务关闭时调用
+	Close() error
 }
 
 // SQLiteStats SQLite统计实现
 type SQLiteStats struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *slog.Logger
 }
 
 // RedisStats Redis统计实现
 type RedisStats struct {
 	client *redis.Client
 	ctx    context.Context
+	logger *slog.Logger
 }
 
 // NewStats 创建新的统计实例
-func NewStats(cfg config.Config) (Stats, error) {
+func NewStats(cfg config.Config, logger *slog.Logger) (Stats, error) {
 	if !cfg.Stats.Enabled {
 		return nil, nil
 	}
 
 	switch cfg.Stats.Type {
 	case "sqlite":
-		return NewSQLiteStats(cfg.Stats.SQLite.Path)
+		return NewSQLiteStats(cfg.Stats.SQLite.Path, logger)
 	case "redis":
-		return NewRedisStats(cfg.Stats.Redis)
+		return NewRedisStats(cfg.Stats.Redis, logger)
 	default:
 		return nil, fmt.Errorf("不支持的统计类型: %s", cfg.Stats.Type)
 	}
 }
 
 // NewSQLiteStats 创建SQLite统计实例
-func NewSQLiteStats(path string) (*SQLiteStats, error) {
+func NewSQLiteStats(path string, logger *slog.Logger) (*SQLiteStats, error) {
 	db, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return nil, fmt.Errorf("打开SQLite数据库失败: %w", err)
@@ -61,12 +65,28 @@ func NewSQLiteStats(path string) (*SQLiteStats, error) {
 		return nil, fmt.Errorf("创建表失败: %w", err)
 	}
 
-	log.Println("SQLite统计初始化成功")
-	return &SQLiteStats{db: db}, nil
+	logger.Info("SQLite统计初始化成功", "path", path)
+	return &SQLiteStats{db: db, logger: logger}, nil
+}
+
+// log 返回日志记录器，未正确初始化时使用默认记录器
+func (s *SQLiteStats) log() *slog.Logger {
+	if s == nil || s.logger == nil {
+		return slog.Default()
+	}
+	return s.logger
+}
+
+// Close 关闭SQLite数据库，确保已记录的统计落盘
+func (s *SQLiteStats) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
 }
 
 // NewRedisStats 创建Redis统计实例
-func NewRedisStats(redisConfig config.RedisConfig) (*RedisStats, error) {
+func NewRedisStats(redisConfig config.RedisConfig, logger *slog.Logger) (*RedisStats, error) {
 	client := redis.NewClient(&redis.Options{
 		Addr:     redisConfig.Addr,
 		Password: redisConfig.Password,
@@ -80,38 +100,55 @@ func NewRedisStats(redisConfig config.RedisConfig) (*RedisStats, error) {
 		return nil, fmt.Errorf("连接Redis失败: %w", err)
 	}
 
-	log.Println("Redis统计初始化成功")
+	logger.Info("Redis统计初始化成功", "addr", redisConfig.Addr)
 	return &RedisStats{
 		client: client,
 		ctx:    ctx,
+		logger: logger,
 	}, nil
 }
 
+// log 返回日志记录器，未正确初始化时使用默认记录器
+func (s *RedisStats) log() *slog.Logger {
+	if s == nil || s.logger == nil {
+		return slog.Default()
+	}
+	return s.logger
+}
+
+// Close 关闭Redis连接
+func (s *RedisStats) Close() error {
+	if s == nil || s.client == nil {
+		return nil
+	}
+	return s.client.Close()
+}
+
 // RecordRequest 记录请求到Redis
 func (s *RedisStats) RecordRequest(url string, source string, bytes int64, duration time.Duration) {
 	// 检查客户端是否为nil
 	if s == nil || s.client == nil || s.ctx == nil {
-		log.Printf("RedisStats is not properly initialized")
+		s.log().Warn("RedisStats is not properly initialized")
 		return
 	}
 
 	// 记录请求数
 	err := s.client.Incr(s.ctx, "stats:requests").Err()
 	if err != nil {
-		log.Printf("记录请求数失败: %v", err)
+		s.log().Warn("记录请求数失败", "error", err)
 	}
 
 	// 记录流量
 	err = s.client.IncrBy(s.ctx, "stats:traffic", bytes).Err()
 	if err != nil {
-		log.Printf("记录流量失败: %v", err)
+		s.log().Warn("记录流量失败", "error", err)
 	}
 
 	// 记录源站请求数
 	sourceKey := fmt.Sprintf("stats:sources:%s", source)
 	err = s.client.Incr(s.ctx, sourceKey).Err()
 	if err != nil {
-		log.Printf("记录源站请求数失败: %v", err)
+		s.log().Warn("记录源站请求数失败", "error", err)
 	}
 
 	// 记录每日统计
@@ -121,38 +158,38 @@ func (s *RedisStats) RecordRequest(url string, source string, bytes int64, durat
 
 	err = s.client.Incr(s.ctx, dailyRequestsKey).Err()
 	if err != nil {
-		log.Printf("记录每日请求数失败: %v", err)
+		s.log().Warn("记录每日请求数失败", "error", err)
 	}
 
 	err = s.client.IncrBy(s.ctx, dailyTrafficKey, bytes).Err()
 	if err != nil {
-		log.Printf("记录每日流量失败: %v", err)
+		s.log().Warn("记录每日流量失败", "error", err)
 	}
 
 	// 设置过期时间
 	err = s.client.Expire(s.ctx, "stats:requests", 7*24*time.Hour).Err()
 	if err != nil {
-		log.Printf("设置请求数过期时间失败: %v", err)
+		s.log().Warn("设置请求数过期时间失败", "error", err)
 	}
 
 	err = s.client.Expire(s.ctx, "stats:traffic", 7*24*time.Hour).Err()
 	if err != nil {
-		log.Printf("设置流量过期时间失败: %v", err)
+		s.log().Warn("设置流量过期时间失败", "error", err)
 	}
 
 	err = s.client.Expire(s.ctx, sourceKey, 7*24*time.Hour).Err()
 	if err != nil {
-		log.Printf("设置源站请求数过期时间失败: %v", err)
+		s.log().Warn("设置源站请求数过期时间失败", "error", err)
 	}
 
 	err = s.client.Expire(s.ctx, dailyRequestsKey, 30*24*time.Hour).Err()
 	if err != nil {
-		log.Printf("设置每日请求数过期时间失败: %v", err)
+		s.log().Warn("设置每日请求数过期时间失败", "error", err)
 	}
 
 	err = s.client.Expire(s.ctx, dailyTrafficKey, 30*24*time.Hour).Err()
 	if err != nil {
-		log.Printf("设置每日流量过期时间失败: %v", err)
+		s.log().Warn("设置每日流量过期时间失败", "error", err)
 	}
 }
 
@@ -160,7 +197,7 @@ func (s *RedisStats) RecordRequest(url string, source string, bytes int64, durat
 func (s *RedisStats) GetStats() (map[string]interface{}, error) {
 	// 检查客户端是否为nil
 	if s == nil || s.client == nil || s.ctx == nil {
-		log.Printf("RedisStats is not properly initialized")
+		s.log().Warn("RedisStats is not properly initialized")
 		return map[string]interface{}{
 			"total_requests": 0,
 			"total_traffic":  0,
@@ -174,7 +211,7 @@ func (s *RedisStats) GetStats() (map[string]interface{}, error) {
 	if err == redis.Nil {
 		requests = 0
 	} else if err != nil {
-		log.Printf("获取请求数失败: %v", err)
+		s.log().Warn("获取请求数失败", "error", err)
 		requests = 0
 	}
 
@@ -183,7 +220,7 @@ func (s *RedisStats) GetStats() (map[string]interface{}, error) {
 	if err == redis.Nil {
 		traffic = 0
 	} else if err != nil {
-		log.Printf("获取流量失败: %v", err)
+		s.log().Warn("获取流量失败", "error", err)
 		traffic = 0
 	}
 
@@ -193,7 +230,7 @@ func (s *RedisStats) GetStats() (map[string]interface{}, error) {
 	if err == redis.Nil {
 		todayRequests = 0
 	} else if err != nil {
-		log.Printf("获取今日请求数失败: %v", err)
+		s.log().Warn("获取今日请求数失败", "error", err)
 		todayRequests = 0
 	}
 
@@ -202,7 +239,7 @@ func (s *RedisStats) GetStats() (map[string]interface{}, error) {
 	if err == redis.Nil {
 		todayTraffic = 0
 	} else if err != nil {
-		log.Printf("获取今日流量失败: %v", err)
+		s.log().Warn("获取今日流量失败", "error", err)
 		todayTraffic = 0
 	}
 
@@ -218,14 +255,14 @@ func (s *RedisStats) GetStats() (map[string]interface{}, error) {
 func (s *RedisStats) GetTopSources() ([]string, error) {
 	// 检查客户端是否为nil
 	if s == nil || s.client == nil || s.ctx == nil {
-		log.Printf("RedisStats is not properly initialized")
+		s.log().Warn("RedisStats is not properly initialized")
 		return []string{}, nil
 	}
 
 	// 获取所有源站键
 	keys, err := s.client.Keys(s.ctx, "stats:sources:*").Result()
 	if err != nil {
-		log.Printf("获取源站键失败: %v", err)
+		s.log().Warn("获取源站键失败", "error", err)
 		return []string{}, nil
 	}
 
@@ -261,7 +298,7 @@ func (s *RedisStats) GetTopSources() ([]string, error) {
 func (s *RedisStats) GetTraffic() (int64, error) {
 	// 检查客户端是否为nil
 	if s == nil || s.client == nil || s.ctx == nil {
-		log.Printf("RedisStats is not properly initialized")
+		s.log().Warn("RedisStats is not properly initialized")
 		return 0, nil
 	}
 
@@ -269,7 +306,7 @@ func (s *RedisStats) GetTraffic() (int64, error) {
 	if err == redis.Nil {
 		return 0, nil
 	} else if err != nil {
-		log.Printf("获取流量失败: %v", err)
+		s.log().Warn("获取流量失败", "error", err)
 		return 0, nil
 	}
 	return traffic, nil
@@ -279,7 +316,7 @@ func (s *RedisStats) GetTraffic() (int64, error) {
 func (s *RedisStats) GetRequests() (int64, error) {
 	// 检查客户端是否为nil
 	if s == nil || s.client == nil || s.ctx == nil {
-		log.Printf("RedisStats is not properly initialized")
+		s.log().Warn("RedisStats is not properly initialized")
 		return 0, nil
 	}
 
@@ -287,7 +324,7 @@ func (s *RedisStats) GetRequests() (int64, error) {
 	if err == redis.Nil {
 		return 0, nil
 	} else if err != nil {
-		log.Printf("获取请求数失败: %v", err)
+		s.log().Warn("获取请求数失败", "error", err)
 		return 0, nil
 	}
 	return requests, nil
@@ -329,7 +366,7 @@ func createTables(db *sql.DB) error {
 func (s *SQLiteStats) RecordRequest(url string, source string, bytes int64, duration time.Duration) {
 	// 检查数据库是否为nil
 	if s == nil || s.db == nil {
-		log.Printf("SQLiteStats is not properly initialized")
+		s.log().Warn("SQLiteStats is not properly initialized")
 		return
 	}
 
@@ -339,7 +376,7 @@ func (s *SQLiteStats) RecordRequest(url string, source string, bytes int64, dura
 		url, source, bytes, duration.Milliseconds(),
 	)
 	if err != nil {
-		log.Printf("记录请求失败: %v", err)
+		s.log().Warn("记录请求失败", "error", err)
 		return
 	}
 
@@ -350,7 +387,7 @@ func (s *SQLiteStats) RecordRequest(url string, source string, bytes int64, dura
 		date, bytes, bytes,
 	)
 	if err != nil {
-		log.Printf("更新每日统计失败: %v", err)
+		s.log().Warn("更新每日统计失败", "error", err)
 	}
 }
 
@@ -358,7 +395,7 @@ func (s *SQLiteStats) RecordRequest(url string, source string, bytes int64, dura
 func (s *SQLiteStats) GetStats() (map[string]interface{}, error) {
 	// 检查数据库是否为nil
 	if s == nil || s.db == nil {
-		log.Printf("SQLiteStats is not properly initialized")
+		s.log().Warn("SQLiteStats is not properly initialized")
 		return map[string]interface{}{
 			"total_requests": 0,
 			"total_traffic":  0,
@@ -371,7 +408,7 @@ func (s *SQLiteStats) GetStats() (map[string]interface{}, error) {
 	var requests int64
 	err := s.db.QueryRow("SELECT COUNT(*) FROM requests").Scan(&requests)
 	if err != nil {
-		log.Printf("获取总请求数失败: %v", err)
+		s.log().Warn("获取总请求数失败", "error", err)
 		requests = 0
 	}
 
@@ -379,7 +416,7 @@ func (s *SQLiteStats) GetStats() (map[string]interface{}, error) {
 	var traffic int64
 	err = s.db.QueryRow("SELECT COALESCE(SUM(bytes), 0) FROM requests").Scan(&traffic)
 	if err != nil {
-		log.Printf("获取总流量失败: %v", err)
+		s.log().Warn("获取总流量失败", "error", err)
 		traffic = 0
 	}
 
@@ -390,7 +427,7 @@ func (s *SQLiteStats) GetStats() (map[string]interface{}, error) {
 	if err == sql.ErrNoRows {
 		todayRequests = 0
 	} else if err != nil {
-		log.Printf("获取今日请求数失败: %v", err)
+		s.log().Warn("获取今日请求数失败", "error", err)
 		todayRequests = 0
 	}
 
@@ -400,7 +437,7 @@ func (s *SQLiteStats) GetStats() (map[string]interface{}, error) {
 	if err == sql.ErrNoRows {
 		todayTraffic = 0
 	} else if err != nil {
-		log.Printf("获取今日流量失败: %v", err)
+		s.log().Warn("获取今日流量失败", "error", err)
 		todayTraffic = 0
 	}
 
@@ -416,7 +453,7 @@ func (s *SQLiteStats) GetStats() (map[string]interface{}, error) {
 func (s *SQLiteStats) GetTopSources() ([]string, error) {
 	// 检查数据库是否为nil
 	if s == nil || s.db == nil {
-		log.Printf("SQLiteStats is not properly initialized")
+		s.log().Warn("SQLiteStats is not properly initialized")
 		return []string{}, nil
 	}
 
@@ -424,7 +461,7 @@ func (s *SQLiteStats) GetTopSources() ([]string, error) {
 		"SELECT source, COUNT(*) as count FROM requests GROUP BY source ORDER BY count DESC LIMIT 5",
 	)
 	if err != nil {
-		log.Printf("获取热门源站失败: %v", err)
+		s.log().Warn("获取热门源站失败", "error", err)
 		return []string{}, nil
 	}
 	defer rows.Close()
@@ -434,7 +471,7 @@ func (s *SQLiteStats) GetTopSources() ([]string, error) {
 		var source string
 		var count int
 		if err := rows.Scan(&source, &count); err != nil {
-			log.Printf("扫描热门源站失败: %v", err)
+			s.log().Warn("扫描热门源站失败", "error", err)
 			continue
 		}
 		sources = append(sources, source)
@@ -447,14 +484,14 @@ func (s *SQLiteStats) GetTopSources() ([]string, error) {
 func (s *SQLiteStats) GetTraffic() (int64, error) {
 	// 检查数据库是否为nil
 	if s == nil || s.db == nil {
-		log.Printf("SQLiteStats is not properly initialized")
+		s.log().Warn("SQLiteStats is not properly initialized")
 		return 0, nil
 	}
 
 	var traffic int64
 	err := s.db.QueryRow("SELECT COALESCE(SUM(bytes), 0) FROM requests").Scan(&traffic)
 	if err != nil {
-		log.Printf("获取流量失败: %v", err)
+		s.log().Warn("获取流量失败", "error", err)
 		return 0, nil
 	}
 	return traffic, nil
@@ -464,14 +501,14 @@ func (s *SQLiteStats) GetTraffic() (int64, error) {
 func (s *SQLiteStats) GetRequests() (int64, error) {
 	// 检查数据库是否为nil
 	if s == nil || s.db == nil {
-		log.Printf("SQLiteStats is not properly initialized")
+		s.log().Warn("SQLiteStats is not properly initialized")
 		return 0, nil
 	}
 
 	var requests int64
 	err := s.db.QueryRow("SELECT COUNT(*) FROM requests").Scan(&requests)
 	if err != nil {
-		log.Printf("获取请求数失败: %v", err)
+		s.log().Warn("获取请求数失败", "error", err)
 		return 0, nil
 	}
 	return requests, nil
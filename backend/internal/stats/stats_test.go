@@ -0,0 +1,76 @@
+package stats
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"static-mirrors/pkg/config"
+)
+
+// discardLogger 丢弃输出的日志记录器
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestSQLiteStatsPersistAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.db")
+	s, err := NewSQLiteStats(path, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RecordRequest("https://registry.npmjs.org/react", "registry.npmjs.org", 100, time.Millisecond)
+	s.RecordRequest("https://registry.npmjs.org/vue", "registry.npmjs.org", 200, time.Millisecond)
+	s.RecordRequest("https://github.com/a/b", "github.com", 50, time.Millisecond)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	// 关闭后重新打开，已记录的统计仍然存在
+	s, err = NewSQLiteStats(path, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if requests, _ := s.GetRequests(); requests != 3 {
+		t.Fatalf("GetRequests() = %d, want 3", requests)
+	}
+	if traffic, _ := s.GetTraffic(); traffic != 350 {
+		t.Fatalf("GetTraffic() = %d, want 350", traffic)
+	}
+	if sources, _ := s.GetTopSources(); !reflect.DeepEqual(sources, []string{"registry.npmjs.org", "github.com"}) {
+		t.Fatalf("GetTopSources() = %v", sources)
+	}
+	stats, err := s.GetStats()
+	if err != nil || stats["today_requests"] != int64(3) || stats["today_traffic"] != int64(350) {
+		t.Fatalf("GetStats() = %v, %v", stats, err)
+	}
+}
+
+func TestCloseUninitialized(t *testing.T) {
+	// 未初始化的统计实例关闭时不会出错
+	var sqlite *SQLiteStats
+	var redis *RedisStats
+	if err := sqlite.Close(); err != nil {
+		t.Fatalf("SQLiteStats.Close() = %v", err)
+	}
+	if err := redis.Close(); err != nil {
+		t.Fatalf("RedisStats.Close() = %v", err)
+	}
+	if err := (&SQLiteStats{}).Close(); err != nil {
+		t.Fatalf("SQLiteStats{}.Close() = %v", err)
+	}
+}
+
+func TestNewStats(t *testing.T) {
+	if s, err := NewStats(config.Config{}, discardLogger); s != nil || err != nil {
+		t.Fatalf("未启用统计时 NewStats() = %v, %v", s, err)
+	}
+
+	cfg := config.Config{Stats: config.StatsConfig{Enabled: true, Type: "csv"}}
+	if _, err := NewStats(cfg, discardLogger); err == nil {
+		t.Fatal("不支持的统计类型应返回错误")
+	}
+}
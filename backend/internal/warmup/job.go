@@ -0,0 +1,243 @@
+package warmup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"static-mirrors/internal/proxy"
+)
+
+// 任务状态
+const (
+	JobListing   = "listing"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobCancelled = "cancelled"
+	JobFailed    = "failed"
+)
+
+// 任务保留策略
+const (
+	maxRetainedJobs = 100
+	maxJobFailures  = 100
+)
+
+// Job 后台包预热任务
+type Job struct {
+	mutex  sync.Mutex
+	cancel context.CancelFunc
+	status JobStatus
+}
+
+// JobStatus 任务进度
+type JobStatus struct {
+	ID         string                 `json:"id"`
+	Package    Package                `json:"package"`
+	Status     string                 `json:"status"`
+	Total      int                    `json:"total"`
+	Done       int                    `json:"done"`
+	Succeeded  int                    `json:"succeeded"`
+	Failed     int                    `json:"failed"`
+	Cached     int                    `json:"cached"`
+	Bytes      int64                  `json:"bytes"`
+	Error      string                 `json:"error,omitempty"`
+	Failures   []proxy.PrefetchResult `json:"failures,omitempty"`
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+}
+
+// Snapshot 获取任务当前进度的副本
+func (j *Job) Snapshot() JobStatus {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	status := j.status
+	status.Failures = append([]proxy.PrefetchResult(nil), j.status.Failures...)
+	return status
+}
+
+// Cancel 取消任务
+func (j *Job) Cancel() {
+	j.cancel()
+}
+
+// finished 任务是否已结束
+func (j *Job) finished() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.status.FinishedAt != nil
+}
+
+// record 记录单个文件的预热结果
+func (j *Job) record(result proxy.PrefetchResult) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.status.Done++
+	if result.OK() {
+		j.status.Succeeded++
+		j.status.Bytes += result.Size
+		if result.Cache == proxy.CacheHit {
+			j.status.Cached++
+		}
+		return
+	}
+
+	j.status.Failed++
+	if len(j.status.Failures) < maxJobFailures {
+		j.status.Failures = append(j.status.Failures, result)
+	}
+}
+
+// finish 结束任务
+func (j *Job) finish(status string, err error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	now := time.Now()
+	j.status.Status = status
+	j.status.FinishedAt = &now
+	if err != nil {
+		j.status.Error = err.Error()
+	}
+}
+
+// StartPackage 启动后台任务预热包内匹配的所有文件
+func (w *Warmer) StartPackage(pkg Package, opts Options) (*Job, error) {
+	if err := pkg.Validate(); err != nil {
+		return nil, err
+	}
+	for _, glob := range pkg.Globs {
+		if _, err := compileGlob(glob); err != nil {
+			return nil, fmt.Errorf("无效的过滤规则 %q: %w", glob, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		cancel: cancel,
+		status: JobStatus{
+			ID:        newJobID(),
+			Package:   pkg,
+			Status:    JobListing,
+			StartedAt: time.Now(),
+		},
+	}
+
+	w.jobsMutex.Lock()
+	w.pruneJobs()
+	w.jobs[job.status.ID] = job
+	w.jobsMutex.Unlock()
+
+	go w.runPackage(ctx, job, opts)
+	return job, nil
+}
+
+// runPackage 执行包预热任务
+func (w *Warmer) runPackage(ctx context.Context, job *Job, opts Options) {
+	defer job.cancel()
+
+	pkg := job.status.Package
+	files, err := w.listFiles(ctx, pkg)
+	if err == nil {
+		files, err = filterFiles(files, pkg.Globs)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			job.finish(JobCancelled, nil)
+		} else {
+			job.finish(JobFailed, err)
+		}
+		return
+	}
+
+	maxFiles := w.settings.Load().config.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultMaxFiles
+	}
+	if len(files) > maxFiles {
+		job.finish(JobFailed, fmt.Errorf("匹配的文件数 %d 超过上限 %d，请缩小过滤规则", len(files), maxFiles))
+		return
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, pkg.MirrorPath(file))
+	}
+
+	job.mutex.Lock()
+	job.status.Total = len(paths)
+	job.status.Status = JobRunning
+	job.mutex.Unlock()
+
+	w.Run(ctx, paths, opts, job.record)
+
+	if ctx.Err() != nil {
+		job.finish(JobCancelled, nil)
+		return
+	}
+	job.finish(JobCompleted, nil)
+}
+
+// Job 根据ID获取任务
+func (w *Warmer) Job(id string) (*Job, bool) {
+	w.jobsMutex.Lock()
+	defer w.jobsMutex.Unlock()
+
+	job, exists := w.jobs[id]
+	return job, exists
+}
+
+// Jobs 获取所有任务的进度，按开始时间倒序
+func (w *Warmer) Jobs() []JobStatus {
+	w.jobsMutex.Lock()
+	jobs := make([]*Job, 0, len(w.jobs))
+	for _, job := range w.jobs {
+		jobs = append(jobs, job)
+	}
+	w.jobsMutex.Unlock()
+
+	snapshots := make([]JobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		snapshots = append(snapshots, job.Snapshot())
+	}
+	sort.Slice(snapshots, func(i, k int) bool {
+		return snapshots[i].StartedAt.After(snapshots[k].StartedAt)
+	})
+	return snapshots
+}
+
+// pruneJobs 删除超出保留数量的已结束任务，调用方需持有jobsMutex
+func (w *Warmer) pruneJobs() {
+	if len(w.jobs) < maxRetainedJobs {
+		return
+	}
+
+	var oldestID string
+	var oldestTime time.Time
+	for id, job := range w.jobs {
+		if !job.finished() {
+			continue
+		}
+		startedAt := job.Snapshot().StartedAt
+		if oldestID == "" || startedAt.Before(oldestTime) {
+			oldestID = id
+			oldestTime = startedAt
+		}
+	}
+	if oldestID != "" {
+		delete(w.jobs, oldestID)
+	}
+}
+
+// newJobID 生成任务ID
+func newJobID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
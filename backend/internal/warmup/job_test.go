@@ -0,0 +1,100 @@
+package warmup
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"static-mirrors/internal/proxy"
+	"static-mirrors/pkg/config"
+)
+
+// newTestWarmer 创建文件列表接口指向本地服务的预热服务，反代服务没有配置源站，预热请求都会失败
+func newTestWarmer(t *testing.T, maxFiles int) *Warmer {
+	t.Helper()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/npm/demo@1.0.0" || r.URL.Query().Get("structure") != "flat" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"files":[{"name":"/package.json"},{"name":"/dist/a.js"},{"name":"/dist/b.js"}]}`))
+	}))
+	t.Cleanup(api.Close)
+
+	cfg := config.Config{}
+	cfg.Security.AllowPrivateNetworks = true
+	cfg.Warmup.ListingAPI = api.URL
+	cfg.Warmup.MaxFiles = maxFiles
+	p := proxy.NewProxy(cfg, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return NewWarmer(p, cfg)
+}
+
+// waitJob 等待任务结束
+func waitJob(t *testing.T, job *Job) JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !job.finished() {
+		if time.Now().After(deadline) {
+			t.Fatal("任务没有结束")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return job.Snapshot()
+}
+
+func TestStartPackage(t *testing.T) {
+	w := newTestWarmer(t, 0)
+	job, err := w.StartPackage(Package{Type: "npm", Name: "demo", Version: "1.0.0", Globs: []string{"/dist/*.js"}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := waitJob(t, job)
+	if status.Status != JobCompleted || status.Total != 2 || status.Done != 2 {
+		t.Fatalf("任务状态 = %+v", status)
+	}
+	// 没有配置源站，每个文件都记录为失败
+	if status.Failed != 2 || len(status.Failures) != 2 {
+		t.Fatalf("失败 %d 个，记录 %d 个", status.Failed, len(status.Failures))
+	}
+
+	if got, ok := w.Job(status.ID); !ok || got != job {
+		t.Fatalf("Job(%s) 没有找到任务", status.ID)
+	}
+	if jobs := w.Jobs(); len(jobs) != 1 || jobs[0].ID != status.ID {
+		t.Fatalf("Jobs() = %+v", jobs)
+	}
+}
+
+func TestStartPackageErrors(t *testing.T) {
+	w := newTestWarmer(t, 2)
+
+	// 参数错误时不创建任务
+	if _, err := w.StartPackage(Package{Type: "npm", Name: "demo"}, Options{}); err == nil {
+		t.Fatal("缺少版本号应返回错误")
+	}
+	if jobs := w.Jobs(); len(jobs) != 0 {
+		t.Fatalf("Jobs() = %+v", jobs)
+	}
+
+	// 匹配的文件数超过 warmup.max_files
+	job, err := w.StartPackage(Package{Type: "npm", Name: "demo", Version: "1.0.0"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := waitJob(t, job); status.Status != JobFailed || status.Error == "" {
+		t.Fatalf("任务状态 = %+v", status)
+	}
+
+	// 文件列表接口返回错误
+	job, err = w.StartPackage(Package{Type: "npm", Name: "missing", Version: "1.0.0"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := waitJob(t, job); status.Status != JobFailed {
+		t.Fatalf("任务状态 = %+v", status)
+	}
+}
@@ -0,0 +1,163 @@
+package warmup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// defaultListingAPI 默认的包文件列表接口
+const defaultListingAPI = "https://data.jsdelivr.com/v1/packages"
+
+// defaultMaxFiles 单个包默认最多预热的文件数
+const defaultMaxFiles = 5000
+
+// Package 需要预热的包
+type Package struct {
+	// 包类型：npm 或 gh
+	Type string `json:"type"`
+	// 包名，npm为包名（可带scope），gh为 owner/repo
+	Name string `json:"name"`
+	// 版本号或Git引用
+	Version string `json:"version"`
+	// 文件过滤规则，支持 * ? **，为空时预热全部文件
+	Globs []string `json:"globs"`
+}
+
+// packageNamePattern 包名允许的字符
+var packageNamePattern = regexp.MustCompile(`^(@[a-z0-9][\w.-]*/)?[a-zA-Z0-9][\w.-]*(/[a-zA-Z0-9][\w.-]*)?$`)
+
+// Validate 校验包参数
+func (pkg Package) Validate() error {
+	switch pkg.Type {
+	case "npm", "gh":
+	default:
+		return fmt.Errorf("不支持的包类型: %s", pkg.Type)
+	}
+	if !packageNamePattern.MatchString(pkg.Name) {
+		return fmt.Errorf("无效的包名: %s", pkg.Name)
+	}
+	if pkg.Type == "gh" && strings.Count(pkg.Name, "/") != 1 {
+		return fmt.Errorf("GitHub包名格式应为 owner/repo")
+	}
+	if pkg.Version == "" || strings.ContainsAny(pkg.Version, "/?#@ ") {
+		return fmt.Errorf("无效的版本号: %s", pkg.Version)
+	}
+	return nil
+}
+
+// String 返回 type/name@version 形式的包标识
+func (pkg Package) String() string {
+	return fmt.Sprintf("%s/%s@%s", pkg.Type, pkg.Name, pkg.Version)
+}
+
+// MirrorPath 返回包内文件的镜像路径
+func (pkg Package) MirrorPath(file string) string {
+	return "/" + pkg.String() + file
+}
+
+// listFiles 通过jsdelivr data API获取包的扁平文件列表
+func (w *Warmer) listFiles(ctx context.Context, pkg Package) ([]string, error) {
+	settings := w.settings.Load()
+	api := strings.TrimRight(settings.config.ListingAPI, "/")
+	if api == "" {
+		api = defaultListingAPI
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s?structure=flat", api, pkg.String()), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := settings.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("获取文件列表失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取文件列表失败: HTTP %d", resp.StatusCode)
+	}
+
+	var listing struct {
+		Files []struct {
+			Name string `json:"name"`
+		} `json:"files"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("解析文件列表失败: %w", err)
+	}
+
+	files := make([]string, 0, len(listing.Files))
+	for _, f := range listing.Files {
+		files = append(files, f.Name)
+	}
+	return files, nil
+}
+
+// filterFiles 按glob规则过滤文件
+func filterFiles(files []string, globs []string) ([]string, error) {
+	if len(globs) == 0 {
+		return files, nil
+	}
+
+	patterns := make([]*regexp.Regexp, 0, len(globs))
+	for _, glob := range globs {
+		pattern, err := compileGlob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("无效的过滤规则 %q: %w", glob, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	var matched []string
+	for _, file := range files {
+		for _, pattern := range patterns {
+			if pattern.MatchString(file) {
+				matched = append(matched, file)
+				break
+			}
+		}
+	}
+	return matched, nil
+}
+
+// compileGlob 将glob规则转换为正则表达式
+// * 匹配除 / 以外的任意字符，** 匹配任意层级目录，? 匹配单个字符
+// 不以 / 开头的规则可以匹配任意目录下的文件
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !strings.HasPrefix(glob, "/") {
+		sb.WriteString("(.*/)?")
+	}
+
+	for i := 0; i < len(glob); i++ {
+		switch ch := glob[i]; ch {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				// **/ 可以匹配零层目录
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					sb.WriteString("(.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,70 @@
+package warmup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterFiles(t *testing.T) {
+	files := []string{
+		"/package.json",
+		"/dist/index.js",
+		"/dist/index.css",
+		"/dist/locale/zh-cn.js",
+		"/src/index.ts",
+	}
+	tests := []struct {
+		name  string
+		globs []string
+		want  []string
+	}{
+		{"不过滤", nil, files},
+		{"单层通配", []string{"/dist/*.js"}, []string{"/dist/index.js"}},
+		{"任意层级", []string{"/dist/**"}, []string{"/dist/index.js", "/dist/index.css", "/dist/locale/zh-cn.js"}},
+		{"**/可以匹配零层目录", []string{"/**/index.js"}, []string{"/dist/index.js"}},
+		{"不以/开头时匹配任意目录", []string{"*.css"}, []string{"/dist/index.css"}},
+		{"单个字符", []string{"/dist/locale/zh-??.js"}, []string{"/dist/locale/zh-cn.js"}},
+		{"多个规则", []string{"/package.json", "/src/**"}, []string{"/package.json", "/src/index.ts"}},
+		{"点号按字面匹配", []string{"/package?json"}, []string{"/package.json"}},
+		{"没有匹配", []string{"/docs/**"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filterFiles(files, tt.globs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("filterFiles(%v) = %v, want %v", tt.globs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackageValidate(t *testing.T) {
+	tests := []struct {
+		pkg Package
+		ok  bool
+	}{
+		{Package{Type: "npm", Name: "vue", Version: "3.4.0"}, true},
+		{Package{Type: "npm", Name: "@vue/runtime-core", Version: "3.4.0"}, true},
+		{Package{Type: "gh", Name: "twbs/bootstrap", Version: "v5.3.0"}, true},
+		{Package{Type: "gh", Name: "bootstrap", Version: "v5.3.0"}, false},
+		{Package{Type: "pypi", Name: "requests", Version: "2.0"}, false},
+		{Package{Type: "npm", Name: "../etc", Version: "1.0"}, false},
+		{Package{Type: "npm", Name: "vue", Version: ""}, false},
+		{Package{Type: "npm", Name: "vue", Version: "3.4.0/../../x"}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.pkg.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s Validate() = %v, want ok %v", tt.pkg, err, tt.ok)
+		}
+	}
+
+	pkg := Package{Type: "npm", Name: "vue", Version: "3.4.0"}
+	if got := pkg.MirrorPath("/dist/vue.js"); got != "/npm/vue@3.4.0/dist/vue.js" {
+		t.Fatalf("MirrorPath() = %s", got)
+	}
+}
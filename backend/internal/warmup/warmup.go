@@ -0,0 +1,145 @@
+package warmup
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"static-mirrors/internal/outbound"
+	"static-mirrors/internal/proxy"
+	"static-mirrors/pkg/config"
+)
+
+// 并发数限制
+const (
+	DefaultConcurrency = 4
+	MaxConcurrency     = 32
+)
+
+// Options 预热参数
+type Options struct {
+	// 并发数
+	Concurrency int `json:"concurrency"`
+	// 忽略已有缓存，强制回源刷新
+	Refresh bool `json:"refresh"`
+}
+
+// Summary 预热结果汇总
+type Summary struct {
+	Total     int   `json:"total"`
+	Succeeded int   `json:"succeeded"`
+	Failed    int   `json:"failed"`
+	Cached    int   `json:"cached"`
+	Bytes     int64 `json:"bytes"`
+	Duration  int64 `json:"duration_ms"`
+}
+
+// Warmer 缓存预热服务
+type Warmer struct {
+	proxy     *proxy.Proxy
+	settings  atomic.Pointer[settings]
+	jobs      map[string]*Job
+	jobsMutex sync.Mutex
+}
+
+// settings 预热配置和文件列表接口客户端，配置热更新时整体替换
+type settings struct {
+	config config.WarmupConfig
+	client *http.Client
+}
+
+// NewWarmer 创建缓存预热服务实例
+func NewWarmer(p *proxy.Proxy, cfg config.Config) *Warmer {
+	w := &Warmer{
+		proxy: p,
+		jobs:  make(map[string]*Job),
+	}
+	w.Reload(cfg)
+	return w
+}
+
+// Reload 应用热更新后的预热配置，进行中的任务继续使用旧配置
+func (w *Warmer) Reload(cfg config.Config) {
+	// 文件列表接口只允许访问配置的接口域名
+	api := cfg.Warmup.ListingAPI
+	if api == "" {
+		api = defaultListingAPI
+	}
+	var hosts []string
+	if u, err := url.Parse(api); err == nil {
+		hosts = append(hosts, u.Host)
+	}
+	guard := outbound.NewGuard(hosts, cfg.Security.AllowPrivateNetworks)
+
+	w.settings.Store(&settings{
+		config: cfg.Warmup,
+		client: guard.Client(30 * time.Second),
+	})
+}
+
+// Run 使用有限的并发数预热URL列表，每完成一个URL调用一次report
+// 已缓存的URL会直接命中缓存，因此中断后重新提交同一列表即可继续预热
+func (w *Warmer) Run(ctx context.Context, urls []string, opts Options, report func(proxy.PrefetchResult)) Summary {
+	start := time.Now()
+	concurrency := normalizeConcurrency(opts.Concurrency)
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	summary := Summary{}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range jobs {
+				result := w.proxy.Prefetch(ctx, target, opts.Refresh)
+
+				mutex.Lock()
+				summary.Total++
+				if result.OK() {
+					summary.Succeeded++
+					summary.Bytes += result.Size
+					if result.Cache == proxy.CacheHit {
+						summary.Cached++
+					}
+				} else {
+					summary.Failed++
+				}
+				if report != nil {
+					report(result)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, target := range urls {
+		select {
+		case jobs <- target:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	summary.Duration = time.Since(start).Milliseconds()
+	return summary
+}
+
+// normalizeConcurrency 将并发数限制在合理范围内
+func normalizeConcurrency(concurrency int) int {
+	if concurrency <= 0 {
+		return DefaultConcurrency
+	}
+	if concurrency > MaxConcurrency {
+		return MaxConcurrency
+	}
+	return concurrency
+}
@@ -0,0 +1,58 @@
+package warmup
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"static-mirrors/internal/proxy"
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestRun(t *testing.T) {
+	cfg := config.Config{}
+	p := proxy.NewProxy(cfg, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w := NewWarmer(p, cfg)
+
+	// 没有配置源站，每个URL都预热失败，每个URL报告一次
+	urls := []string{"/npm/a.js", "/npm/b.js", "https://example.com/c.js"}
+	var mutex sync.Mutex
+	reported := make(map[string]bool)
+	summary := w.Run(context.Background(), urls, Options{Concurrency: 2}, func(result proxy.PrefetchResult) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		reported[result.URL] = true
+	})
+
+	if summary.Total != 3 || summary.Failed != 3 || summary.Succeeded != 0 {
+		t.Fatalf("Run() = %+v", summary)
+	}
+	for _, u := range urls {
+		if !reported[u] {
+			t.Fatalf("没有报告 %s", u)
+		}
+	}
+
+	// 已取消的上下文不再提交新的URL
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if summary := w.Run(ctx, urls, Options{}, nil); summary.Total > 1 {
+		t.Fatalf("取消后仍预热了 %d 个URL", summary.Total)
+	}
+}
+
+func TestNormalizeConcurrency(t *testing.T) {
+	for in, want := range map[int]int{0: DefaultConcurrency, -1: DefaultConcurrency, 8: 8, 1000: MaxConcurrency} {
+		if got := normalizeConcurrency(in); got != want {
+			t.Errorf("normalizeConcurrency(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
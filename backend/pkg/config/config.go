@@ -2,8 +2,9 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"sync/atomic"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
@@ -14,7 +15,10 @@ type Config struct {
 	Cache    CacheConfig    `yaml:"cache"`
 	Stats    StatsConfig    `yaml:"stats"`
 	Security SecurityConfig `yaml:"security"`
+	Proxy    ProxyConfig    `yaml:"proxy"`
+	Warmup   WarmupConfig   `yaml:"warmup"`
 	Log      LogConfig      `yaml:"log"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
 }
 
 // AppConfig 应用基本配置
@@ -24,6 +28,37 @@ type AppConfig struct {
 	Host    string `yaml:"host"`
 	Port    int    `yaml:"port"`
 	Debug   bool   `yaml:"debug"`
+	// HTTPS监听配置，启用后 port 端口提供HTTPS（含HTTP/2）服务
+	TLS TLSConfig `yaml:"tls"`
+	// 收到SIGTERM后等待进行中请求完成的时间（秒），超时后中止未完成的下载，默认30秒
+	ShutdownTimeout int `yaml:"shutdown_timeout"`
+	// 镜像对外的访问地址（如 https://mirror.example.com），改写元数据中的下载地址时使用；
+	// 为空时根据请求的Host推断
+	PublicURL string `yaml:"public_url"`
+	// 可信的反向代理地址（IP或CIDR），只有来自这些地址的请求才会采用
+	// X-Forwarded-For、X-Forwarded-Host、X-Forwarded-Proto 等头
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// TLSConfig HTTPS监听配置
+type TLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 证书列表，按客户端SNI选择，没有匹配的证书时使用第一个
+	Certificates []CertificateConfig `yaml:"certificates"`
+	// 最低TLS版本：1.2（默认）或 1.3
+	MinVersion string `yaml:"min_version"`
+	// TLS 1.2 允许的加密套件（如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256），为空时使用Go的默认安全套件
+	CipherSuites []string `yaml:"cipher_suites"`
+	// 证书文件检查间隔（秒），文件变化后自动重新加载，默认30秒
+	ReloadInterval int `yaml:"reload_interval"`
+	// HTTP跳转HTTPS的监听端口，0表示不启用
+	RedirectPort int `yaml:"redirect_port"`
+}
+
+// CertificateConfig 证书和私钥文件路径（PEM格式）
+type CertificateConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
 }
 
 // SourceConfig 源站配置
@@ -31,8 +66,40 @@ type SourceConfig struct {
 	Name    string `yaml:"name"`
 	Domain  string `yaml:"domain"`
 	Enabled bool   `yaml:"enabled"`
+	// 源站类型：cdn（默认，静态CDN）、github、npm、goproxy、pypi、maven、helm 或 fonts
+	Type string `yaml:"type"`
+	// 可选的服务端访问令牌，回源时以Authorization头发送，避免匿名请求被限流
+	Token string `yaml:"token"`
+	// 源站重定向处理方式：follow（默认，镜像内部跟随并缓存最终响应）或 rewrite（改写Location为镜像路径）
+	Redirect string `yaml:"redirect"`
+	// 允许跟随或改写的其他上游域名（如源站的对象存储域名）
+	RedirectHosts []string `yaml:"redirect_hosts"`
+	// 路径代理模式下的路径前缀（如 /cdnjs），为空时只能通过 /mirror?url= 访问
+	Prefix string `yaml:"prefix"`
+	// 包仓库类源站元数据（如npm packument）的缓存时间（秒），制品本身永久缓存
+	MetadataTTL int `yaml:"metadata_ttl"`
+	// goproxy源站允许透传的校验和数据库名称（如 sum.golang.org），为空时不代理 /sumdb/ 请求
+	SumDB string `yaml:"sumdb"`
 }
 
+// 源站类型
+const (
+	SourceTypeCDN     = "cdn"
+	SourceTypeGitHub  = "github"
+	SourceTypeNPM     = "npm"
+	SourceTypeGoProxy = "goproxy"
+	SourceTypePyPI    = "pypi"
+	SourceTypeMaven   = "maven"
+	SourceTypeHelm    = "helm"
+	SourceTypeFonts   = "fonts"
+)
+
+// 源站重定向处理方式
+const (
+	RedirectFollow  = "follow"
+	RedirectRewrite = "rewrite"
+)
+
 // CacheConfig 缓存配置
 type CacheConfig struct {
 	Enabled  bool                `yaml:"enabled"`
@@ -42,6 +109,18 @@ type CacheConfig struct {
 	TTL      CacheTTLConfig      `yaml:"ttl"`
 	Strategy CacheStrategyConfig `yaml:"strategy"`
 	Purge    CachePurgeConfig    `yaml:"purge"`
+	Stale    CacheStaleConfig    `yaml:"stale"`
+	// 单个响应允许写入缓存的最大字节数
+	MaxObjectSize int64 `yaml:"max_object_size"`
+	// 离线模式：只从缓存提供内容，不访问源站
+	Offline bool `yaml:"offline"`
+}
+
+// CacheStaleConfig 陈旧缓存配置
+type CacheStaleConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 缓存过期后仍可作为陈旧内容提供的最长时间（秒）
+	MaxStale int `yaml:"max_stale"`
 }
 
 // CacheStrategyConfig 缓存策略配置
@@ -95,6 +174,10 @@ type SQLiteConfig struct {
 type SecurityConfig struct {
 	RateLimit   RateLimitConfig `yaml:"rate_limit"`
 	BlockedURLs []string        `yaml:"blocked_urls"`
+	// 允许出站请求访问内网、回环和链路本地地址，仅用于本地开发和测试
+	AllowPrivateNetworks bool `yaml:"allow_private_networks"`
+	// 管理接口令牌，请求需要带上 Authorization: Bearer <token>，为空时管理接口不可用
+	AdminToken string `yaml:"admin_token"`
 }
 
 // RateLimitConfig 速率限制配置
@@ -103,40 +186,140 @@ type RateLimitConfig struct {
 	RequestsPerMinute int  `yaml:"requests_per_minute"`
 }
 
+// ProxyConfig 反代配置
+type ProxyConfig struct {
+	// 连接源站（含TLS握手）的超时（秒），0表示使用默认值5秒
+	ConnectTimeout int `yaml:"connect_timeout"`
+	// 请求发出后等待源站响应头的超时（秒），0表示使用默认值15秒
+	ResponseHeaderTimeout int `yaml:"response_header_timeout"`
+	// 读取响应体时等待源站数据的超时（秒），0表示使用默认值30秒；不限制整个下载的时长
+	ReadTimeout    int                  `yaml:"read_timeout"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+}
+
+// CircuitBreakerConfig 源站熔断配置
+type CircuitBreakerConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 统计窗口（秒），窗口结束后重新计数
+	WindowSeconds int `yaml:"window_seconds"`
+	// 窗口内至少有这么多请求才会计算错误率
+	MinRequests int `yaml:"min_requests"`
+	// 触发熔断的错误率（0-1）
+	ErrorRate float64 `yaml:"error_rate"`
+	// 熔断打开后多久进入半开状态（秒）
+	OpenSeconds int `yaml:"open_seconds"`
+}
+
+// WarmupConfig 缓存预热配置
+type WarmupConfig struct {
+	// 包文件列表接口地址（jsdelivr data API）
+	ListingAPI string `yaml:"listing_api"`
+	// 单个包最多预热的文件数
+	MaxFiles int `yaml:"max_files"`
+}
+
 // LogConfig 日志配置
 type LogConfig struct {
 	Level  string `yaml:"level"`
 	Format string `yaml:"format"`
+	// 访问日志
+	Access AccessLogConfig `yaml:"access"`
 }
 
-// 全局配置实例
-var GlobalConfig Config
+// AccessLogConfig 访问日志配置，每个请求一行，写入单独的文件
+type AccessLogConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 日志文件路径
+	Path string `yaml:"path"`
+	// 格式：json 或 combined（Combined Log Format，末尾追加缓存状态、源站等字段）
+	Format string `yaml:"format"`
+	// 文件达到该大小（MB）时轮转，0表示不按大小轮转
+	MaxSize int `yaml:"max_size"`
+	// 按时间轮转：hourly 或 daily，为空表示不按时间轮转
+	RotateInterval string `yaml:"rotate_interval"`
+	// 保留的轮转文件数，0表示全部保留
+	MaxFiles int `yaml:"max_files"`
+	// 使用gzip压缩轮转后的文件
+	Compress bool `yaml:"compress"`
+	// 按路径前缀采样，匹配多个前缀时使用最长的前缀，未匹配的请求全部记录
+	Sampling []AccessLogSamplingConfig `yaml:"sampling"`
+}
+
+// AccessLogSamplingConfig 访问日志采样规则，状态码为4xx或5xx的请求不参与采样，总是记录
+type AccessLogSamplingConfig struct {
+	Prefix string `yaml:"prefix"`
+	// 记录的比例，0-1之间
+	Rate float64 `yaml:"rate"`
+}
+
+// MetricsConfig Prometheus指标配置
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 指标路径，默认 /metrics
+	Path string `yaml:"path"`
+	// 单独监听的地址，如 127.0.0.1:9100，为空时在服务端口上提供
+	Listen string `yaml:"listen"`
+	// 访问令牌，设置后抓取时需要带上 Authorization: Bearer <token>
+	Token string `yaml:"token"`
+}
+
+// current 当前生效的配置，热更新时整体替换，读取方拿到的快照不会被修改
+var current atomic.Pointer[Config]
+
+// configPath 配置文件路径，重新加载时使用
+var configPath string
 
 // LoadConfig 加载配置文件
-func LoadConfig(configPath string) error {
-	viper.SetConfigFile(configPath)
-	viper.AutomaticEnv()
+func LoadConfig(path string) error {
+	cfg, err := CheckFile(path)
+	if err != nil {
+		return err
+	}
 
-	if err := viper.ReadInConfig(); err != nil {
-		return fmt.Errorf("读取配置文件失败: %w", err)
+	configPath = path
+	current.Store(&cfg)
+	return nil
+}
+
+// readConfig 读取并解析配置文件，再应用 STATIC_MIRRORS_ 开头的环境变量，返回被环境变量覆盖的配置项
+func readConfig(path string) (Config, map[string]string, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return Config{}, nil, fmt.Errorf("读取配置文件失败: %w", err)
 	}
 
-	if err := viper.Unmarshal(&GlobalConfig); err != nil {
-		return fmt.Errorf("解析配置文件失败: %w", err)
+	settings := v.AllSettings()
+	overrides, err := applyEnv(settings)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	for key, value := range settings {
+		v.Set(key, value)
 	}
 
-	log.Println("配置文件加载成功")
-	return nil
+	// 配置结构体使用yaml标签，解码时需按yaml标签匹配下划线形式的键
+	var cfg Config
+	if err := v.Unmarshal(&cfg, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "yaml"
+	}); err != nil {
+		return Config{}, nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	return cfg, overrides, nil
 }
 
-// GetConfig 获取全局配置
+// GetConfig 获取当前配置的快照
 func GetConfig() Config {
-	return GlobalConfig
+	if cfg := current.Load(); cfg != nil {
+		return *cfg
+	}
+	return Config{}
 }
 
 // GetSourceConfig 根据域名获取源站配置
 func GetSourceConfig(domain string) *SourceConfig {
-	for _, source := range GlobalConfig.Sources {
+	for _, source := range GetConfig().Sources {
 		if source.Domain == domain && source.Enabled {
 			return &source
 		}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 覆盖配置项的环境变量前缀
+// 配置项路径转为大写，"."和列表下标用"_"连接，如 cache.redis.addr 对应 STATIC_MIRRORS_CACHE_REDIS_ADDR，
+// sources[0].token 对应 STATIC_MIRRORS_SOURCES_0_TOKEN
+const EnvPrefix = "STATIC_MIRRORS_"
+
+// envFileSuffix 环境变量名加上此后缀时，取值为该文件的内容，用于从Docker或Kubernetes的secret文件读取密码和令牌
+const envFileSuffix = "_FILE"
+
+// errEnvReported 环境变量的问题已经逐项记录
+var errEnvReported = errors.New("环境变量取值有误")
+
+// envName 返回配置项对应的环境变量名
+func envName(key string) string {
+	key = strings.NewReplacer(".", "_", "[", "_", "]", "").Replace(key)
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+// applyEnv 按配置结构把环境变量覆盖到配置文件的取值上，返回被覆盖的配置项及其环境变量名
+func applyEnv(settings map[string]interface{}) (map[string]string, error) {
+	e := &envApplier{overrides: make(map[string]string)}
+	e.walk("", settings, reflect.TypeOf(Config{}))
+	if len(e.problems) > 0 {
+		return nil, &ValidationError{Problems: e.problems}
+	}
+	return e.overrides, nil
+}
+
+// envApplier 记录覆盖结果和环境变量中的问题
+type envApplier struct {
+	overrides map[string]string
+	problems  []Problem
+}
+
+// add 记录一个环境变量问题
+func (e *envApplier) add(key string, name string, format string, args ...interface{}) {
+	e.problems = append(e.problems, Problem{Key: key, Message: fmt.Sprintf("环境变量 %s: ", name) + fmt.Sprintf(format, args...)})
+}
+
+// walk 递归处理结构体t的各个配置项，m为对应的配置取值
+// 嵌套结构体逐项覆盖，结构体列表既可以整体覆盖，也可以按下标覆盖单个元素的配置项
+func (e *envApplier) walk(key string, m map[string]interface{}, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		childKey := joinKey(key, name)
+
+		if field.Type.Kind() == reflect.Struct {
+			child, exists := m[name].(map[string]interface{})
+			if !exists {
+				child = make(map[string]interface{})
+			}
+			e.walk(childKey, child, field.Type)
+			if exists || len(child) > 0 {
+				m[name] = child
+			}
+			continue
+		}
+
+		if value, ok := e.lookup(childKey, field.Type); ok {
+			m[name] = value
+		}
+
+		if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
+			items, _ := m[name].([]interface{})
+			for j, item := range items {
+				if element, ok := item.(map[string]interface{}); ok {
+					e.walk(fmt.Sprintf("%s[%d]", childKey, j), element, field.Type.Elem())
+				}
+			}
+		}
+	}
+}
+
+// lookup 读取配置项对应的环境变量并按类型t解析
+func (e *envApplier) lookup(key string, t reflect.Type) (interface{}, bool) {
+	name := envName(key)
+	raw, ok := e.env(key, name)
+	if !ok {
+		return nil, false
+	}
+
+	value, err := e.parse(key, name, raw, t)
+	if err != nil {
+		if err != errEnvReported {
+			e.add(key, name, "%v", err)
+		}
+		return nil, false
+	}
+	e.overrides[key] = name
+	return value, true
+}
+
+// env 读取环境变量，设置了 NAME_FILE 时读取文件内容并去掉末尾换行
+func (e *envApplier) env(key string, name string) (string, bool) {
+	value, ok := os.LookupEnv(name)
+	path, fromFile := os.LookupEnv(name + envFileSuffix)
+	if !fromFile {
+		return value, ok
+	}
+	if ok {
+		e.add(key, name, "不能同时设置 %s 和 %s", name, name+envFileSuffix)
+		return "", false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		e.add(key, name+envFileSuffix, "读取文件失败: %v", err)
+		return "", false
+	}
+	return strings.TrimRight(string(data), "\r\n"), true
+}
+
+// parse 按配置项类型解析环境变量的取值
+// 字符串列表可以用逗号分隔，其他列表和映射使用YAML或JSON，如 [{"name": "npm", ...}]
+func (e *envApplier) parse(key string, name string, raw string, t reflect.Type) (interface{}, error) {
+	value := strings.TrimSpace(raw)
+	switch t.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("应为 true 或 false: %s", value)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("应为整数: %s", value)
+		}
+		return n, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("应为数字: %s", value)
+		}
+		return f, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String && !strings.HasPrefix(value, "[") {
+			items := []interface{}{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			return items, nil
+		}
+	}
+
+	// 列表和映射按配置结构检查后再使用
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &node); err != nil {
+		return nil, fmt.Errorf("解析失败: %w", err)
+	}
+	if len(node.Content) == 0 {
+		return nil, fmt.Errorf("取值为空")
+	}
+	s := &schemaChecker{lines: make(map[string]int)}
+	s.check(key, node.Content[0], t)
+	for _, problem := range s.problems {
+		e.add(problem.Key, name, "%s", problem.Message)
+	}
+	if len(s.problems) > 0 {
+		return nil, errEnvReported
+	}
+
+	var decoded interface{}
+	if err := node.Content[0].Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("解析失败: %w", err)
+	}
+	return decoded, nil
+}
+
+// envSource 返回覆盖了配置项或其上级配置项的环境变量名
+func envSource(overrides map[string]string, key string) (string, bool) {
+	for key != "" {
+		if name, ok := overrides[key]; ok {
+			return name, true
+		}
+		i := strings.LastIndexAny(key, ".[")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return "", false
+}
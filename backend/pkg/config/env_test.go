@@ -0,0 +1,214 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testConfig 测试使用的最小配置文件
+const testConfig = `app:
+  name: "static-mirrors"
+  host: "0.0.0.0"
+  port: 8080
+cache:
+  enabled: false
+  type: "memory"
+  ttl:
+    default: 3600
+    max: 86400
+security:
+  blocked_urls:
+    - "cdn.example.com/npm/evil"
+sources:
+  - name: "npm"
+    domain: "registry.npmjs.org"
+    enabled: true
+    type: "npm"
+    prefix: "/npm"
+`
+
+// writeTestConfig 把配置写入临时文件并返回路径
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"app.port", "STATIC_MIRRORS_APP_PORT"},
+		{"cache.redis.addr", "STATIC_MIRRORS_CACHE_REDIS_ADDR"},
+		{"sources[0].token", "STATIC_MIRRORS_SOURCES_0_TOKEN"},
+		{"log.access.sampling[12].rate", "STATIC_MIRRORS_LOG_ACCESS_SAMPLING_12_RATE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := envName(tt.key); got != tt.want {
+				t.Fatalf("envName(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFileEnv(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(secretFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(cfg Config) interface{}
+		want  interface{}
+		// 期望的错误信息片段，为空时期望成功
+		wantErr string
+	}{
+		{
+			name:  "没有环境变量",
+			check: func(cfg Config) interface{} { return cfg.App.Port },
+			want:  8080,
+		},
+		{
+			name:  "整数",
+			env:   map[string]string{"STATIC_MIRRORS_APP_PORT": "9000"},
+			check: func(cfg Config) interface{} { return cfg.App.Port },
+			want:  9000,
+		},
+		{
+			name:  "布尔值",
+			env:   map[string]string{"STATIC_MIRRORS_APP_DEBUG": "true"},
+			check: func(cfg Config) interface{} { return cfg.App.Debug },
+			want:  true,
+		},
+		{
+			name:  "配置文件中没有的嵌套配置项",
+			env:   map[string]string{"STATIC_MIRRORS_CACHE_REDIS_ADDR": "redis:6379"},
+			check: func(cfg Config) interface{} { return cfg.Cache.Redis.Addr },
+			want:  "redis:6379",
+		},
+		{
+			name:  "逗号分隔的字符串列表",
+			env:   map[string]string{"STATIC_MIRRORS_SECURITY_BLOCKED_URLS": "a.example.com/x, b.example.com"},
+			check: func(cfg Config) interface{} { return cfg.Security.BlockedURLs },
+			want:  []string{"a.example.com/x", "b.example.com"},
+		},
+		{
+			name:  "JSON字符串列表",
+			env:   map[string]string{"STATIC_MIRRORS_APP_TRUSTED_PROXIES": `["10.0.0.0/8", "::1"]`},
+			check: func(cfg Config) interface{} { return cfg.App.TrustedProxies },
+			want:  []string{"10.0.0.0/8", "::1"},
+		},
+		{
+			name:  "按下标覆盖列表元素",
+			env:   map[string]string{"STATIC_MIRRORS_SOURCES_0_TOKEN": "env-token"},
+			check: func(cfg Config) interface{} { return []string{cfg.Sources[0].Name, cfg.Sources[0].Token} },
+			want:  []string{"npm", "env-token"},
+		},
+		{
+			name:  "从文件读取",
+			env:   map[string]string{"STATIC_MIRRORS_SOURCES_0_TOKEN_FILE": secretFile},
+			check: func(cfg Config) interface{} { return cfg.Sources[0].Token },
+			want:  "file-token",
+		},
+		{
+			name: "整体覆盖结构体列表",
+			env:  map[string]string{"STATIC_MIRRORS_SOURCES": `[{"name": "unpkg", "domain": "unpkg.com", "enabled": true}]`},
+			check: func(cfg Config) interface{} {
+				return []interface{}{cfg.Sources[0].Name, cfg.Sources[0].Domain, len(cfg.Sources)}
+			},
+			want: []interface{}{"unpkg", "unpkg.com", 1},
+		},
+		{
+			name:    "整数格式错误",
+			env:     map[string]string{"STATIC_MIRRORS_APP_PORT": "abc"},
+			wantErr: "环境变量 STATIC_MIRRORS_APP_PORT: 应为整数",
+		},
+		{
+			name:    "布尔值格式错误",
+			env:     map[string]string{"STATIC_MIRRORS_APP_DEBUG": "yes"},
+			wantErr: "应为 true 或 false",
+		},
+		{
+			name: "同时设置取值和文件",
+			env: map[string]string{
+				"STATIC_MIRRORS_SOURCES_0_TOKEN":      "env-token",
+				"STATIC_MIRRORS_SOURCES_0_TOKEN_FILE": secretFile,
+			},
+			wantErr: "不能同时设置",
+		},
+		{
+			name:    "文件不存在",
+			env:     map[string]string{"STATIC_MIRRORS_SOURCES_0_TOKEN_FILE": filepath.Join(t.TempDir(), "missing")},
+			wantErr: "读取文件失败",
+		},
+		{
+			name:    "结构体列表中的未知配置项",
+			env:     map[string]string{"STATIC_MIRRORS_SOURCES": `[{"name": "unpkg", "domian": "unpkg.com"}]`},
+			wantErr: "domian",
+		},
+		{
+			name:    "取值校验失败时注明环境变量",
+			env:     map[string]string{"STATIC_MIRRORS_APP_PORT": "0"},
+			wantErr: "（来自环境变量 STATIC_MIRRORS_APP_PORT）",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := CheckFile(writeTestConfig(t, testConfig))
+			if tt.wantErr != "" {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CheckFile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckFile() error = %v", err)
+			}
+			if got := tt.check(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvSource(t *testing.T) {
+	overrides := map[string]string{
+		"app.port": "STATIC_MIRRORS_APP_PORT",
+		"sources":  "STATIC_MIRRORS_SOURCES",
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"app.port", "STATIC_MIRRORS_APP_PORT"},
+		{"sources[0].domain", "STATIC_MIRRORS_SOURCES"},
+		{"app.host", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			name, _ := envSource(overrides, tt.key)
+			if name != tt.want {
+				t.Fatalf("envSource(%q) = %q, want %q", tt.key, name, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// restartKeys 修改后需要重启才能生效的配置项，热更新时拒绝
+var restartKeys = []string{
+	"app.host",
+	"app.port",
+	"app.debug",
+	"app.tls",
+	"app.trusted_proxies",
+	"cache.enabled",
+	"cache.type",
+	"cache.redis",
+	"cache.memory",
+	"stats",
+	"proxy.connect_timeout",
+	"proxy.response_header_timeout",
+	"proxy.read_timeout",
+	"log.format",
+	"log.access.enabled",
+	"log.access.path",
+	"log.access.format",
+	"log.access.max_size",
+	"log.access.rotate_interval",
+	"log.access.max_files",
+	"log.access.compress",
+	"metrics",
+}
+
+// secretFields 变更日志中需要隐藏取值的字段
+var secretFields = map[string]bool{
+	"password":    true,
+	"token":       true,
+	"admin_token": true,
+}
+
+// watchDelay 配置文件变化后等待写入完成的时间
+const watchDelay = 200 * time.Millisecond
+
+// reloadMutex 保证同一时间只有一次重新加载
+var reloadMutex sync.Mutex
+
+// Change 一个配置项的变更
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+// String 返回变更的可读描述
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.Old, c.New)
+}
+
+// Reload 重新读取配置文件，校验通过且只修改了可热更新的配置项时原子替换当前配置，
+// 并在同一把锁内调用apply把新配置应用到各服务，保证多次重新加载按顺序生效
+// 返回变更列表，出错时当前配置保持不变，没有变化时不调用apply
+func Reload(apply func(Config, []Change)) ([]Change, error) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	next, err := CheckFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := Diff(GetConfig(), next)
+	var restart []string
+	for _, change := range changes {
+		if requiresRestart(change.Key) {
+			restart = append(restart, change.Key)
+		}
+	}
+	if len(restart) > 0 {
+		return nil, fmt.Errorf("以下配置需要重启才能生效: %s", strings.Join(restart, ", "))
+	}
+
+	if len(changes) > 0 {
+		current.Store(&next)
+		apply(next, changes)
+	}
+	return changes, nil
+}
+
+// Watch 监听配置文件变化，文件被修改或替换时调用onChange
+// 编辑器保存文件可能触发多次写入事件，最后一次事件之后等待watchDelay再调用
+func Watch(onChange func()) {
+	var mutex sync.Mutex
+	var timer *time.Timer
+
+	v := viper.New()
+	v.SetConfigFile(configPath)
+	v.OnConfigChange(func(fsnotify.Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(watchDelay, onChange)
+	})
+	v.WatchConfig()
+}
+
+// Diff 按yaml键比较两份配置，返回有变化的配置项
+func Diff(old, next Config) []Change {
+	var changes []Change
+	diffValue("", reflect.ValueOf(old), reflect.ValueOf(next), false, &changes)
+	return changes
+}
+
+// diffValue 递归比较结构体字段，结构体切片按下标比较
+func diffValue(key string, a, b reflect.Value, secret bool, changes *[]Change) {
+	switch {
+	case a.Kind() == reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			name := strings.Split(a.Type().Field(i).Tag.Get("yaml"), ",")[0]
+			diffValue(joinKey(key, name), a.Field(i), b.Field(i), secretFields[name], changes)
+		}
+
+	case a.Kind() == reflect.Slice && a.Type().Elem().Kind() == reflect.Struct:
+		// 有名称的条目（如源站）按名称对应，插入条目不会影响其他条目
+		if byName(a) != nil && byName(b) != nil {
+			diffByName(key, a, b, secret, changes)
+			return
+		}
+		for i := 0; i < a.Len() || i < b.Len(); i++ {
+			elemKey := fmt.Sprintf("%s[%d]", key, i)
+			switch {
+			case i >= a.Len():
+				*changes = append(*changes, Change{Key: elemKey, Old: "<无>", New: formatValue(b.Index(i), secret)})
+			case i >= b.Len():
+				*changes = append(*changes, Change{Key: elemKey, Old: formatValue(a.Index(i), secret), New: "<无>"})
+			default:
+				diffValue(elemKey, a.Index(i), b.Index(i), secret, changes)
+			}
+		}
+
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*changes = append(*changes, Change{Key: key, Old: formatValue(a, secret), New: formatValue(b, secret)})
+		}
+	}
+}
+
+// diffByName 按名称比较条目，保持新配置中的顺序，删除的条目排在最后
+func diffByName(key string, a, b reflect.Value, secret bool, changes *[]Change) {
+	oldItems := byName(a)
+	for i := 0; i < b.Len(); i++ {
+		name := b.Index(i).FieldByName("Name").String()
+		elemKey := fmt.Sprintf("%s[%s]", key, name)
+		if old, ok := oldItems[name]; ok {
+			diffValue(elemKey, old, b.Index(i), secret, changes)
+		} else {
+			*changes = append(*changes, Change{Key: elemKey, Old: "<无>", New: formatValue(b.Index(i), secret)})
+		}
+	}
+
+	newItems := byName(b)
+	for i := 0; i < a.Len(); i++ {
+		name := a.Index(i).FieldByName("Name").String()
+		if _, ok := newItems[name]; !ok {
+			*changes = append(*changes, Change{Key: fmt.Sprintf("%s[%s]", key, name), Old: formatValue(a.Index(i), secret), New: "<无>"})
+		}
+	}
+}
+
+// byName 按Name字段索引条目，没有Name字段或名称为空、重复时返回nil
+func byName(v reflect.Value) map[string]reflect.Value {
+	if _, ok := v.Type().Elem().FieldByName("Name"); !ok {
+		return nil
+	}
+	items := make(map[string]reflect.Value, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		name := v.Index(i).FieldByName("Name").String()
+		if _, exists := items[name]; exists || name == "" {
+			return nil
+		}
+		items[name] = v.Index(i)
+	}
+	return items
+}
+
+// formatValue 格式化配置值，敏感字段只显示是否设置
+func formatValue(v reflect.Value, secret bool) string {
+	if secret {
+		if v.IsZero() {
+			return "<空>"
+		}
+		return "******"
+	}
+	if v.Kind() == reflect.Struct {
+		// 新增或删除的源站等条目只显示名称和域名，避免输出令牌
+		if name := v.FieldByName("Name"); name.IsValid() {
+			if domain := v.FieldByName("Domain"); domain.IsValid() {
+				return fmt.Sprintf("%v(%v)", name.Interface(), domain.Interface())
+			}
+		}
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// requiresRestart 判断配置项是否需要重启才能生效
+func requiresRestart(key string) bool {
+	for _, restartKey := range restartKeys {
+		if key == restartKey || strings.HasPrefix(key, restartKey+".") || strings.HasPrefix(key, restartKey+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// joinKey 拼接多级配置键
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
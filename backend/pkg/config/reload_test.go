@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := Config{
+		App: AppConfig{Port: 8080},
+		Sources: []SourceConfig{
+			{Name: "npm", Domain: "registry.npmjs.org", Token: "old"},
+			{Name: "github", Domain: "github.com"},
+		},
+		Cache:    CacheConfig{Redis: RedisConfig{Password: "secret"}},
+		Security: SecurityConfig{AdminToken: "old-admin"},
+	}
+	next := Config{
+		App: AppConfig{Port: 9090},
+		Sources: []SourceConfig{
+			// 在前面插入新源站不影响按名称对应的其他源站
+			{Name: "pypi", Domain: "pypi.org"},
+			{Name: "npm", Domain: "registry.npmjs.org", Token: "new"},
+		},
+		Cache:    CacheConfig{Redis: RedisConfig{Password: ""}},
+		Security: SecurityConfig{AdminToken: "new-admin"},
+	}
+
+	got := make([]string, 0)
+	for _, change := range Diff(old, next) {
+		got = append(got, change.String())
+	}
+	want := []string{
+		"app.port: 8080 -> 9090",
+		"sources[pypi]: <无> -> pypi(pypi.org)",
+		"sources[npm].token: ****** -> ******",
+		"sources[github]: github(github.com) -> <无>",
+		"cache.redis.password: ****** -> <空>",
+		"security.admin_token: ****** -> ******",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if changes := Diff(old, old); len(changes) != 0 {
+		t.Fatalf("相同配置的 Diff() = %v", changes)
+	}
+}
+
+func TestDiffByIndex(t *testing.T) {
+	// 没有名称的条目按下标比较
+	old := Config{Log: LogConfig{Access: AccessLogConfig{Sampling: []AccessLogSamplingConfig{{Prefix: "/a", Rate: 1}}}}}
+	next := Config{Log: LogConfig{Access: AccessLogConfig{Sampling: []AccessLogSamplingConfig{{Prefix: "/a", Rate: 0.5}, {Prefix: "/b", Rate: 1}}}}}
+
+	changes := Diff(old, next)
+	if len(changes) != 2 || changes[0].Key != "log.access.sampling[0].rate" || changes[1].Key != "log.access.sampling[1]" {
+		t.Fatalf("Diff() = %v", changes)
+	}
+}
+
+func TestRequiresRestart(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"app.port", true},
+		{"app.tls.certificates[0].cert_file", true},
+		{"cache.redis.addr", true},
+		{"metrics.enabled", true},
+		{"app.public_url", false},
+		{"app.portal", false},
+		{"sources[npm].token", false},
+		{"cache.ttl.default", false},
+	}
+	for _, tt := range tests {
+		if got := requiresRestart(tt.key); got != tt.want {
+			t.Errorf("requiresRestart(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := writeTestConfig(t, testConfig)
+	if err := LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	rewrite := func(old, new string) {
+		t.Helper()
+		content := strings.Replace(testConfig, old, new, 1)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var applied []Change
+	apply := func(cfg Config, changes []Change) { applied = changes }
+
+	// 没有变化时不调用apply
+	changes, err := Reload(apply)
+	if err != nil || len(changes) != 0 || applied != nil {
+		t.Fatalf("Reload() = %v, %v, applied %v", changes, err, applied)
+	}
+
+	// 可热更新的配置项立即生效
+	rewrite("default: 3600", "default: 600")
+	changes, err = Reload(apply)
+	if err != nil || len(changes) != 1 || changes[0].Key != "cache.ttl.default" || !reflect.DeepEqual(applied, changes) {
+		t.Fatalf("Reload() = %v, %v, applied %v", changes, err, applied)
+	}
+	if got := GetConfig().Cache.TTL.Default; got != 600 {
+		t.Fatalf("cache.ttl.default = %d, want 600", got)
+	}
+
+	// 需要重启的配置项被拒绝，当前配置保持不变
+	applied = nil
+	rewrite("port: 8080", "port: 9090")
+	if _, err := Reload(apply); err == nil || !strings.Contains(err.Error(), "app.port") {
+		t.Fatalf("Reload() error = %v, want 需要重启 app.port", err)
+	}
+	if cfg := GetConfig(); cfg.App.Port != 8080 || cfg.Cache.TTL.Default != 600 || applied != nil {
+		t.Fatalf("拒绝重新加载后配置被修改: port %d, ttl %d", cfg.App.Port, cfg.Cache.TTL.Default)
+	}
+
+	// 校验失败时当前配置保持不变
+	rewrite("default: 3600", "default: -1")
+	if _, err := Reload(apply); err == nil {
+		t.Fatal("无效配置的 Reload() 应返回错误")
+	}
+	if got := GetConfig().Cache.TTL.Default; got != 600 || applied != nil {
+		t.Fatalf("校验失败后 cache.ttl.default = %d", got)
+	}
+}
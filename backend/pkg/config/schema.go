@@ -0,0 +1,171 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CheckFile 严格检查配置文件：未知或重复的配置项、类型错误、取值范围、必填项和引用关系
+// 检查时会应用环境变量覆盖，返回的*ValidationError中每个问题都尽量带有行号
+func CheckFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return Config{}, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+
+	// 结构有问题时取值不可信，先报告结构问题
+	s := &schemaChecker{lines: make(map[string]int)}
+	if len(root.Content) > 0 {
+		s.check("", root.Content[0], reflect.TypeOf(Config{}))
+	}
+	if len(s.problems) > 0 {
+		return Config{}, &ValidationError{Problems: s.problems}
+	}
+
+	cfg, overrides, err := readConfig(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	// 来自环境变量的取值没有行号，在问题中注明环境变量名
+	var validationErr *ValidationError
+	if err := Validate(cfg); errors.As(err, &validationErr) {
+		for i := range validationErr.Problems {
+			problem := &validationErr.Problems[i]
+			if name, ok := envSource(overrides, problem.Key); ok {
+				problem.Message += fmt.Sprintf("（来自环境变量 %s）", name)
+				continue
+			}
+			problem.Line = s.line(problem.Key)
+		}
+		return Config{}, validationErr
+	}
+	return cfg, nil
+}
+
+// schemaChecker 按配置结构体检查YAML节点，并记录每个配置项所在的行
+type schemaChecker struct {
+	problems []Problem
+	lines    map[string]int
+}
+
+// line 返回配置项所在的行，配置项不存在时返回最近的上级配置项所在的行
+func (s *schemaChecker) line(key string) int {
+	for key != "" {
+		if line, ok := s.lines[key]; ok {
+			return line
+		}
+		i := strings.LastIndexAny(key, ".[")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return 0
+}
+
+// add 记录一个结构问题
+func (s *schemaChecker) add(key string, line int, format string, args ...interface{}) {
+	s.problems = append(s.problems, Problem{Key: key, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// check 递归检查节点是否符合类型t
+func (s *schemaChecker) check(key string, node *yaml.Node, t reflect.Type) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	// 空值按零值处理
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			s.add(key, node.Line, "应为对象")
+			return
+		}
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name, value := node.Content[i], node.Content[i+1]
+			childKey := joinKey(key, name.Value)
+			if seen[name.Value] {
+				s.add(childKey, name.Line, "重复的配置项")
+				continue
+			}
+			seen[name.Value] = true
+
+			field, ok := fieldByTag(t, name.Value)
+			if !ok {
+				s.add(childKey, name.Line, "未知的配置项")
+				continue
+			}
+			s.lines[childKey] = name.Line
+			s.check(childKey, value, field.Type)
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			s.add(key, node.Line, "应为对象")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name, value := node.Content[i], node.Content[i+1]
+			childKey := joinKey(key, name.Value)
+			s.lines[childKey] = name.Line
+			s.check(childKey, value, t.Elem())
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			s.add(key, node.Line, "应为列表")
+			return
+		}
+		for i, item := range node.Content {
+			childKey := fmt.Sprintf("%s[%d]", key, i)
+			s.lines[childKey] = item.Line
+			s.check(childKey, item, t.Elem())
+		}
+
+	default:
+		if node.Kind != yaml.ScalarNode {
+			s.add(key, node.Line, "应为单个值")
+			return
+		}
+		switch t.Kind() {
+		case reflect.Bool:
+			if node.Tag != "!!bool" {
+				s.add(key, node.Line, "应为 true 或 false: %s", node.Value)
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if node.Tag != "!!int" {
+				s.add(key, node.Line, "应为整数: %s", node.Value)
+			}
+		case reflect.Float32, reflect.Float64:
+			if node.Tag != "!!int" && node.Tag != "!!float" {
+				s.add(key, node.Line, "应为数字: %s", node.Value)
+			}
+		}
+	}
+}
+
+// fieldByTag 按yaml标签查找结构体字段
+func fieldByTag(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if strings.Split(field.Tag.Get("yaml"), ",")[0] == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
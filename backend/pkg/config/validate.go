@@ -0,0 +1,318 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// sourceTypes 支持的源站类型，空值等同于cdn
+var sourceTypes = map[string]bool{
+	"":                true,
+	SourceTypeCDN:     true,
+	SourceTypeGitHub:  true,
+	SourceTypeNPM:     true,
+	SourceTypeGoProxy: true,
+	SourceTypePyPI:    true,
+	SourceTypeMaven:   true,
+	SourceTypeHelm:    true,
+	SourceTypeFonts:   true,
+}
+
+// Problem 配置中的一个问题，Line为配置文件中的行号，未知时为0
+type Problem struct {
+	Key     string
+	Line    int
+	Message string
+}
+
+// String 返回带行号的问题描述
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("第%d行 %s: %s", p.Line, p.Key, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Key, p.Message)
+}
+
+// ValidationError 配置校验失败，包含发现的所有问题
+type ValidationError struct {
+	Problems []Problem
+}
+
+// Error 每个问题占一行
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		lines = append(lines, "  "+problem.String())
+	}
+	return fmt.Sprintf("配置校验失败，共%d个问题:\n%s", len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// validator 收集校验问题
+type validator struct {
+	problems []Problem
+}
+
+// add 记录一个问题
+func (v *validator) add(key string, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// check 条件不成立时记录问题
+func (v *validator) check(ok bool, key string, format string, args ...interface{}) {
+	if !ok {
+		v.add(key, format, args...)
+	}
+}
+
+// oneOf 取值必须是给定值之一
+func (v *validator) oneOf(value string, key string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(key, "不支持的取值 %q，可选值: %s", value, strings.Join(allowed, ", "))
+}
+
+// Validate 校验配置的取值范围、必填项和引用关系，返回*ValidationError
+func Validate(cfg Config) error {
+	v := &validator{}
+	validateApp(v, cfg.App)
+	validateSources(v, cfg.Sources)
+	validateCache(v, cfg.Cache)
+	validateStats(v, cfg.Stats)
+	validateSecurity(v, cfg.Security)
+	validateProxy(v, cfg.Proxy)
+	validateWarmup(v, cfg.Warmup)
+	validateLog(v, cfg.Log)
+	validateMetrics(v, cfg.Metrics, cfg.App)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func validateApp(v *validator, app AppConfig) {
+	v.check(app.Port > 0 && app.Port <= 65535, "app.port", "必须在1-65535之间: %d", app.Port)
+	v.check(app.ShutdownTimeout >= 0, "app.shutdown_timeout", "不能为负数: %d", app.ShutdownTimeout)
+	if app.PublicURL != "" {
+		u, err := url.Parse(app.PublicURL)
+		v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && strings.Trim(u.Path, "/") == "" && u.RawQuery == "",
+			"app.public_url", "必须是不带路径的HTTP(S)地址: %s", app.PublicURL)
+	}
+	for i, proxy := range app.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		v.check(cidrErr == nil || net.ParseIP(proxy) != nil, fmt.Sprintf("app.trusted_proxies[%d]", i), "不是有效的IP或CIDR: %s", proxy)
+	}
+
+	tls := app.TLS
+	if !tls.Enabled {
+		return
+	}
+	v.check(len(tls.Certificates) > 0, "app.tls.certificates", "启用TLS时至少需要配置一个证书")
+	for i, cert := range tls.Certificates {
+		key := fmt.Sprintf("app.tls.certificates[%d]", i)
+		v.check(cert.CertFile != "", key+".cert_file", "不能为空")
+		v.check(cert.KeyFile != "", key+".key_file", "不能为空")
+	}
+	v.oneOf(tls.MinVersion, "app.tls.min_version", "", "1.2", "1.3")
+	v.check(tls.ReloadInterval >= 0, "app.tls.reload_interval", "不能为负数: %d", tls.ReloadInterval)
+	v.check(tls.RedirectPort >= 0 && tls.RedirectPort <= 65535, "app.tls.redirect_port", "必须在0-65535之间: %d", tls.RedirectPort)
+	v.check(tls.RedirectPort != app.Port, "app.tls.redirect_port", "不能与 app.port 相同: %d", tls.RedirectPort)
+}
+
+func validateSources(v *validator, sources []SourceConfig) {
+	names := make(map[string]int)
+	domains := make(map[string]int)
+	prefixes := make(map[string]int)
+
+	for i, source := range sources {
+		key := fmt.Sprintf("sources[%d]", i)
+
+		if source.Name == "" {
+			v.add(key+".name", "不能为空")
+		} else if j, exists := names[source.Name]; exists {
+			v.add(key+".name", "与 sources[%d] 重复: %s", j, source.Name)
+		} else {
+			names[source.Name] = i
+		}
+
+		domain := strings.ToLower(source.Domain)
+		switch {
+		case domain == "":
+			v.add(key+".domain", "不能为空")
+		case strings.ContainsAny(domain, "/:?#@ "):
+			v.add(key+".domain", "只能是域名，不能包含协议、端口或路径: %s", source.Domain)
+		default:
+			if j, exists := domains[domain]; exists {
+				v.add(key+".domain", "与 sources[%d] 重复: %s", j, source.Domain)
+			} else {
+				domains[domain] = i
+			}
+		}
+
+		v.check(sourceTypes[source.Type], key+".type", "不支持的源站类型: %s", source.Type)
+		v.oneOf(source.Redirect, key+".redirect", "", RedirectFollow, RedirectRewrite)
+		v.check(source.MetadataTTL >= 0, key+".metadata_ttl", "不能为负数: %d", source.MetadataTTL)
+		v.check(source.SumDB == "" || source.Type == SourceTypeGoProxy, key+".sumdb", "只有goproxy源站可以配置")
+
+		if source.Prefix != "" && source.Enabled {
+			prefix := "/" + strings.Trim(source.Prefix, "/")
+			if prefix == "/" {
+				v.add(key+".prefix", "不能为根路径，默认源站已经使用根路径")
+			} else if j, exists := prefixes[prefix]; exists {
+				v.add(key+".prefix", "与 sources[%d] 重复: %s", j, source.Prefix)
+			} else {
+				prefixes[prefix] = i
+			}
+		}
+	}
+}
+
+func validateCache(v *validator, cache CacheConfig) {
+	if cache.Enabled {
+		v.oneOf(cache.Type, "cache.type", "memory", "redis")
+		switch cache.Type {
+		case "redis":
+			validateRedis(v, "cache.redis", cache.Redis)
+		case "memory":
+			v.check(cache.Memory.Size > 0, "cache.memory.size", "缓存类型为memory时必须大于0: %d", cache.Memory.Size)
+		}
+	}
+
+	v.check(cache.TTL.Default > 0, "cache.ttl.default", "必须大于0: %d", cache.TTL.Default)
+	v.check(cache.TTL.Max >= 0, "cache.ttl.max", "不能为负数: %d", cache.TTL.Max)
+	v.check(cache.TTL.Max == 0 || cache.TTL.Max >= cache.TTL.Default, "cache.ttl.max", "不能小于 cache.ttl.default: %d < %d", cache.TTL.Max, cache.TTL.Default)
+
+	strategy := cache.Strategy
+	v.check(strategy.LargeFileThreshold >= 0, "cache.strategy.large_file_threshold", "不能为负数: %d", strategy.LargeFileThreshold)
+	v.check(strategy.LargeFileTTL >= 0, "cache.strategy.large_file_ttl", "不能为负数: %d", strategy.LargeFileTTL)
+	v.check(strategy.NormalFileTTL >= 0, "cache.strategy.normal_file_ttl", "不能为负数: %d", strategy.NormalFileTTL)
+	v.check(strategy.SmallFileTTL >= 0, "cache.strategy.small_file_ttl", "不能为负数: %d", strategy.SmallFileTTL)
+	for fileType, ttl := range strategy.FileTypes {
+		v.check(ttl >= 0, "cache.strategy.file_types."+fileType, "不能为负数: %d", ttl)
+	}
+
+	if cache.Purge.Enabled {
+		v.check(cache.Purge.RateLimitMinutes >= 0, "cache.purge.rate_limit_minutes", "不能为负数: %d", cache.Purge.RateLimitMinutes)
+		v.check(cache.Purge.MaxPurgeCount > 0, "cache.purge.max_purge_count", "启用缓存刷新时必须大于0: %d", cache.Purge.MaxPurgeCount)
+	}
+	v.check(cache.Stale.MaxStale >= 0, "cache.stale.max_stale", "不能为负数: %d", cache.Stale.MaxStale)
+	v.check(cache.MaxObjectSize >= 0, "cache.max_object_size", "不能为负数: %d", cache.MaxObjectSize)
+}
+
+func validateStats(v *validator, stats StatsConfig) {
+	if !stats.Enabled {
+		return
+	}
+	v.oneOf(stats.Type, "stats.type", "sqlite", "redis")
+	switch stats.Type {
+	case "sqlite":
+		v.check(stats.SQLite.Path != "", "stats.sqlite.path", "统计类型为sqlite时不能为空")
+	case "redis":
+		validateRedis(v, "stats.redis", stats.Redis)
+	}
+}
+
+// validateRedis Redis地址必须配置为 host:port
+func validateRedis(v *validator, key string, redis RedisConfig) {
+	if redis.Addr == "" {
+		v.add(key+".addr", "使用redis时必须配置Redis地址")
+	} else if !strings.Contains(redis.Addr, ":") {
+		v.add(key+".addr", "格式应为 host:port: %s", redis.Addr)
+	}
+	v.check(redis.DB >= 0 && redis.DB <= 15, key+".db", "必须在0-15之间: %d", redis.DB)
+}
+
+func validateSecurity(v *validator, security SecurityConfig) {
+	if security.RateLimit.Enabled {
+		v.check(security.RateLimit.RequestsPerMinute > 0, "security.rate_limit.requests_per_minute", "启用速率限制时必须大于0: %d", security.RateLimit.RequestsPerMinute)
+	}
+	for i, pattern := range security.BlockedURLs {
+		v.check(strings.TrimSpace(pattern) != "", fmt.Sprintf("security.blocked_urls[%d]", i), "不能为空，空规则会封禁所有URL")
+	}
+}
+
+func validateProxy(v *validator, proxy ProxyConfig) {
+	v.check(proxy.ConnectTimeout >= 0, "proxy.connect_timeout", "不能为负数: %d", proxy.ConnectTimeout)
+	v.check(proxy.ResponseHeaderTimeout >= 0, "proxy.response_header_timeout", "不能为负数: %d", proxy.ResponseHeaderTimeout)
+	v.check(proxy.ReadTimeout >= 0, "proxy.read_timeout", "不能为负数: %d", proxy.ReadTimeout)
+
+	breaker := proxy.CircuitBreaker
+	if !breaker.Enabled {
+		return
+	}
+	key := "proxy.circuit_breaker"
+	v.check(breaker.WindowSeconds > 0, key+".window_seconds", "必须大于0: %d", breaker.WindowSeconds)
+	v.check(breaker.MinRequests > 0, key+".min_requests", "必须大于0: %d", breaker.MinRequests)
+	v.check(breaker.ErrorRate > 0 && breaker.ErrorRate <= 1, key+".error_rate", "必须在0-1之间: %v", breaker.ErrorRate)
+	v.check(breaker.OpenSeconds > 0, key+".open_seconds", "必须大于0: %d", breaker.OpenSeconds)
+}
+
+func validateWarmup(v *validator, warmup WarmupConfig) {
+	if warmup.ListingAPI != "" {
+		u, err := url.Parse(warmup.ListingAPI)
+		v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "warmup.listing_api", "必须是HTTP(S)地址: %s", warmup.ListingAPI)
+	}
+	v.check(warmup.MaxFiles >= 0, "warmup.max_files", "不能为负数: %d", warmup.MaxFiles)
+}
+
+func validateLog(v *validator, log LogConfig) {
+	v.oneOf(log.Level, "log.level", "", "debug", "info", "warn", "error")
+	v.oneOf(log.Format, "log.format", "", "json", "text")
+
+	access := log.Access
+	if !access.Enabled {
+		return
+	}
+	v.check(access.Path != "", "log.access.path", "启用访问日志时不能为空")
+	v.oneOf(access.Format, "log.access.format", "", "json", "combined")
+	v.check(access.MaxSize >= 0, "log.access.max_size", "不能为负数: %d", access.MaxSize)
+	v.oneOf(access.RotateInterval, "log.access.rotate_interval", "", "hourly", "daily")
+	v.check(access.MaxFiles >= 0, "log.access.max_files", "不能为负数: %d", access.MaxFiles)
+	prefixes := make(map[string]int)
+	for i, rule := range access.Sampling {
+		key := fmt.Sprintf("log.access.sampling[%d]", i)
+		if !strings.HasPrefix(rule.Prefix, "/") {
+			v.add(key+".prefix", "必须以/开头: %q", rule.Prefix)
+		} else if j, exists := prefixes[rule.Prefix]; exists {
+			v.add(key+".prefix", "与 log.access.sampling[%d] 重复: %s", j, rule.Prefix)
+		} else {
+			prefixes[rule.Prefix] = i
+		}
+		v.check(rule.Rate >= 0 && rule.Rate <= 1, key+".rate", "必须在0-1之间: %v", rule.Rate)
+	}
+}
+
+// reservedPaths 服务自身使用的路径，在服务端口上提供指标时不能占用
+var reservedPaths = []string{"/api", "/health", "/purge", "/mirror", "/assets"}
+
+func validateMetrics(v *validator, metrics MetricsConfig, app AppConfig) {
+	if !metrics.Enabled {
+		return
+	}
+	if metrics.Path != "" && !strings.HasPrefix(metrics.Path, "/") {
+		v.add("metrics.path", "必须以/开头: %q", metrics.Path)
+	}
+
+	if metrics.Listen == "" {
+		for _, reserved := range reservedPaths {
+			if metrics.Path == reserved || strings.HasPrefix(metrics.Path, reserved+"/") {
+				v.add("metrics.path", "与服务自身的路径冲突: %s", metrics.Path)
+			}
+		}
+		return
+	}
+	_, port, err := net.SplitHostPort(metrics.Listen)
+	if err != nil {
+		v.add("metrics.listen", "格式应为 host:port: %s", metrics.Listen)
+		return
+	}
+	n, err := strconv.Atoi(port)
+	v.check(err == nil && n > 0 && n <= 65535, "metrics.listen", "端口必须在1-65535之间: %s", port)
+	v.check(n != app.Port && (!app.TLS.Enabled || n != app.TLS.RedirectPort), "metrics.listen", "端口不能与服务端口相同: %s", port)
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// validConfig 通过校验的最小配置
+func validConfig() Config {
+	return Config{
+		App: AppConfig{Port: 8080},
+		Sources: []SourceConfig{
+			{Name: "npm", Domain: "registry.npmjs.org", Type: SourceTypeNPM, Prefix: "/npm", Enabled: true},
+			{Name: "jsdelivr", Domain: "cdn.jsdelivr.net", Prefix: "/jsdelivr", Enabled: true},
+		},
+		Cache: CacheConfig{TTL: CacheTTLConfig{Default: 3600, Max: 86400}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(validConfig()); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		// 期望的问题，按配置项和信息片段匹配
+		wantKey string
+		wantMsg string
+	}{
+		{"端口超出范围", func(cfg *Config) { cfg.App.Port = 70000 }, "app.port", "1-65535"},
+		{"public_url带路径", func(cfg *Config) { cfg.App.PublicURL = "https://mirror.example.com/cdn" }, "app.public_url", "不带路径"},
+		{"无效的可信代理", func(cfg *Config) { cfg.App.TrustedProxies = []string{"10.0.0.0/33"} }, "app.trusted_proxies[0]", "IP或CIDR"},
+		{"启用TLS没有证书", func(cfg *Config) { cfg.App.TLS.Enabled = true }, "app.tls.certificates", "至少需要配置一个证书"},
+		{"源站名称重复", func(cfg *Config) { cfg.Sources[1].Name = "npm" }, "sources[1].name", "与 sources[0] 重复"},
+		{"源站域名带协议", func(cfg *Config) { cfg.Sources[0].Domain = "https://registry.npmjs.org" }, "sources[0].domain", "只能是域名"},
+		{"源站域名忽略大小写重复", func(cfg *Config) { cfg.Sources[1].Domain = "Registry.NPMJS.org" }, "sources[1].domain", "与 sources[0] 重复"},
+		{"未知的源站类型", func(cfg *Config) { cfg.Sources[0].Type = "rubygems" }, "sources[0].type", "不支持的源站类型"},
+		{"前缀重复", func(cfg *Config) { cfg.Sources[1].Prefix = "npm/" }, "sources[1].prefix", "与 sources[0] 重复"},
+		{"前缀为根路径", func(cfg *Config) { cfg.Sources[0].Prefix = "/" }, "sources[0].prefix", "不能为根路径"},
+		{"sumdb只用于goproxy", func(cfg *Config) { cfg.Sources[0].SumDB = "sum.golang.org" }, "sources[0].sumdb", "goproxy"},
+		{"未知的缓存类型", func(cfg *Config) { cfg.Cache.Enabled, cfg.Cache.Type = true, "disk" }, "cache.type", "不支持的取值"},
+		{"Redis地址没有端口", func(cfg *Config) {
+			cfg.Cache.Enabled, cfg.Cache.Type, cfg.Cache.Redis.Addr = true, "redis", "localhost"
+		}, "cache.redis.addr", "host:port"},
+		{"最大TTL小于默认TTL", func(cfg *Config) { cfg.Cache.TTL.Max = 60 }, "cache.ttl.max", "不能小于"},
+		{"空的封禁规则", func(cfg *Config) { cfg.Security.BlockedURLs = []string{" "} }, "security.blocked_urls[0]", "封禁所有URL"},
+		{"熔断错误率超出范围", func(cfg *Config) {
+			cfg.Proxy.CircuitBreaker = CircuitBreakerConfig{Enabled: true, WindowSeconds: 60, MinRequests: 10, ErrorRate: 1.5, OpenSeconds: 30}
+		}, "proxy.circuit_breaker.error_rate", "0-1"},
+		{"未知的日志级别", func(cfg *Config) { cfg.Log.Level = "trace" }, "log.level", "不支持的取值"},
+		{"采样前缀重复", func(cfg *Config) {
+			cfg.Log.Access = AccessLogConfig{Enabled: true, Path: "access.log", Sampling: []AccessLogSamplingConfig{{Prefix: "/npm", Rate: 1}, {Prefix: "/npm", Rate: 0.5}}}
+		}, "log.access.sampling[1].prefix", "重复"},
+		{"指标路径与服务路径冲突", func(cfg *Config) { cfg.Metrics.Enabled, cfg.Metrics.Path = true, "/api/metrics" }, "metrics.path", "冲突"},
+		{"指标端口与服务端口相同", func(cfg *Config) { cfg.Metrics.Enabled, cfg.Metrics.Listen = true, ":8080" }, "metrics.listen", "不能与服务端口相同"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+
+			var validationErr *ValidationError
+			if err := Validate(cfg); !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() = %v, want *ValidationError", err)
+			}
+			for _, problem := range validationErr.Problems {
+				if problem.Key == tt.wantKey && strings.Contains(problem.Message, tt.wantMsg) {
+					return
+				}
+			}
+			t.Fatalf("Validate() 缺少问题 %s: %s，实际: %v", tt.wantKey, tt.wantMsg, validationErr.Problems)
+		})
+	}
+}
+
+func TestValidateCollectsAllProblems(t *testing.T) {
+	cfg := validConfig()
+	cfg.App.Port = 0
+	cfg.Cache.TTL.Default = 0
+	cfg.Log.Format = "xml"
+
+	var validationErr *ValidationError
+	if err := Validate(cfg); !errors.As(err, &validationErr) || len(validationErr.Problems) != 3 {
+		t.Fatalf("Validate() = %v, want 3个问题", err)
+	}
+	if msg := validationErr.Error(); !strings.HasPrefix(msg, "配置校验失败，共3个问题:\n  app.port:") {
+		t.Fatalf("Error() = %q", msg)
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	tests := []struct {
+		name string
+		// 替换testConfig中的内容
+		old, new string
+		// 期望的问题描述片段，为空时期望通过
+		want string
+	}{
+		{"有效配置", "", "", ""},
+		{"未知的配置项", "  port: 8080", "  port: 8080\n  prot: 8081", "第5行 app.prot: 未知的配置项"},
+		{"重复的配置项", "  port: 8080", "  port: 8080\n  port: 8081", "第5行 app.port: 重复的配置项"},
+		{"类型错误", "  port: 8080", `  port: "8080"`, "第4行 app.port: 应为整数: 8080"},
+		{"应为列表", "  blocked_urls:\n    - \"cdn.example.com/npm/evil\"", "  blocked_urls: cdn.example.com", "第12行 security.blocked_urls: 应为列表"},
+		{"源站中的未知配置项", "    prefix: \"/npm\"", "    prefix: \"/npm\"\n    tokn: \"x\"", "第20行 sources[0].tokn: 未知的配置项"},
+		{"取值校验带行号", "default: 3600", "default: 0", "第9行 cache.ttl.default: 必须大于0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CheckFile(writeTestConfig(t, strings.Replace(testConfig, tt.old, tt.new, 1)))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("CheckFile() = %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("CheckFile() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFileExample(t *testing.T) {
+	// 仓库自带的配置文件必须通过检查
+	if _, err := CheckFile("../../../config/config.yaml"); err != nil {
+		t.Fatalf("config/config.yaml: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 访问日志格式
+const (
+	AccessFormatJSON     = "json"
+	AccessFormatCombined = "combined"
+)
+
+// clfTimeFormat Combined Log Format的时间格式
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLog 访问日志，每个请求写一行，用于排查滥用
+type AccessLog struct {
+	out      io.WriteCloser
+	format   string
+	sampling atomic.Pointer[[]config.AccessLogSamplingConfig]
+}
+
+// accessEntry 一条访问日志
+type accessEntry struct {
+	Time           string `json:"time"`
+	RequestID      string `json:"request_id,omitempty"`
+	ClientIP       string `json:"client_ip"`
+	Method         string `json:"method"`
+	Path           string `json:"path"`
+	Protocol       string `json:"protocol"`
+	Status         int    `json:"status"`
+	Bytes          int    `json:"bytes"`
+	Cache          string `json:"cache,omitempty"`
+	Upstream       string `json:"upstream,omitempty"`
+	UpstreamStatus int64  `json:"upstream_status,omitempty"`
+	DurationMS     int64  `json:"duration_ms"`
+	Referer        string `json:"referer,omitempty"`
+	UserAgent      string `json:"user_agent,omitempty"`
+
+	time time.Time
+}
+
+// NewAccessLog 按配置打开访问日志文件，logger用于记录轮转和压缩中的问题
+func NewAccessLog(cfg config.AccessLogConfig, logger *slog.Logger) (*AccessLog, error) {
+	out, err := NewRotatingFile(cfg.Path, int64(cfg.MaxSize)*1024*1024, cfg.RotateInterval, cfg.MaxFiles, cfg.Compress, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AccessLog{out: out, format: cfg.Format}
+	if a.format == "" {
+		a.format = AccessFormatJSON
+	}
+	a.SetSampling(cfg.Sampling)
+	return a, nil
+}
+
+// SetSampling 更新采样规则，配置热更新时调用
+func (a *AccessLog) SetSampling(rules []config.AccessLogSamplingConfig) {
+	// 按前缀长度从长到短排列，匹配时使用第一条命中的规则
+	sorted := append([]config.AccessLogSamplingConfig(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+	a.sampling.Store(&sorted)
+}
+
+// Middleware 请求结束后写入访问日志，需要放在logging.Middleware之后以取得请求ID、缓存状态和源站
+func (a *AccessLog) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		if !a.sampled(c.Request.URL.Path, status) {
+			return
+		}
+
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		entry := accessEntry{
+			Time:       start.Format(time.RFC3339Nano),
+			ClientIP:   c.ClientIP(),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.RequestURI(),
+			Protocol:   c.Request.Proto,
+			Status:     status,
+			Bytes:      size,
+			DurationMS: time.Since(start).Milliseconds(),
+			Referer:    c.Request.Referer(),
+			UserAgent:  c.Request.UserAgent(),
+			time:       start,
+		}
+		if r := requestOf(c); r != nil {
+			if v, ok := r.fields.get(FieldRequestID); ok {
+				entry.RequestID = v.String()
+			}
+			if v, ok := r.fields.get(FieldCache); ok {
+				entry.Cache = v.String()
+			}
+			if v, ok := r.fields.get(FieldSource); ok {
+				entry.Upstream = v.String()
+			}
+			if v, ok := r.fields.get(FieldUpstreamStatus); ok && v.Kind() == slog.KindInt64 {
+				entry.UpstreamStatus = v.Int64()
+			}
+		}
+
+		if err := a.write(entry); err != nil {
+			Get(c).Warn("写入访问日志失败", "error", err)
+		}
+	}
+}
+
+// Close 关闭访问日志文件
+func (a *AccessLog) Close() error {
+	return a.out.Close()
+}
+
+// sampled 按采样规则决定是否记录，4xx和5xx总是记录
+func (a *AccessLog) sampled(path string, status int) bool {
+	if status >= 400 {
+		return true
+	}
+	for _, rule := range *a.sampling.Load() {
+		if strings.HasPrefix(path, rule.Prefix) {
+			return rule.Rate >= 1 || rand.Float64() < rule.Rate
+		}
+	}
+	return true
+}
+
+// write 按配置的格式写入一行
+func (a *AccessLog) write(entry accessEntry) error {
+	var line []byte
+	if a.format == AccessFormatCombined {
+		line = combinedLine(entry)
+	} else {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		line = append(data, '\n')
+	}
+	_, err := a.out.Write(line)
+	return err
+}
+
+// combinedLine 生成Combined Log Format的一行，末尾以key=value追加缓存状态、源站、回源状态码、耗时和请求ID
+func combinedLine(entry accessEntry) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s - - [%s] \"%s %s %s\" %d %d \"%s\" \"%s\"",
+		entry.ClientIP,
+		entry.time.Format(clfTimeFormat),
+		clfEscape(entry.Method), clfEscape(entry.Path), clfEscape(entry.Protocol),
+		entry.Status,
+		entry.Bytes,
+		clfValue(clfEscape(entry.Referer)),
+		clfValue(clfEscape(entry.UserAgent)),
+	)
+
+	upstreamStatus := "-"
+	if entry.UpstreamStatus != 0 {
+		upstreamStatus = strconv.FormatInt(entry.UpstreamStatus, 10)
+	}
+	fmt.Fprintf(&b, " cache=%s upstream=%s upstream_status=%s duration_ms=%d request_id=%s\n",
+		clfValue(entry.Cache),
+		clfValue(entry.Upstream),
+		upstreamStatus,
+		entry.DurationMS,
+		clfValue(entry.RequestID),
+	)
+	return b.Bytes()
+}
+
+// clfValue 空值记为"-"
+func clfValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// clfEscape 转义双引号、反斜杠和控制字符，避免客户端伪造日志行或字段
+func clfEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch == '"' || ch == '\\' || ch < 0x20 || ch == 0x7f {
+			fmt.Fprintf(&b, "\\x%02X", ch)
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
@@ -0,0 +1,190 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAccessLogSampled(t *testing.T) {
+	a := &AccessLog{}
+	a.SetSampling([]config.AccessLogSamplingConfig{
+		{Prefix: "/npm/", Rate: 0},
+		{Prefix: "/npm/react/", Rate: 1},
+		{Prefix: "/health", Rate: 0},
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		want   bool
+	}{
+		{"没有匹配的规则时全部记录", "/gh/a.js", 200, true},
+		{"采样率为0", "/npm/vue/index.js", 200, false},
+		{"最长前缀优先", "/npm/react/index.js", 200, true},
+		{"健康检查", "/health", 200, false},
+		{"4xx总是记录", "/npm/vue/missing.js", 404, true},
+		{"5xx总是记录", "/health", 503, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.sampled(tt.path, tt.status); got != tt.want {
+				t.Fatalf("sampled(%q, %d) = %v, want %v", tt.path, tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogSampledRate(t *testing.T) {
+	a := &AccessLog{}
+	a.SetSampling([]config.AccessLogSamplingConfig{{Prefix: "/", Rate: 0.1}})
+
+	const n = 10000
+	kept := 0
+	for i := 0; i < n; i++ {
+		if a.sampled("/a.js", 200) {
+			kept++
+		}
+	}
+	if kept < n/20 || kept > n/5 {
+		t.Fatalf("采样率0.1时记录了 %d/%d 条", kept, n)
+	}
+}
+
+// newAccessLogEngine 创建写入临时文件的访问日志和使用它的gin引擎
+func newAccessLogEngine(t *testing.T, cfg config.AccessLogConfig) (*AccessLog, *gin.Engine, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg.Path = filepath.Join(t.TempDir(), "access.log")
+	a, err := NewAccessLog(cfg, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(Middleware(discardLogger))
+	r.Use(a.Middleware())
+	r.GET("/*path", func(c *gin.Context) {
+		if strings.HasSuffix(c.Request.URL.Path, "/missing.js") {
+			c.String(http.StatusNotFound, "not found")
+			return
+		}
+		Set(c, FieldCache, "HIT")
+		Set(c, FieldSource, "cdn.example.com")
+		c.String(http.StatusOK, "console.log(1)")
+	})
+	return a, r, cfg.Path
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	a, r, path := newAccessLogEngine(t, config.AccessLogConfig{
+		Sampling: []config.AccessLogSamplingConfig{{Prefix: "/npm/", Rate: 0}},
+	})
+
+	for _, target := range []string{"/gh/a.js?v=1", "/npm/vue/index.js", "/npm/vue/missing.js"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("User-Agent", "test-agent")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(readLog(t, path)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("访问日志 %d 行, want 2:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+
+	var entries []accessEntry
+	for _, line := range lines {
+		var entry accessEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("解析 %q 失败: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	first := entries[0]
+	if first.Path != "/gh/a.js?v=1" || first.Status != 200 || first.Bytes != len("console.log(1)") ||
+		first.Cache != "HIT" || first.Upstream != "cdn.example.com" || first.UserAgent != "test-agent" || first.RequestID == "" {
+		t.Fatalf("第一条日志 %+v", first)
+	}
+	// 被采样规则排除的路径，错误响应仍然记录
+	if entries[1].Path != "/npm/vue/missing.js" || entries[1].Status != 404 {
+		t.Fatalf("第二条日志 %+v", entries[1])
+	}
+}
+
+func TestAccessLogSetSampling(t *testing.T) {
+	a, r, path := newAccessLogEngine(t, config.AccessLogConfig{})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/npm/a.js", nil))
+	// 热更新后新规则立即生效
+	a.SetSampling([]config.AccessLogSamplingConfig{{Prefix: "/npm/", Rate: 0}})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/npm/b.js", nil))
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content := readLog(t, path)
+	if !strings.Contains(content, "/npm/a.js") || strings.Contains(content, "/npm/b.js") {
+		t.Fatalf("访问日志内容:\n%s", content)
+	}
+}
+
+func TestCombinedLine(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name  string
+		entry accessEntry
+		want  string
+	}{
+		{
+			name: "完整字段",
+			entry: accessEntry{
+				ClientIP: "203.0.113.1", Method: "GET", Path: "/gh/a.js", Protocol: "HTTP/1.1",
+				Status: 200, Bytes: 14, Referer: "https://example.com/", UserAgent: "curl/8.0",
+				Cache: "HIT", Upstream: "cdn.example.com", UpstreamStatus: 200, DurationMS: 3, RequestID: "abc",
+				time: start,
+			},
+			want: `203.0.113.1 - - [02/Jan/2024:03:04:05 +0000] "GET /gh/a.js HTTP/1.1" 200 14 "https://example.com/" "curl/8.0"` +
+				" cache=HIT upstream=cdn.example.com upstream_status=200 duration_ms=3 request_id=abc\n",
+		},
+		{
+			name: "空字段记为-",
+			entry: accessEntry{
+				ClientIP: "203.0.113.1", Method: "GET", Path: "/", Protocol: "HTTP/1.1", Status: 404, time: start,
+			},
+			want: `203.0.113.1 - - [02/Jan/2024:03:04:05 +0000] "GET / HTTP/1.1" 404 0 "-" "-"` +
+				" cache=- upstream=- upstream_status=- duration_ms=0 request_id=-\n",
+		},
+		{
+			name: "转义引号和换行",
+			entry: accessEntry{
+				ClientIP: "203.0.113.1", Method: "GET", Path: "/", Protocol: "HTTP/1.1", Status: 200,
+				UserAgent: "evil\" \n1.2.3.4 - - [fake]", time: start,
+			},
+			want: `203.0.113.1 - - [02/Jan/2024:03:04:05 +0000] "GET / HTTP/1.1" 200 0 "-" "evil\x22 \x0A1.2.3.4 - - [fake]"` +
+				" cache=- upstream=- upstream_status=- duration_ms=0 request_id=-\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(combinedLine(tt.entry)); got != tt.want {
+				t.Fatalf("combinedLine() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
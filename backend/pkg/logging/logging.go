@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"static-mirrors/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// level 当前的日志级别，热更新配置时修改
+var level slog.LevelVar
+
+// New 根据日志配置创建输出到w的日志记录器，format为json时输出JSON，否则输出key=value文本
+func New(cfg config.LogConfig, w io.Writer) *slog.Logger {
+	level.Set(parseLevel(cfg.Level))
+	options := &slog.HandlerOptions{Level: &level}
+	if cfg.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// Setup 创建输出到标准错误的日志记录器并设为默认
+// 仍在使用标准库log的代码和gin的错误输出也会写入该记录器
+func Setup(cfg config.LogConfig) *slog.Logger {
+	logger := New(cfg, os.Stderr)
+	slog.SetDefault(logger)
+	gin.DefaultErrorWriter = slog.NewLogLogger(logger.Handler(), slog.LevelError).Writer()
+	return logger
+}
+
+// SetLevel 修改日志级别，配置热更新时调用
+func SetLevel(name string) {
+	level.Set(parseLevel(name))
+}
+
+// parseLevel 解析日志级别，默认为info
+func parseLevel(name string) slog.Level {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// StdLogger 返回写入logger的标准库log记录器，供只接受*log.Logger的组件使用
+func StdLogger(logger *slog.Logger, level slog.Level) *log.Logger {
+	return slog.NewLogLogger(logger.Handler(), level)
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"static-mirrors/pkg/config"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(config.LogConfig{Level: "warn", Format: "json"}, &buf)
+
+	logger.Info("忽略")
+	logger.Warn("记录", "key", "value")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("warn级别输出了 %d 条日志: %s", len(lines), buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("日志不是JSON: %v", err)
+	}
+	if entry["msg"] != "记录" || entry["level"] != "WARN" || entry["key"] != "value" {
+		t.Fatalf("日志 = %v", entry)
+	}
+
+	// 热更新日志级别对已创建的记录器生效
+	SetLevel("debug")
+	defer SetLevel("info")
+	buf.Reset()
+	logger.Debug("调试")
+	if !strings.Contains(buf.String(), "调试") {
+		t.Fatal("SetLevel(debug) 之后没有输出debug日志")
+	}
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(config.LogConfig{}, &buf)
+	logger.Debug("忽略")
+	logger.Info("记录", "key", "value")
+
+	if got := buf.String(); !strings.HasPrefix(got, "time=") || !strings.Contains(got, "level=INFO msg=记录 key=value") || strings.Contains(got, "忽略") {
+		t.Fatalf("文本日志 = %q", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"DEBUG": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+		"":      slog.LevelInfo,
+		"trace": slog.LevelInfo,
+	}
+	for name, want := range tests {
+		if got := parseLevel(name); got != want {
+			t.Errorf("parseLevel(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(config.LogConfig{Format: "json"}, &buf)
+	StdLogger(logger, slog.LevelError).Print("标准库日志")
+
+	if got := buf.String(); !strings.Contains(got, `"level":"ERROR"`) || !strings.Contains(got, `"msg":"标准库日志"`) {
+		t.Fatalf("日志 = %q", got)
+	}
+}
@@ -0,0 +1,223 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求范围的日志字段
+const (
+	FieldRequestID      = "request_id"
+	FieldSource         = "source"
+	FieldCache          = "cache"
+	FieldUpstreamStatus = "upstream_status"
+)
+
+// RequestIDHeader 请求ID的请求头和响应头，客户端或前置代理传入合法的请求ID时沿用
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 沿用的请求ID最大长度
+const maxRequestIDLength = 64
+
+// requestKey 请求日志状态在gin上下文中的键
+const requestKey = "logging.request"
+
+// contextKey 请求日志状态在请求context中的键
+type contextKey struct{}
+
+// request 单个请求的基础日志记录器和字段
+type request struct {
+	base   *slog.Logger
+	fields *fields
+}
+
+// fields 请求范围的日志字段，处理过程中可以更新，之后的每条日志都带上最新的取值
+type fields struct {
+	mu     sync.Mutex
+	keys   []string
+	values map[string]slog.Value
+}
+
+// set 设置字段，已存在的字段保持原来的顺序
+func (f *fields) set(key string, value any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.values[key]; !exists {
+		f.keys = append(f.keys, key)
+	}
+	f.values[key] = slog.AnyValue(value)
+}
+
+// get 返回字段的当前取值
+func (f *fields) get(key string) (slog.Value, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.values[key]
+	return value, ok
+}
+
+// attrs 返回当前的字段
+func (f *fields) attrs() []slog.Attr {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	attrs := make([]slog.Attr, 0, len(f.keys))
+	for _, key := range f.keys {
+		attrs = append(attrs, slog.Attr{Key: key, Value: f.values[key]})
+	}
+	return attrs
+}
+
+// requestHandler 在每条日志中加入请求字段的当前取值
+type requestHandler struct {
+	slog.Handler
+	fields *fields
+}
+
+// Handle 加入请求字段后交给下一级处理
+func (h requestHandler) Handle(ctx context.Context, r slog.Record) error {
+	r = r.Clone()
+	r.AddAttrs(h.fields.attrs()...)
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs 保留请求字段
+func (h requestHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestHandler{Handler: h.Handler.WithAttrs(attrs), fields: h.fields}
+}
+
+// WithGroup 保留请求字段
+func (h requestHandler) WithGroup(name string) slog.Handler {
+	return requestHandler{Handler: h.Handler.WithGroup(name), fields: h.fields}
+}
+
+// Attach 为请求创建带请求ID的日志记录器，保存到gin上下文和请求context中，返回请求ID
+// requestID为空时生成新的请求ID
+func Attach(c *gin.Context, base *slog.Logger, requestID string) string {
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	f := &fields{values: make(map[string]slog.Value)}
+	f.set(FieldRequestID, requestID)
+
+	r := &request{base: base, fields: f}
+	c.Set(requestKey, r)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, r))
+	return requestID
+}
+
+// Middleware 为每个请求分配请求ID，请求结束后记录状态码、耗时和请求字段
+func Middleware(base *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := Attach(c, base, validRequestID(c.GetHeader(RequestIDHeader)))
+		c.Header(RequestIDHeader, requestID)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelWarn
+		}
+		Get(c).LogAttrs(c.Request.Context(), level, "请求完成",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", size),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// Get 返回请求的日志记录器，请求没有经过Middleware或Attach时返回默认记录器
+func Get(c *gin.Context) *slog.Logger {
+	if r := requestOf(c); r != nil {
+		return r.logger(r.base)
+	}
+	return slog.Default()
+}
+
+// For 返回带有请求字段的logger，各组件用它记录与请求相关的日志，请求没有日志状态时直接返回logger
+func For(c *gin.Context, logger *slog.Logger) *slog.Logger {
+	if r := requestOf(c); r != nil {
+		return r.logger(logger)
+	}
+	return logger
+}
+
+// ForContext 与For相同，用于只有请求context的代码
+func ForContext(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	if r, ok := ctx.Value(contextKey{}).(*request); ok {
+		return r.logger(logger)
+	}
+	return logger
+}
+
+// logger 返回在logger基础上加入请求字段的记录器
+func (r *request) logger(logger *slog.Logger) *slog.Logger {
+	return slog.New(requestHandler{Handler: logger.Handler(), fields: r.fields})
+}
+
+// requestOf 返回gin上下文中的请求日志状态
+func requestOf(c *gin.Context) *request {
+	if v, exists := c.Get(requestKey); exists {
+		return v.(*request)
+	}
+	return nil
+}
+
+// Set 设置请求字段，如源站、缓存状态和回源状态码，之后该请求的每条日志都会带上
+func Set(c *gin.Context, key string, value any) {
+	if r := requestOf(c); r != nil {
+		r.fields.set(key, value)
+	}
+}
+
+// RequestID 返回请求ID，没有时返回空字符串
+func RequestID(c *gin.Context) string {
+	return Field(c, FieldRequestID)
+}
+
+// Field 返回请求字段的当前取值，没有设置时返回空字符串
+func Field(c *gin.Context, key string) string {
+	if r := requestOf(c); r != nil {
+		if value, ok := r.fields.get(key); ok {
+			return value.String()
+		}
+	}
+	return ""
+}
+
+// validRequestID 只沿用由字母、数字和 -_.: 组成的请求ID，避免日志注入
+func validRequestID(id string) string {
+	if id == "" || len(id) > maxRequestIDLength {
+		return ""
+	}
+	for _, ch := range id {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-' || ch == '_' || ch == '.' || ch == ':':
+		default:
+			return ""
+		}
+	}
+	return id
+}
+
+// newRequestID 生成16位十六进制的随机请求ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
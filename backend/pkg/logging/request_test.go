@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// decodeLines 解析每行一条的JSON日志
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("日志不是JSON: %q", line)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	base := slog.New(slog.NewJSONHandler(&buf, nil))
+	component := slog.New(slog.NewJSONHandler(&buf, nil)).With("component", "proxy")
+
+	r := gin.New()
+	r.Use(Middleware(base))
+	r.GET("/npm/*path", func(c *gin.Context) {
+		// 字段设置之前的记录器在之后的日志中也带上最新的取值
+		logger := For(c, component)
+		Set(c, FieldSource, "npm")
+		Set(c, FieldCache, "MISS")
+		logger.Info("回源")
+		Set(c, FieldCache, "HIT")
+		ForContext(c.Request.Context(), component).Info("读取缓存")
+		c.String(http.StatusBadGateway, "error")
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		// 为空时期望生成新的请求ID
+		want string
+	}{
+		{"沿用合法的请求ID", "abc-123_x.y:z", "abc-123_x.y:z"},
+		{"拒绝包含非法字符的请求ID", "abc\n123", ""},
+		{"拒绝过长的请求ID", strings.Repeat("a", maxRequestIDLength+1), ""},
+		{"没有请求ID", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/npm/react", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			requestID := w.Header().Get(RequestIDHeader)
+			switch {
+			case tt.want != "" && requestID != tt.want:
+				t.Fatalf("%s = %q, want %q", RequestIDHeader, requestID, tt.want)
+			case tt.want == "" && (len(requestID) != 16 || requestID == tt.header):
+				t.Fatalf("%s = %q, want 新生成的请求ID", RequestIDHeader, requestID)
+			}
+
+			entries := decodeLines(t, &buf)
+			if len(entries) != 3 {
+				t.Fatalf("输出了 %d 条日志", len(entries))
+			}
+			for _, entry := range entries {
+				if entry[FieldRequestID] != requestID || entry[FieldSource] != "npm" {
+					t.Fatalf("日志缺少请求字段: %v", entry)
+				}
+			}
+			if entries[0]["component"] != "proxy" || entries[0][FieldCache] != "MISS" || entries[1][FieldCache] != "HIT" {
+				t.Fatalf("组件日志 = %v", entries[:2])
+			}
+
+			// 请求完成日志，5xx使用warn级别
+			done := entries[2]
+			if done["msg"] != "请求完成" || done["level"] != "WARN" || done["status"] != float64(502) || done["path"] != "/npm/react" || done[FieldCache] != "HIT" {
+				t.Fatalf("请求完成日志 = %v", done)
+			}
+		})
+	}
+}
+
+func TestWithoutRequest(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// 没有请求日志状态时原样返回记录器，设置字段不会出错
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	if For(c, logger) != logger || ForContext(c.Request.Context(), logger) != logger {
+		t.Fatal("没有请求日志状态时应返回原记录器")
+	}
+	Set(c, FieldSource, "npm")
+	if RequestID(c) != "" || Field(c, FieldSource) != "" {
+		t.Fatal("没有请求日志状态时字段应为空")
+	}
+	if Get(c) != slog.Default() {
+		t.Fatal("没有请求日志状态时 Get 应返回默认记录器")
+	}
+}
+
+func TestAttach(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	var buf bytes.Buffer
+	if id := Attach(c, slog.New(slog.NewJSONHandler(&buf, nil)), "warmup-1"); id != "warmup-1" || RequestID(c) != "warmup-1" {
+		t.Fatalf("Attach() = %q, RequestID() = %q", id, RequestID(c))
+	}
+	Set(c, FieldUpstreamStatus, 404)
+	if got := Field(c, FieldUpstreamStatus); got != "404" {
+		t.Fatalf("Field(upstream_status) = %q", got)
+	}
+
+	// 分组和附加属性之后仍带有请求字段
+	Get(c).WithGroup("g").With("a", 1).Info("msg")
+	if got := buf.String(); !strings.Contains(got, `"request_id":"warmup-1"`) || !strings.Contains(got, `"upstream_status":404`) {
+		t.Fatalf("日志 = %q", got)
+	}
+}
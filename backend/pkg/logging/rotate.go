@@ -0,0 +1,294 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 按时间轮转的周期
+const (
+	RotateHourly = "hourly"
+	RotateDaily  = "daily"
+)
+
+// rotatedTimeFormat 轮转文件名中的时间格式，如 access-20240101T150405.log
+const rotatedTimeFormat = "20060102T150405"
+
+// RotatingFile 按大小或时间轮转的日志文件
+// 轮转时当前文件改名为带时间的文件名，之后在后台压缩并删除超出保留数量的旧文件
+type RotatingFile struct {
+	path     string
+	maxSize  int64
+	interval string
+	maxFiles int
+	compress bool
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time
+	closed bool
+
+	// cleanup 串行执行压缩和清理，cleanupDone 在关闭时等待后台任务结束
+	cleanup     chan struct{}
+	cleanupDone chan struct{}
+}
+
+// NewRotatingFile 打开日志文件，目录不存在时创建
+// maxSize为轮转大小（字节，0表示不按大小轮转），interval为hourly、daily或空，maxFiles为保留的轮转文件数（0表示全部保留）
+func NewRotatingFile(path string, maxSize int64, interval string, maxFiles int, compress bool, logger *slog.Logger) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %w", err)
+	}
+
+	f := &RotatingFile{
+		path:        path,
+		maxSize:     maxSize,
+		interval:    interval,
+		maxFiles:    maxFiles,
+		compress:    compress,
+		logger:      logger,
+		cleanup:     make(chan struct{}, 1),
+		cleanupDone: make(chan struct{}),
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	go f.cleanupLoop()
+
+	// 上次运行留下的未压缩或超出数量的文件也一并处理
+	f.requestCleanup()
+	return f, nil
+}
+
+// Write 写入一条日志，写入前按需轮转，p应为完整的一行
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	// 上次轮转后重新打开失败时再次尝试
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.shouldRotate(len(p), time.Now()) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close 关闭日志文件并等待后台压缩完成
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.closed = true
+	close(f.cleanup)
+	f.mu.Unlock()
+
+	<-f.cleanupDone
+	return err
+}
+
+// open 打开或创建日志文件，已有内容时按文件的修改时间确定所属的轮转周期
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.period = f.periodOf(time.Now())
+	if f.size > 0 {
+		f.period = f.periodOf(info.ModTime())
+	}
+	return nil
+}
+
+// shouldRotate 写入n字节前是否需要轮转，空文件不按大小轮转，避免单条超长日志反复轮转
+func (f *RotatingFile) shouldRotate(n int, now time.Time) bool {
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(n) > f.maxSize {
+		return true
+	}
+	return f.interval != "" && f.size > 0 && !f.periodOf(now).Equal(f.period)
+}
+
+// periodOf 返回t所属轮转周期的开始时间
+func (f *RotatingFile) periodOf(t time.Time) time.Time {
+	switch f.interval {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	default:
+		return time.Time{}
+	}
+}
+
+// rotate 把当前文件改名为带时间的文件名后重新打开
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		f.logger.Warn("关闭日志文件失败", "path", f.path, "error", err)
+	}
+	f.file = nil
+
+	if err := os.Rename(f.path, f.rotatedName(time.Now())); err != nil {
+		f.logger.Warn("轮转日志文件失败，继续写入原文件", "path", f.path, "error", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.requestCleanup()
+	return nil
+}
+
+// rotatedName 返回未被占用的轮转文件名
+func (f *RotatingFile) rotatedName(now time.Time) string {
+	dir, base, ext := f.nameParts()
+	name := filepath.Join(dir, base+"-"+now.Format(rotatedTimeFormat)+ext)
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = filepath.Join(dir, fmt.Sprintf("%s-%s.%d%s", base, now.Format(rotatedTimeFormat), i, ext))
+	}
+	return name
+}
+
+// nameParts 拆分日志文件路径为目录、文件名和扩展名
+func (f *RotatingFile) nameParts() (string, string, string) {
+	dir := filepath.Dir(f.path)
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(filepath.Base(f.path), ext)
+	return dir, base, ext
+}
+
+// requestCleanup 通知后台执行压缩和清理，已有待执行的任务时合并
+func (f *RotatingFile) requestCleanup() {
+	select {
+	case f.cleanup <- struct{}{}:
+	default:
+	}
+}
+
+// cleanupLoop 后台压缩轮转文件并删除超出保留数量的旧文件
+func (f *RotatingFile) cleanupLoop() {
+	defer close(f.cleanupDone)
+	for range f.cleanup {
+		rotated := f.rotatedFiles()
+		if f.compress {
+			for i, name := range rotated {
+				if strings.HasSuffix(name, ".gz") {
+					continue
+				}
+				if err := compressFile(name); err != nil {
+					f.logger.Warn("压缩日志文件失败", "path", name, "error", err)
+					continue
+				}
+				rotated[i] = name + ".gz"
+			}
+		}
+		if f.maxFiles > 0 && len(rotated) > f.maxFiles {
+			for _, name := range rotated[:len(rotated)-f.maxFiles] {
+				if err := os.Remove(name); err != nil {
+					f.logger.Warn("删除旧日志文件失败", "path", name, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// rotatedFiles 返回已轮转的文件，按轮转时间从旧到新排列
+func (f *RotatingFile) rotatedFiles() []string {
+	dir, base, ext := f.nameParts()
+	matches, err := filepath.Glob(filepath.Join(dir, base+"-*"+ext+"*"))
+	if err != nil {
+		return nil
+	}
+
+	var rotated []string
+	for _, name := range matches {
+		stamp := strings.TrimPrefix(filepath.Base(name), base+"-")
+		if strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		if _, err := time.Parse(rotatedTimeFormat, stamp[:min(len(stamp), len(rotatedTimeFormat))]); err != nil {
+			continue
+		}
+		rotated = append(rotated, name)
+	}
+
+	// 去掉扩展名后按字典序即按时间排序，同一秒内轮转的文件带有递增的序号
+	sortKey := func(name string) string {
+		return strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+	}
+	sort.Slice(rotated, func(i, j int) bool {
+		return sortKey(rotated[i]) < sortKey(rotated[j])
+	})
+	return rotated
+}
+
+// compressFile 把文件压缩为 name.gz 后删除原文件
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := name + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(name)
+}
+
+// fileExists 文件是否存在
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
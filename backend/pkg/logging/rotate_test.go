@@ -0,0 +1,174 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// readLog 读取日志文件内容，.gz文件先解压
+func readLog(t *testing.T, name string) string {
+	t.Helper()
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("解压 %s 失败: %v", name, err)
+		}
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	line := strings.Repeat("x", 39) + "\n"
+	tests := []struct {
+		name     string
+		maxSize  int64
+		maxFiles int
+		compress bool
+		writes   int
+		// 期望的轮转文件数和当前文件的行数
+		rotated int
+		current int
+	}{
+		{"不按大小轮转", 0, 0, false, 5, 0, 5},
+		{"超过大小时轮转", 100, 0, false, 5, 2, 1},
+		{"只保留最新的文件", 100, 1, false, 7, 1, 1},
+		{"压缩轮转文件", 100, 0, true, 5, 2, 1},
+		{"单条日志超过大小时不反复轮转", 10, 0, false, 3, 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "access.log")
+			f, err := NewRotatingFile(path, tt.maxSize, "", tt.maxFiles, tt.compress, discardLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.writes; i++ {
+				if _, err := f.Write([]byte(line)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Close等待后台压缩和清理结束
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			rotated := f.rotatedFiles()
+			if len(rotated) != tt.rotated {
+				t.Fatalf("轮转文件 %v, want %d 个", rotated, tt.rotated)
+			}
+			total := strings.Count(readLog(t, path), "\n")
+			if total != tt.current {
+				t.Fatalf("当前文件 %d 行, want %d", total, tt.current)
+			}
+			for _, name := range rotated {
+				if strings.HasSuffix(name, ".gz") != tt.compress {
+					t.Fatalf("轮转文件 %s, want compress %v", name, tt.compress)
+				}
+				content := readLog(t, name)
+				if content == "" || strings.Trim(content, "x\n") != "" {
+					t.Fatalf("轮转文件 %s 内容 %q", name, content)
+				}
+				total += strings.Count(content, "\n")
+			}
+			if tt.maxFiles == 0 && total != tt.writes {
+				t.Fatalf("共 %d 行, want %d", total, tt.writes)
+			}
+		})
+	}
+}
+
+func TestRotatingFileShouldRotate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		interval string
+		size     int64
+		now      time.Time
+		want     bool
+	}{
+		{"同一小时", RotateHourly, 10, start.Add(20 * time.Minute), false},
+		{"下一小时", RotateHourly, 10, start.Add(30 * time.Minute), true},
+		{"同一天", RotateDaily, 10, start.Add(8 * time.Hour), false},
+		{"下一天", RotateDaily, 10, start.Add(9 * time.Hour), true},
+		{"空文件不轮转", RotateHourly, 0, start.Add(time.Hour), false},
+		{"不按时间轮转", "", 10, start.Add(48 * time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &RotatingFile{interval: tt.interval, size: tt.size}
+			f.period = f.periodOf(start)
+			if got := f.shouldRotate(1, tt.now); got != tt.want {
+				t.Fatalf("shouldRotate(%s) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotatingFileReopenOldPeriod(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 上次运行在两小时前写入，重新打开后第一次写入即按小时轮转
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewRotatingFile(path, 0, RotateHourly, 0, false, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := f.rotatedFiles()
+	if len(rotated) != 1 || readLog(t, rotated[0]) != "old\n" {
+		t.Fatalf("轮转文件 %v", rotated)
+	}
+	if content := readLog(t, path); content != "new\n" {
+		t.Fatalf("当前文件内容 %q", content)
+	}
+}
+
+func TestRotatingFileClosed(t *testing.T) {
+	f, err := NewRotatingFile(filepath.Join(t.TempDir(), "logs", "access.log"), 0, "", 0, false, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("line\n")); err != os.ErrClosed {
+		t.Fatalf("关闭后写入 error = %v, want %v", err, os.ErrClosed)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("重复关闭 error = %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"static-mirrors/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default 服务的指标集合，/metrics 输出其中的所有指标
+var Default = NewRegistry()
+
+// 服务指标，source为源站域名，非代理请求为空
+var (
+	Requests = Default.NewCounterVec("static_mirrors_requests_total",
+		"按源站、状态码和缓存结果统计的请求数", "source", "status", "cache")
+	RequestDuration = Default.NewHistogramVec("static_mirrors_request_duration_seconds",
+		"请求处理总耗时（秒）", nil, "source")
+	UpstreamDuration = Default.NewHistogramVec("static_mirrors_upstream_duration_seconds",
+		"回源请求收到响应头的耗时（秒），包括连接失败的请求", nil, "source")
+	ResponseBytes = Default.NewCounterVec("static_mirrors_response_bytes_total",
+		"发送给客户端的响应体字节数", "source")
+	CacheEvictions = Default.NewCounterVec("static_mirrors_cache_evictions_total",
+		"内存缓存移除的条目数，reason为size（超出容量）或expired（过期清理）", "reason")
+	Purges = Default.NewCounterVec("static_mirrors_purges_total",
+		"缓存刷新次数，result为ok、error或rejected（超出频率或次数限制）", "result")
+	RateLimitRejections = Default.NewCounterVec("static_mirrors_rate_limit_rejections_total",
+		"被速率限制拒绝的请求数")
+	HelmUnsupportedURLs = Default.NewCounterVec("static_mirrors_helm_unsupported_urls_total",
+		"返回的Helm index.yaml中无法通过镜像下载的chart地址数，reason为oci、scheme、not_allowed或invalid", "source", "reason")
+)
+
+// 缓存刷新结果
+const (
+	PurgeOK       = "ok"
+	PurgeError    = "error"
+	PurgeRejected = "rejected"
+)
+
+// Middleware 请求结束后记录请求数、耗时和响应字节数，需要放在logging.Middleware之后以取得源站和缓存结果
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		source := logging.Field(c, logging.FieldSource)
+		status := strconv.Itoa(c.Writer.Status())
+		Requests.With(source, status, logging.Field(c, logging.FieldCache)).Inc()
+		RequestDuration.With(source).Observe(time.Since(start).Seconds())
+		if size := c.Writer.Size(); size > 0 {
+			ResponseBytes.With(source).Add(float64(size))
+		}
+	}
+}
+
+// Handler 以Prometheus文本格式输出指标，token不为空时要求 Authorization: Bearer <token>
+func Handler(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 覆盖CDN缓存中间件设置的缓存头，指标不能被前置CDN缓存
+		c.Header("Cache-Control", "no-store")
+		if token != "" && !validToken(c.GetHeader("Authorization"), token) {
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+			return
+		}
+
+		var buf bytes.Buffer
+		if _, err := Default.WriteTo(&buf); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "输出指标失败", "details": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, ContentType, buf.Bytes())
+	}
+}
+
+// validToken 按固定时间比较令牌，避免通过响应时间猜测令牌
+func validToken(header string, token string) bool {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || header[:len(prefix)] != prefix {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(header[len(prefix):]), []byte(token)) == 1
+}
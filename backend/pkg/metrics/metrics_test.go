@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandlerToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		status        int
+	}{
+		{"未配置令牌", "", "", http.StatusOK},
+		{"令牌正确", "secret", "Bearer secret", http.StatusOK},
+		{"缺少令牌", "secret", "", http.StatusUnauthorized},
+		{"令牌错误", "secret", "Bearer other", http.StatusUnauthorized},
+		{"不是Bearer令牌", "secret", "Basic secret", http.StatusUnauthorized},
+		{"只有前缀", "secret", "Bearer ", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/metrics", Handler(tt.token))
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "no-store" {
+				t.Fatalf("Cache-Control = %q", got)
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("401响应缺少WWW-Authenticate")
+			}
+			if tt.status == http.StatusOK && w.Header().Get("Content-Type") != ContentType {
+				t.Fatalf("Content-Type = %q", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("test_requests_total", "请求数", "status")
+	requests.With("200").Inc()
+	requests.With("200").Add(2)
+	requests.With("404").Inc()
+
+	duration := r.NewHistogramVec("test_duration_seconds", "耗时", []float64{0.1, 1}, "source")
+	duration.With("npm").Observe(0.05)
+	duration.With("npm").Observe(0.5)
+	duration.With("npm").Observe(5)
+
+	r.NewGaugeFunc("test_state", "状态\n说明", []string{"source"}, func() []Sample {
+		return []Sample{{LabelValues: []string{`a"b`}, Value: 1}}
+	})
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{status="200"} 3` + "\n",
+		`test_requests_total{status="404"} 1` + "\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{source="npm",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{source="npm",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{source="npm",le="+Inf"} 3` + "\n",
+		`test_duration_seconds_sum{source="npm"} 5.55` + "\n",
+		`test_duration_seconds_count{source="npm"} 3` + "\n",
+		`# HELP test_state 状态\n说明` + "\n",
+		`test_state{source="a\"b"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出缺少 %q\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ContentType Prometheus文本格式的Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metric 可以按Prometheus文本格式输出的指标
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry 指标集合
+type Registry struct {
+	mutex   sync.Mutex
+	names   map[string]bool
+	metrics []metric
+}
+
+// NewRegistry 创建空的指标集合
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register 登记指标，指标名重复属于编程错误，直接panic
+func (r *Registry) register(name string, m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.names[name] {
+		panic(fmt.Sprintf("指标重复注册: %s", name))
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo 按注册顺序以Prometheus文本格式输出所有指标
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mutex.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// desc 指标名称、说明、类型和标签名
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+// writeHeader 输出 HELP 和 TYPE 行
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// checkLabels 标签值个数必须与标签名一致
+func (d *desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("指标 %s 需要%d个标签值，实际为%d个", d.name, len(d.labels), len(values)))
+	}
+}
+
+// seriesKey 标签值组合的键
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// CounterVec 按标签分组的计数器
+type CounterVec struct {
+	desc
+	mutex  sync.RWMutex
+	series map[string]*Counter
+}
+
+// Counter 单个计数器
+type Counter struct {
+	labels []string
+	bits   atomic.Uint64
+}
+
+// NewCounterVec 创建并注册计数器，labels为标签名
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	v := &CounterVec{
+		desc:   desc{name: name, help: help, kind: "counter", labels: labels},
+		series: make(map[string]*Counter),
+	}
+	r.register(name, v)
+	return v
+}
+
+// With 返回标签值对应的计数器，不存在时创建
+func (v *CounterVec) With(values ...string) *Counter {
+	v.checkLabels(values)
+	key := seriesKey(values)
+
+	v.mutex.RLock()
+	c, exists := v.series[key]
+	v.mutex.RUnlock()
+	if exists {
+		return c
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if c, exists = v.series[key]; !exists {
+		c = &Counter{labels: append([]string(nil), values...)}
+		v.series[key] = c
+	}
+	return c
+}
+
+// Inc 加1
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add 增加计数，delta不能为负数
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	addFloat(&c.bits, delta)
+}
+
+// Value 当前计数
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+	for _, c := range v.sorted() {
+		writeSample(w, v.name, v.labels, c.labels, "", "", c.Value())
+	}
+}
+
+// sorted 按标签值排序的计数器，保证输出顺序稳定
+func (v *CounterVec) sorted() []*Counter {
+	v.mutex.RLock()
+	counters := make([]*Counter, 0, len(v.series))
+	for _, c := range v.series {
+		counters = append(counters, c)
+	}
+	v.mutex.RUnlock()
+
+	sort.Slice(counters, func(i, j int) bool {
+		return seriesKey(counters[i].labels) < seriesKey(counters[j].labels)
+	})
+	return counters
+}
+
+// HistogramVec 按标签分组的直方图
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mutex   sync.RWMutex
+	series  map[string]*Histogram
+}
+
+// Histogram 单个直方图
+type Histogram struct {
+	labels  []string
+	buckets []float64
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	sum     atomic.Uint64
+}
+
+// DefaultBuckets 默认的耗时分桶（秒），覆盖从缓存命中到大文件下载
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// NewHistogramVec 创建并注册直方图，buckets为升序的分桶上限，为空时使用DefaultBuckets
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	v := &HistogramVec{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*Histogram),
+	}
+	r.register(name, v)
+	return v
+}
+
+// With 返回标签值对应的直方图，不存在时创建
+func (v *HistogramVec) With(values ...string) *Histogram {
+	v.checkLabels(values)
+	key := seriesKey(values)
+
+	v.mutex.RLock()
+	h, exists := v.series[key]
+	v.mutex.RUnlock()
+	if exists {
+		return h
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if h, exists = v.series[key]; !exists {
+		h = &Histogram{
+			labels:  append([]string(nil), values...),
+			buckets: v.buckets,
+			counts:  make([]atomic.Uint64, len(v.buckets)),
+		}
+		v.series[key] = h
+	}
+	return h
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.buckets, value)
+	if i < len(h.counts) {
+		h.counts[i].Add(1)
+	}
+	addFloat(&h.sum, value)
+	h.count.Add(1)
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+
+	v.mutex.RLock()
+	histograms := make([]*Histogram, 0, len(v.series))
+	for _, h := range v.series {
+		histograms = append(histograms, h)
+	}
+	v.mutex.RUnlock()
+	sort.Slice(histograms, func(i, j int) bool {
+		return seriesKey(histograms[i].labels) < seriesKey(histograms[j].labels)
+	})
+
+	for _, h := range histograms {
+		// 先读取总数再累加分桶，并发写入时保证分桶不超过总数
+		count := h.count.Load()
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += h.counts[i].Load()
+			cumulative = min(cumulative, count)
+			writeSample(w, v.name+"_bucket", v.labels, h.labels, "le", formatFloat(upper), float64(cumulative))
+		}
+		writeSample(w, v.name+"_bucket", v.labels, h.labels, "le", "+Inf", float64(count))
+		writeSample(w, v.name+"_sum", v.labels, h.labels, "", "", math.Float64frombits(h.sum.Load()))
+		writeSample(w, v.name+"_count", v.labels, h.labels, "", "", float64(count))
+	}
+}
+
+// Sample 抓取时计算的一个取值，LabelValues与指标的标签名一一对应
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc 抓取时调用函数取值的仪表，用于缓存大小、熔断器状态等已有的状态
+type GaugeFunc struct {
+	desc
+	fn func() []Sample
+}
+
+// NewGaugeFunc 创建并注册仪表，每次抓取时调用fn
+func (r *Registry) NewGaugeFunc(name string, help string, labels []string, fn func() []Sample) *GaugeFunc {
+	g := &GaugeFunc{
+		desc: desc{name: name, help: help, kind: "gauge", labels: labels},
+		fn:   fn,
+	}
+	r.register(name, g)
+	return g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	for _, sample := range g.fn() {
+		g.checkLabels(sample.LabelValues)
+		writeSample(w, g.name, g.labels, sample.LabelValues, "", "", sample.Value)
+	}
+}
+
+// writeSample 输出一行样本，extraName不为空时追加一个标签（直方图的le）
+func writeSample(w *bufio.Writer, name string, labels []string, values []string, extraName string, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabel(values[i]))
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+// formatFloat 按Prometheus文本格式输出数值
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// escapeLabel 转义标签值中的反斜杠、双引号和换行
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// escapeHelp 转义说明中的反斜杠和换行
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// addFloat 原子地给以位形式保存的浮点数加上delta
+func addFloat(bits *atomic.Uint64, delta float64) {
+	for {
+		old := bits.Load()
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+		if bits.CompareAndSwap(old, updated) {
+			return
+		}
+	}
+}
@@ -3,8 +3,13 @@ package utils
 import (
 	"fmt"
 	"net/http"
+	"runtime/debug"
+	"sync"
 	"time"
 
+	"static-mirrors/pkg/logging"
+	"static-mirrors/pkg/metrics"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -53,29 +58,38 @@ func SecurityMiddleware() gin.HandlerFunc {
 }
 
 // RateLimitMiddleware 速率限制中间件
-func RateLimitMiddleware(maxRequests int, duration time.Duration) gin.HandlerFunc {
+// limit 每次请求时调用，返回每个客户端在duration内允许的最大请求数，返回0表示不限制，配置热更新后立即生效
+func RateLimitMiddleware(limit func() int, duration time.Duration) gin.HandlerFunc {
 	// 简单的内存速率限制实现
 	// 生产环境中建议使用Redis等分布式方案
+	var mu sync.Mutex
 	requests := make(map[string][]time.Time)
 
 	return func(c *gin.Context) {
+		maxRequests := limit()
+		if maxRequests <= 0 {
+			c.Next()
+			return
+		}
+
 		// 获取客户端IP
 		clientIP := c.ClientIP()
 
+		mu.Lock()
 		// 清理过期的请求记录
 		now := time.Now()
-		if reqs, exists := requests[clientIP]; exists {
-			var validReqs []time.Time
-			for _, reqTime := range reqs {
-				if now.Sub(reqTime) < duration {
-					validReqs = append(validReqs, reqTime)
-				}
+		var validReqs []time.Time
+		for _, reqTime := range requests[clientIP] {
+			if now.Sub(reqTime) < duration {
+				validReqs = append(validReqs, reqTime)
 			}
-			requests[clientIP] = validReqs
 		}
 
 		// 检查请求数是否超过限制
-		if len(requests[clientIP]) >= maxRequests {
+		if len(validReqs) >= maxRequests {
+			requests[clientIP] = validReqs
+			mu.Unlock()
+			metrics.RateLimitRejections.With().Inc()
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error": "请求过于频繁，请稍后再试",
 			})
@@ -84,8 +98,35 @@ func RateLimitMiddleware(maxRequests int, duration time.Duration) gin.HandlerFun
 		}
 
 		// 记录新的请求
-		requests[clientIP] = append(requests[clientIP], now)
+		requests[clientIP] = append(validReqs, now)
+		mu.Unlock()
+
+		c.Next()
+	}
+}
 
+// RecoveryMiddleware 捕获处理器中的panic，记录日志并返回500
+// 与gin.Recovery不同，http.ErrAbortHandler 会继续向上抛出，由net/http中止响应
+// （HTTP/1.1关闭连接，HTTP/2发送RST_STREAM），用于已经开始输出后才发现内容有误的情况
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			logging.Get(c).Error("处理请求时发生panic", "error", err, "stack", string(debug.Stack()))
+			if c.Writer.Written() {
+				// 状态码已经写出，无法再返回500
+				c.Abort()
+				return
+			}
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}()
 		c.Next()
 	}
 }
@@ -97,20 +138,23 @@ func CDNCacheMiddleware() gin.HandlerFunc {
 		path := c.Request.URL.Path
 
 		// 根据文件类型设置不同的缓存时间
+		var cacheControl string
 		switch {
 		case hasSuffix(path, ".js"), hasSuffix(path, ".css"):
 			// JavaScript和CSS文件缓存1天
-			c.Header("Cache-Control", "public, max-age=86400, stale-while-revalidate=3600")
+			cacheControl = "public, max-age=86400, stale-while-revalidate=3600"
 		case hasSuffix(path, ".jpg"), hasSuffix(path, ".jpeg"), hasSuffix(path, ".png"), hasSuffix(path, ".gif"), hasSuffix(path, ".webp"):
 			// 图片文件缓存7天
-			c.Header("Cache-Control", "public, max-age=604800, stale-while-revalidate=86400")
+			cacheControl = "public, max-age=604800, stale-while-revalidate=86400"
 		case hasSuffix(path, ".ico"), hasSuffix(path, ".woff"), hasSuffix(path, ".woff2"), hasSuffix(path, ".ttf"), hasSuffix(path, ".eot"):
 			// 字体和图标文件缓存30天
-			c.Header("Cache-Control", "public, max-age=2592000, stale-while-revalidate=604800")
+			cacheControl = "public, max-age=2592000, stale-while-revalidate=604800"
 		default:
 			// 其他文件缓存1小时
-			c.Header("Cache-Control", "public, max-age=3600, stale-while-revalidate=600")
+			cacheControl = "public, max-age=3600, stale-while-revalidate=600"
 		}
+		c.Header("Cache-Control", cacheControl)
+		c.Writer = &errorNoStoreWriter{ResponseWriter: c.Writer, cacheControl: cacheControl}
 
 		// 添加CDN相关的头
 		c.Header("X-Cache-Status", "MISS") // 将来可以根据实际缓存状态修改
@@ -120,6 +164,21 @@ func CDNCacheMiddleware() gin.HandlerFunc {
 	}
 }
 
+// errorNoStoreWriter 错误响应（4xx/5xx）仍使用默认缓存头时改为no-store，
+// 避免封禁、限流等错误被CDN按静态资源缓存；处理器自行设置的缓存头保持不变
+type errorNoStoreWriter struct {
+	gin.ResponseWriter
+	cacheControl string
+}
+
+func (w *errorNoStoreWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest && w.Header().Get("Cache-Control") == w.cacheControl {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Del("Expires")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
 // hasSuffix 检查字符串是否以指定后缀结尾
 func hasSuffix(s, suffix string) bool {
 	if len(s) < len(suffix) {
@@ -137,7 +196,7 @@ func ErrorHandlerMiddleware() gin.HandlerFunc {
 		if len(c.Errors) > 0 {
 			// 记录错误
 			for _, err := range c.Errors {
-				fmt.Printf("Error: %v\n", err)
+				logging.Get(c).Error("请求处理出错", "error", err.Err)
 			}
 
 			// 如果没有设置响应状态码，设置为500
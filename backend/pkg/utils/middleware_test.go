@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCDNCacheMiddlewareErrorNoStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CDNCacheMiddleware())
+	r.GET("/ok.js", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/blocked.js", func(c *gin.Context) { c.JSON(http.StatusForbidden, gin.H{"error": "该URL已被封禁"}) })
+	r.GET("/custom.js", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=60")
+		c.Status(http.StatusNotFound)
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/ok.js", "public, max-age=86400, stale-while-revalidate=3600"},
+		{"/blocked.js", "no-store"},
+		{"/custom.js", "public, max-age=60"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if got := w.Header().Get("Cache-Control"); got != tt.want {
+				t.Fatalf("Cache-Control = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RecoveryMiddleware())
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	r.GET("/abort", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		panic(http.ErrAbortHandler)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("panic后状态码 = %d, want 500", w.Code)
+	}
+
+	// http.ErrAbortHandler 交给net/http中止连接
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Fatalf("recover() = %v, want http.ErrAbortHandler", err)
+		}
+	}()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+	t.Fatal("http.ErrAbortHandler 被中间件吞掉")
+}